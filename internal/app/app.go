@@ -1,16 +1,35 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"linkshrink/internal/auth"
 	"linkshrink/internal/config"
 	"linkshrink/internal/controller"
 	"linkshrink/internal/handlers"
+	"linkshrink/internal/middleware"
 	"linkshrink/internal/repository"
 	"linkshrink/internal/service"
+	"linkshrink/internal/utils/logger"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	// Регистрация доступных типов хранилищ.
+	_ "linkshrink/internal/repository/file_store"
+	_ "linkshrink/internal/repository/memory_store"
+	_ "linkshrink/internal/repository/postgres_store"
+	_ "linkshrink/internal/repository/sqlite_store"
 
 	"go.uber.org/zap"
 )
 
+// closeTimeout - время на освобождение ресурсов после остановки сервера.
+const closeTimeout = 10 * time.Second
+
 func Run() error {
 	// Создаем логгер
 	logger, err := zap.NewProduction()
@@ -29,14 +48,137 @@ func Run() error {
 		return fmt.Errorf("failed to initialize config: %w", err)
 	}
 
+	// Сервис останавливается по SIGINT или SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Создаем экземпляр репозитория для хранения URL
-	urlRepo := repository.NewStore("file", cfg.FileStoragePath, logger)
+	urlRepo, err := repository.NewStore(cfg.StorageType, cfg, logger)
+	if err != nil {
+		logger.Error("Error initializing storage", zap.String("type", cfg.StorageType), zap.Error(err))
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer closeStore(urlRepo, logger)
+
+	if expirer, ok := urlRepo.(repository.IExpirer); ok {
+		reaper := service.NewReaper(expirer, cfg.Expiration, time.Now, logger)
+		reaperDone := make(chan struct{})
+		go func() {
+			defer close(reaperDone)
+			reaper.Run(ctx)
+		}()
+		defer func() {
+			// Хранилище закрывается только после остановки удаления истекших ссылок.
+			stop()
+			<-reaperDone
+		}()
+	}
+
+	idGenerator, err := service.NewIDGenerator(cfg.ID)
+	if err != nil {
+		logger.Error("Error initializing ID generator", zap.Error(err))
+		return fmt.Errorf("failed to initialize ID generator: %w", err)
+	}
+
+	if cfg.ID.PoolSize > 0 {
+		pool, err := newKeyPool(idGenerator, urlRepo, cfg, logger)
+		if err != nil {
+			logger.Error("Error initializing key pool", zap.Error(err))
+			return fmt.Errorf("failed to initialize key pool: %w", err)
+		}
+		defer func() {
+			// Резерв снимается после остановки сервера, когда новые ID уже не выдаются.
+			closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			defer cancel()
+			if err := pool.Close(closeCtx); err != nil {
+				logger.Error("Error closing key pool", zap.Error(err))
+			}
+		}()
+		idGenerator = pool
+	}
+
+	privateIDGenerator, err := service.NewPrivateIDGenerator(cfg.ID)
+	if err != nil {
+		logger.Error("Error initializing private ID generator", zap.Error(err))
+		return fmt.Errorf("failed to initialize private ID generator: %w", err)
+	}
+
+	normalizer, err := service.NewURLNormalizer(cfg.URL)
+	if err != nil {
+		logger.Error("Error initializing URL normalizer", zap.Error(err))
+		return fmt.Errorf("failed to initialize URL normalizer: %w", err)
+	}
+
+	shorteners, err := service.NewShortenerDetector(cfg.URL)
+	if err != nil {
+		logger.Error("Error initializing shortener detector", zap.Error(err))
+		return fmt.Errorf("failed to initialize shortener detector: %w", err)
+	}
+
+	var blocklist *service.Blocklist
+	if cfg.Blocklist.Path != "" {
+		if blocklist, err = startBlocklist(ctx, cfg, logger); err != nil {
+			logger.Error("Error initializing blocklist", zap.Error(err))
+			return fmt.Errorf("failed to initialize blocklist: %w", err)
+		}
+	}
+
+	var networkGuard *service.NetworkGuard
+	if cfg.URL.BlockPrivateNetworks {
+		networkGuard = service.NewNetworkGuard(net.DefaultResolver)
+	}
+
+	var deleter *service.Deleter
+	if repoDeleter, ok := urlRepo.(repository.IDeleter); ok {
+		deleter = service.NewDeleter(repoDeleter, cfg.Deletion, logger)
+		deleterDone := make(chan struct{})
+		go func() {
+			defer close(deleterDone)
+			deleter.Run()
+		}()
+		defer func() {
+			// Сервер к этому моменту остановлен: дожидаемся удаления ссылок из очереди до закрытия хранилища.
+			deleter.Close()
+			<-deleterDone
+		}()
+	}
 
-	urlService := service.NewURLService(urlRepo)
+	urlService := service.NewURLServiceWithConfig(urlRepo, service.URLServiceConfig{
+		IDGenerator:        idGenerator,
+		PrivateIDGenerator: privateIDGenerator,
+		Normalizer:         normalizer,
+		Blocklist:          blocklist,
+		Shorteners:         shorteners,
+		NetworkGuard:       networkGuard,
+		PasswordLimiter:    service.NewPasswordLimiter(cfg.Password, time.Now),
+		Deleter:            deleter,
+		ExpiredRetention:   cfg.Expiration.Retention,
+		Logger:             logger,
+	})
 
 	urlController := controller.NewURLController(cfg, urlService, logger)
 
-	err = handlers.StartServer(cfg, urlController, logger)
+	signer, err := newSigner(cfg, logger)
+	if err != nil {
+		logger.Error("Error initializing user cookie signer", zap.Error(err))
+		return fmt.Errorf("failed to initialize user cookie signer: %w", err)
+	}
+
+	// Если хранилище не поддерживает API-ключи, принимается только административный ключ из конфигурации.
+	keyStore, _ := urlRepo.(repository.IAPIKeyStore)
+	keyService, err := service.NewAPIKeyService(keyStore, service.APIKeyServiceConfig{AdminKey: cfg.APIKey.AdminKey})
+	if err != nil {
+		logger.Error("Error initializing API keys", zap.Error(err))
+		return fmt.Errorf("failed to initialize API keys: %w", err)
+	}
+	keyAuth, err := middleware.NewKeyAuth(keyService, cfg.APIKey.Policy, logger)
+	if err != nil {
+		logger.Error("Error initializing API key authentication", zap.Error(err))
+		return fmt.Errorf("failed to initialize API key authentication: %w", err)
+	}
+	keyController := controller.NewAPIKeyController(keyService, logger)
+
+	err = handlers.StartServer(ctx, cfg, urlController, keyController, signer, keyAuth, logger)
 
 	if err != nil {
 		logger.Error("Error on start serve", zap.Error(err))
@@ -45,3 +187,72 @@ func Run() error {
 
 	return nil
 }
+
+// newSigner создает подпись cookie пользователей с ключом из конфигурации.
+// Если ключ не задан, генерируется случайный.
+func newSigner(cfg *config.Config, log logger.Logger) (*auth.Signer, error) {
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		log.Warn("Auth secret is not set, users will lose access to their links after restart")
+		var err error
+		if secret, err = auth.RandomSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+		}
+	}
+	signer, err := auth.NewSigner(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+	return signer, nil
+}
+
+// newKeyPool создает пул зарезервированных ID, если хранилище поддерживает резервирование.
+func newKeyPool(
+	idGenerator service.IDGenerator,
+	urlRepo repository.IURLRepository,
+	cfg *config.Config,
+	log logger.Logger,
+) (*service.KeyPool, error) {
+	reserver, ok := urlRepo.(repository.IKeyReserver)
+	if !ok {
+		return nil, fmt.Errorf("storage %s doesn't support ID reservation", cfg.StorageType)
+	}
+
+	pool, err := service.NewKeyPool(idGenerator, reserver, service.KeyPoolConfig{
+		Size:         cfg.ID.PoolSize,
+		LowWatermark: cfg.ID.PoolLowWatermark,
+		BatchSize:    cfg.ID.PoolBatchSize,
+	}, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key pool: %w", err)
+	}
+	return pool, nil
+}
+
+// startBlocklist загружает блок-лист и запускает его перезагрузку
+// при изменении файла или по SIGHUP до отмены ctx.
+func startBlocklist(ctx context.Context, cfg *config.Config, log logger.Logger) (*service.Blocklist, error) {
+	blocklist, err := service.NewBlocklist(cfg.Blocklist.Path, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blocklist: %w", err)
+	}
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(reload)
+		blocklist.Watch(ctx, cfg.Blocklist.ReloadInterval, reload)
+	}()
+	return blocklist, nil
+}
+
+// closeStore закрывает хранилище, если оно держит ресурсы (файлы, соединения).
+func closeStore(urlRepo repository.IURLRepository, log logger.Logger) {
+	closer, ok := urlRepo.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Error("Error closing storage", zap.Error(err))
+	}
+}
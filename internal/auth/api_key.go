@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scope - действие, разрешенное API-ключу.
+type Scope string
+
+const (
+	ScopeShorten Scope = "shorten" // Сокращение ссылок
+	ScopeRead    Scope = "read"    // Просмотр ссылок владельца ключа
+	ScopeDelete  Scope = "delete"  // Удаление ссылок владельца ключа
+	ScopeAdmin   Scope = "admin"   // Управление API-ключами
+)
+
+// Scopes возвращает все права в каноническом порядке.
+func Scopes() []Scope {
+	return []Scope{ScopeShorten, ScopeRead, ScopeDelete, ScopeAdmin}
+}
+
+const (
+	// APIKeyPrefix отличает API-ключи от других токенов, например при поиске утечек в коде.
+	APIKeyPrefix = "lsk_"
+
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+)
+
+var (
+	// ErrInvalidAPIKey возвращается для API-ключа с неверным форматом, неизвестного или отозванного.
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidScope возвращается для неизвестного права.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// ParseScopes проверяет права и возвращает их без повторов в каноническом порядке.
+func ParseScopes(values []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(values))
+	for _, value := range values {
+		scope := Scope(value)
+		if !slices.Contains(Scopes(), scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, value)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.SortFunc(scopes, func(a, b Scope) int {
+		return slices.Index(Scopes(), a) - slices.Index(Scopes(), b)
+	})
+	return scopes, nil
+}
+
+// NewAPIKey генерирует API-ключ вида lsk_<id>_<секрет>. ID открыто идентифицирует ключ
+// и хранится как есть, секрет хранится только в виде хеша (см. HashAPIKeySecret).
+func NewAPIKey() (id string, secret string, token string, err error) {
+	idBytes := make([]byte, apiKeyIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key ID: %w", err)
+	}
+	secretBytes := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key secret: %w", err)
+	}
+	id = hex.EncodeToString(idBytes)
+	secret = base64.RawURLEncoding.EncodeToString(secretBytes)
+	return id, secret, APIKeyPrefix + id + "_" + secret, nil
+}
+
+// ParseAPIKey разбирает API-ключ на ID и секрет.
+func ParseAPIKey(token string) (id string, secret string, err error) {
+	rest, ok := strings.CutPrefix(token, APIKeyPrefix)
+	if !ok {
+		return "", "", ErrInvalidAPIKey
+	}
+	// Секрет в base64url может содержать "_", а ID - нет.
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return id, secret, nil
+}
+
+// HashAPIKeySecret возвращает SHA-256 секрета API-ключа в hex. Секрет случайный и длинный,
+// поэтому медленный хеш вроде bcrypt не нужен: перебор невозможен и без него.
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+// Package auth идентифицирует пользователей по подписанной cookie и программных клиентов по API-ключу.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// CookieName - имя cookie с подписанным ID пользователя.
+	CookieName = "user_id"
+
+	// userIDBytes - количество случайных байт ID пользователя.
+	userIDBytes = 16
+	// MinSecretLength - минимальная длина ключа подписи в байтах.
+	MinSecretLength = 32
+)
+
+var (
+	// ErrInvalidToken возвращается для значения cookie с неверным форматом или подписью.
+	ErrInvalidToken = errors.New("invalid user token")
+	// ErrInvalidSecret возвращается для слишком короткого ключа подписи.
+	ErrInvalidSecret = errors.New("invalid auth secret")
+)
+
+// Signer подписывает ID пользователя HMAC-SHA256, чтобы клиент не мог выдать себя за другого пользователя.
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner создает подпись с ключом secret.
+func NewSigner(secret []byte) (*Signer, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("%w: shorter than %d bytes", ErrInvalidSecret, MinSecretLength)
+	}
+	return &Signer{secret: secret}, nil
+}
+
+// RandomSecret генерирует случайный ключ подписи. Cookie, подписанные им, перестают
+// действовать после перезапуска сервиса.
+func RandomSecret() ([]byte, error) {
+	secret := make([]byte, MinSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+	}
+	return secret, nil
+}
+
+// NewUserID генерирует случайный ID нового пользователя.
+func NewUserID() (string, error) {
+	id := make([]byte, userIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate user ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Sign возвращает значение cookie для пользователя userID: ID и подпись через точку.
+func (s *Signer) Sign(userID string) string {
+	return userID + "." + base64.RawURLEncoding.EncodeToString(s.mac(userID))
+}
+
+// Verify проверяет подпись значения cookie и возвращает ID пользователя.
+func (s *Signer) Verify(token string) (string, error) {
+	userID, signature, ok := strings.Cut(token, ".")
+	if !ok || userID == "" {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(userID)) {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func (s *Signer) mac(userID string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(userID))
+	return h.Sum(nil)
+}
+
+// Identity - пользователь, от имени которого выполняется запрос.
+type Identity struct {
+	UserID string
+	// Rejected сообщает, что запрос пришел с cookie, подпись которой не прошла проверку,
+	// и пользователю выдан новый ID.
+	Rejected bool
+	// KeyID - API-ключ, которым подписан запрос; пустой - пользователь определен по cookie.
+	KeyID string
+	// Scopes - права API-ключа. Для пользователя, определенного по cookie, не используются.
+	Scopes []Scope
+}
+
+// ByAPIKey сообщает, определен ли пользователь по API-ключу.
+func (i Identity) ByAPIKey() bool {
+	return i.KeyID != ""
+}
+
+// Allows сообщает, есть ли у API-ключа запроса право scope.
+func (i Identity) Allows(scope Scope) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+type identityKey struct{}
+
+// WithIdentity возвращает контекст с пользователем запроса.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext возвращает пользователя запроса, если он определен.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,83 @@
+package auth_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"linkshrink/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("k"), auth.MinSecretLength))
+	require.NoError(t, err)
+	other, err := auth.NewSigner(bytes.Repeat([]byte("o"), auth.MinSecretLength))
+	require.NoError(t, err)
+
+	userID, err := auth.NewUserID()
+	require.NoError(t, err)
+	token := signer.Sign(userID)
+
+	verified, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, verified)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "other secret", token: other.Sign(userID)},
+		{name: "other user", token: "intruder" + token[len(userID):]},
+		{name: "no signature", token: userID},
+		{name: "empty signature", token: userID + "."},
+		{name: "not base64", token: userID + ".!!!"},
+		{name: "empty user", token: signer.Sign("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.token)
+			require.ErrorIs(t, err, auth.ErrInvalidToken)
+		})
+	}
+}
+
+func TestNewSigner_ShortSecret(t *testing.T) {
+	_, err := auth.NewSigner([]byte("short"))
+	require.ErrorIs(t, err, auth.ErrInvalidSecret)
+}
+
+func TestAPIKey(t *testing.T) {
+	id, secret, token, err := auth.NewAPIKey()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, auth.APIKeyPrefix))
+
+	parsedID, parsedSecret, err := auth.ParseAPIKey(token)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsedID)
+	assert.Equal(t, secret, parsedSecret)
+	assert.Equal(t, auth.HashAPIKeySecret(secret), auth.HashAPIKeySecret(parsedSecret))
+	assert.NotEqual(t, auth.HashAPIKeySecret(secret), auth.HashAPIKeySecret(secret+"x"))
+
+	for _, token := range []string{"", id + "_" + secret, auth.APIKeyPrefix + id, auth.APIKeyPrefix + "_" + secret,
+		auth.APIKeyPrefix + id + "_"} {
+		_, _, err := auth.ParseAPIKey(token)
+		require.ErrorIs(t, err, auth.ErrInvalidAPIKey, token)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := auth.ParseScopes([]string{"admin", "read", "shorten", "read"})
+	require.NoError(t, err)
+	assert.Equal(t, []auth.Scope{auth.ScopeShorten, auth.ScopeRead, auth.ScopeAdmin}, scopes)
+
+	_, err = auth.ParseScopes([]string{"read", "write"})
+	require.ErrorIs(t, err, auth.ErrInvalidScope)
+
+	identity := auth.Identity{KeyID: "k1", Scopes: scopes}
+	assert.True(t, identity.ByAPIKey())
+	assert.True(t, identity.Allows(auth.ScopeRead))
+	assert.False(t, identity.Allows(auth.ScopeDelete))
+}
@@ -2,33 +2,361 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config - структура для хранения конфигурации сервиса.
 type Config struct {
-	Address         string // Адрес запуска HTTP-сервера
-	BaseURL         string // Базовый адрес результирующего сокращённого URL
-	FileStoragePath string
+	Address     string // Адрес запуска HTTP-сервера
+	BaseURL     string // Базовый адрес результирующего сокращённого URL
+	StorageType string // Тип хранилища: memory, file, sqlite или postgres
+
+	ID         IDConfig          // Настройки генератора коротких ID
+	URL        URLConfig         // Настройки проверки оригинальных URL
+	Blocklist  BlocklistConfig   // Настройки блок-листа доменов
+	Expiration ExpirationConfig  // Настройки удаления ссылок с истекшим сроком действия
+	Password   PasswordConfig    // Настройки защиты ссылок паролем
+	Auth       AuthConfig        // Настройки идентификации пользователей
+	APIKey     APIKeyConfig      // Настройки API-ключей программных клиентов
+	Deletion   DeletionConfig    // Настройки фонового удаления ссылок пользователями
+	File       FileStorageConfig // Настройки файлового хранилища
+	SQLite     SQLiteConfig      // Настройки хранилища SQLite
+	Postgres   PostgresConfig    // Настройки хранилища PostgreSQL
+}
+
+// IDConfig - настройки генератора коротких ID.
+type IDConfig struct {
+	Strategy string // Стратегия генерации: random, sequential, snowflake или hash
+	Length   int    // Начальная (для sequential - минимальная) длина ID
+	Alphabet string // Алфавит ID: base62 или unambiguous (без 0/O и 1/l/I)
+	Salt     string // Соль для обфускации последовательных ID
+	Node     int64  // Номер узла для Snowflake, у каждого экземпляра должен быть свой
+
+	MinEntropyBits     int // Минимальная энтропия случайных ID, бит
+	PrivateEntropyBits int // Энтропия ID приватных ссылок, бит
+
+	PoolSize         int // Емкость пула заранее зарезервированных ID, 0 - пул не используется
+	PoolLowWatermark int // Количество ID в пуле, ниже которого он пополняется, 0 - четверть PoolSize
+	PoolBatchSize    int // Количество ID, резервируемых за один запрос к хранилищу
+}
+
+// URLConfig - настройки проверки оригинальных URL.
+type URLConfig struct {
+	AllowedSchemes []string // Разрешенные схемы URL
+	MaxLength      int      // Максимальная длина URL после нормализации
+
+	ShortenerHosts  []string // Хосты других сервисов сокращения ссылок
+	ShortenerPolicy string   // Обработка их ссылок: reject - отклонять, flag - сокращать с предупреждением
+
+	BlockPrivateNetworks bool // Отклонять URL, ведущие на loopback, link-local и частные сети
+}
+
+// BlocklistConfig - настройки блок-листа доменов.
+type BlocklistConfig struct {
+	Path           string        // Путь к файлу блок-листа, пустой - блок-лист не используется
+	ReloadInterval time.Duration // Периодичность проверки файла на изменения
+}
+
+// ExpirationConfig - настройки удаления ссылок с истекшим сроком действия.
+type ExpirationConfig struct {
+	ReapInterval time.Duration // Периодичность удаления истекших ссылок
+	Retention    time.Duration // Сколько истекшая ссылка хранится и отвечает 410 Gone, прежде чем будет удалена
+}
+
+// PasswordConfig - ограничения подбора паролей ссылок.
+type PasswordConfig struct {
+	MaxLinkAttempts   int           // Неудачных попыток ввода пароля одной ссылки за окно, со всех клиентов
+	MaxClientAttempts int           // Неудачных попыток ввода паролей с одного клиента за окно, по всем ссылкам
+	AttemptWindow     time.Duration // Окно, в котором считаются неудачные попытки
+}
+
+// AuthConfig - настройки идентификации пользователей.
+type AuthConfig struct {
+	// Secret - ключ подписи cookie пользователя. Пустой - ключ генерируется при запуске,
+	// и пользователи теряют доступ к своим ссылкам после перезапуска.
+	Secret string
+}
+
+// APIKeyConfig - настройки API-ключей программных клиентов.
+type APIKeyConfig struct {
+	// Policy - обработка запросов к API без ключа: allow - пользователь определяется по cookie,
+	// reject - запрос отклоняется. Переход по коротким ссылкам ключа не требует.
+	Policy string
+	// AdminKey - административный ключ, которым создаются первые API-ключи. Пустой - не используется.
+	AdminKey string
+}
+
+// DeletionConfig - настройки фонового удаления ссылок пользователями.
+type DeletionConfig struct {
+	BatchSize     int           // Количество ссылок, удаляемых одним запросом к хранилищу
+	FlushInterval time.Duration // Наибольшая задержка удаления неполного пакета
+}
+
+// FileStorageConfig - настройки файлового хранилища.
+type FileStorageConfig struct {
+	Path               string        // Путь к файлу журнала
+	CompactionInterval time.Duration // Периодичность компактизации журнала
+	MaxJournalRecords  int           // Количество записей в журнале, после которого запускается компактизация
+	MaxJournalSize     int64         // Размер журнала в байтах, после которого запускается компактизация
+}
+
+// SQLiteConfig - настройки хранилища SQLite.
+type SQLiteConfig struct {
+	Path string // Путь к файлу базы
 }
 
+// PostgresConfig - настройки хранилища PostgreSQL.
+type PostgresConfig struct {
+	DSN      string // Строка подключения к PostgreSQL
+	MaxConns int    // Максимальное количество соединений в пуле
+}
+
+const (
+	defaultMaxConns       = 10
+	defaultMinEntropyBits = 40
+)
+
+// Значения по умолчанию, которые сервисы подставляют вместо незаданных настроек.
+const (
+	DefaultIDLength           = 7   // Длина ID по умолчанию: 62^7 - около 3.5 триллионов кодов
+	DefaultPrivateEntropyBits = 128 // Энтропия ID приватных ссылок по умолчанию
+	DefaultPoolBatchSize      = 100 // Количество ID, резервируемых пулом ключей за один запрос к хранилищу
+
+	DefaultMaxURLLength            = 2048             // Максимальная длина URL, поддерживаемая всеми браузерами
+	DefaultBlocklistReloadInterval = 30 * time.Second // Периодичность проверки файла блок-листа на изменения
+	DefaultReapInterval            = time.Minute      // Периодичность удаления истекших ссылок
+	// DefaultExpiredRetention - сколько истекшая ссылка хранится, отвечая 410, прежде чем будет удалена.
+	DefaultExpiredRetention = 24 * time.Hour
+
+	DefaultMaxLinkAttempts   = 10               // Неудачных попыток ввода пароля одной ссылки за окно
+	DefaultMaxClientAttempts = 20               // Неудачных попыток ввода паролей с одного клиента за окно
+	DefaultAttemptWindow     = 15 * time.Minute // Окно, в котором считаются неудачные попытки
+
+	DefaultDeleteBatchSize     = 100         // Количество ссылок, удаляемых одним запросом к хранилищу
+	DefaultDeleteFlushInterval = time.Second // Наибольшая задержка удаления неполного пакета
+
+	DefaultCompactionInterval = 10 * time.Minute // Периодичность компактизации журнала файлового хранилища
+	DefaultMaxJournalRecords  = 100_000          // Количество записей в журнале, запускающее компактизацию
+	DefaultMaxJournalSize     = 64 << 20         // Размер журнала, запускающий компактизацию: 64 МБ
+)
+
+var (
+	// DefaultAllowedSchemes - схемы URL, разрешенные по умолчанию.
+	DefaultAllowedSchemes = []string{"http", "https"}
+	// DefaultShortenerHosts - известные сервисы сокращения ссылок.
+	DefaultShortenerHosts = []string{
+		"bit.ly", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly", "rebrand.ly", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+	}
+)
+
 // InitConfig - функция для инициализации конфигурации из аргументов командной строки.
 func InitConfig() (*Config, error) {
 	addressFlag := flag.String("a", "localhost:8080", "HTTP server address")
 	baseURLFlag := flag.String("b", "http://localhost:8080", "Base URL for the shortened URL")
+	storageTypeFlag := flag.String("s", "",
+		"Storage type: memory, file, sqlite or postgres (by default postgres with -d, file with -f, else memory)")
+	idStrategyFlag := flag.String("id-strategy", "random",
+		"Short URL ID generation strategy: random, sequential, snowflake or hash")
+	idLengthFlag := flag.Int("id-length", DefaultIDLength, "Initial length of the short URL ID")
+	idAlphabetFlag := flag.String("id-alphabet", "base62",
+		"Alphabet of the short URL ID: base62 or unambiguous (without 0/O and 1/l/I)")
+	idSaltFlag := flag.String("id-salt", "", "Salt for obfuscation of sequential IDs")
+	idNodeFlag := flag.Int64("id-node", 0, "Node ID of this instance for the snowflake strategy, 0..1023")
+	idMinEntropyFlag := flag.Int("id-min-entropy", defaultMinEntropyBits,
+		"Minimum entropy of random IDs in bits, the ID length must provide it")
+	idPrivateEntropyFlag := flag.Int("id-private-entropy", DefaultPrivateEntropyBits,
+		"Entropy of private link IDs in bits")
+	idPoolSizeFlag := flag.Int("id-pool-size", 0, "Capacity of the pool of pre-reserved IDs, 0 disables the pool")
+	idPoolLowWatermarkFlag := flag.Int("id-pool-low-watermark", 0,
+		"Number of IDs in the pool below which it is refilled, 0 means a quarter of the pool")
+	idPoolBatchSizeFlag := flag.Int("id-pool-batch-size", DefaultPoolBatchSize,
+		"Number of IDs reserved in the storage per request")
+	urlSchemesFlag := flag.String("url-schemes", strings.Join(DefaultAllowedSchemes, ","),
+		"Comma-separated list of allowed URL schemes")
+	urlMaxLengthFlag := flag.Int("url-max-length", DefaultMaxURLLength, "Maximum length of the original URL")
+	shortenerHostsFlag := flag.String("shortener-hosts", strings.Join(DefaultShortenerHosts, ","),
+		"Comma-separated list of hosts of other URL shorteners")
+	shortenerPolicyFlag := flag.String("shortener-policy", "reject",
+		"Handling of other shorteners' links: reject or flag (shorten with a warning)")
+	blockPrivateFlag := flag.Bool("block-private-networks", false,
+		"Reject URLs resolving to loopback, link-local, private network or cloud metadata addresses")
+	blocklistPathFlag := flag.String("blocklist", "",
+		"Path to the file with blocked domains, empty disables the blocklist")
+	blocklistReloadFlag := flag.Duration("blocklist-reload-interval", DefaultBlocklistReloadInterval,
+		"Interval of checking the blocklist file for changes")
+	reapIntervalFlag := flag.Duration("reap-interval", DefaultReapInterval,
+		"Interval of deleting expired links")
+	expiredRetentionFlag := flag.Duration("expired-retention", DefaultExpiredRetention,
+		"How long expired links are kept answering 410 Gone before they are deleted")
+	linkAttemptsFlag := flag.Int("password-link-attempts", DefaultMaxLinkAttempts,
+		"Failed password attempts per link within the window before it is throttled")
+	clientAttemptsFlag := flag.Int("password-client-attempts", DefaultMaxClientAttempts,
+		"Failed password attempts per client within the window before it is throttled")
+	attemptWindowFlag := flag.Duration("password-attempt-window", DefaultAttemptWindow,
+		"Window in which failed password attempts are counted")
+	authSecretFlag := flag.String("auth-secret", "",
+		"Key for signing user cookies, at least 32 bytes (by default random on every start)")
+	apiKeyPolicyFlag := flag.String("api-key-policy", "allow",
+		"Handling of API requests without an API key: allow (identify the user by cookie) or reject")
+	adminAPIKeyFlag := flag.String("admin-api-key", "",
+		"Admin API key for managing API keys, at least 32 bytes, empty disables it")
+	deleteBatchSizeFlag := flag.Int("delete-batch-size", DefaultDeleteBatchSize,
+		"Number of links deleted by one storage request")
+	deleteFlushFlag := flag.Duration("delete-flush-interval", DefaultDeleteFlushInterval,
+		"Maximum delay of deleting an incomplete batch of links")
 	fileStoragePathFlag := flag.String("f", "default_storage.json", "Path to the file for storing URLs")
+	compactionIntervalFlag := flag.Duration("compaction-interval", DefaultCompactionInterval,
+		"Interval of the file storage journal compaction")
+	maxJournalRecordsFlag := flag.Int("compaction-max-records", DefaultMaxJournalRecords,
+		"Number of journal records that triggers the file storage compaction")
+	maxJournalSizeFlag := flag.Int64("compaction-max-size", DefaultMaxJournalSize,
+		"Journal size in bytes that triggers the file storage compaction")
+	sqlitePathFlag := flag.String("sqlite-path", "linkshrink.db", "Path to the SQLite database file")
+	databaseDSNFlag := flag.String("d", "", "PostgreSQL connection string")
+	maxConnsFlag := flag.Int("db-max-conns", defaultMaxConns, "Maximum number of PostgreSQL connections")
 
 	flag.Parse()
 
-	var address = getValue("SERVER_ADDRESS", addressFlag)
-	var baseURL = getValue("BASE_URL", baseURLFlag)
-	var fileStoragePath = getValue("FILE_STORAGE_PATH", fileStoragePathFlag)
+	cfg := &Config{
+		Address:     getValue("SERVER_ADDRESS", addressFlag),
+		BaseURL:     getValue("BASE_URL", baseURLFlag),
+		StorageType: getValue("STORAGE_TYPE", storageTypeFlag),
+		ID: IDConfig{
+			Strategy: getValue("ID_STRATEGY", idStrategyFlag),
+			Alphabet: getValue("ID_ALPHABET", idAlphabetFlag),
+			Salt:     getValue("ID_SALT", idSaltFlag),
+		},
+		URL: URLConfig{
+			AllowedSchemes:  splitList(getValue("URL_ALLOWED_SCHEMES", urlSchemesFlag)),
+			ShortenerHosts:  splitList(getValue("SHORTENER_HOSTS", shortenerHostsFlag)),
+			ShortenerPolicy: getValue("SHORTENER_POLICY", shortenerPolicyFlag),
+		},
+		Blocklist: BlocklistConfig{
+			Path: getValue("BLOCKLIST_PATH", blocklistPathFlag),
+		},
+		Auth: AuthConfig{
+			Secret: getValue("AUTH_SECRET", authSecretFlag),
+		},
+		APIKey: APIKeyConfig{
+			Policy:   getValue("API_KEY_POLICY", apiKeyPolicyFlag),
+			AdminKey: getValue("ADMIN_API_KEY", adminAPIKeyFlag),
+		},
+		File: FileStorageConfig{
+			Path: getValue("FILE_STORAGE_PATH", fileStoragePathFlag),
+		},
+		SQLite: SQLiteConfig{
+			Path: getValue("SQLITE_PATH", sqlitePathFlag),
+		},
+		Postgres: PostgresConfig{
+			DSN: getValue("DATABASE_DSN", databaseDSNFlag),
+		},
+	}
+
+	var err error
+	if cfg.ID.Length, err = getInt("ID_LENGTH", idLengthFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.Node, err = getInt64("ID_NODE", idNodeFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.MinEntropyBits, err = getInt("ID_MIN_ENTROPY_BITS", idMinEntropyFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.PrivateEntropyBits, err = getInt("ID_PRIVATE_ENTROPY_BITS", idPrivateEntropyFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.PoolSize, err = getInt("ID_POOL_SIZE", idPoolSizeFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.PoolLowWatermark, err = getInt("ID_POOL_LOW_WATERMARK", idPoolLowWatermarkFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.PoolBatchSize, err = getInt("ID_POOL_BATCH_SIZE", idPoolBatchSizeFlag); err != nil {
+		return nil, err
+	}
+	if cfg.ID.PoolLowWatermark == 0 {
+		cfg.ID.PoolLowWatermark = cfg.ID.PoolSize / 4
+	}
+	if cfg.URL.MaxLength, err = getInt("URL_MAX_LENGTH", urlMaxLengthFlag); err != nil {
+		return nil, err
+	}
+	if cfg.URL.BlockPrivateNetworks, err = getBool("BLOCK_PRIVATE_NETWORKS", blockPrivateFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Blocklist.ReloadInterval, err = getDuration("BLOCKLIST_RELOAD_INTERVAL", blocklistReloadFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Expiration.ReapInterval, err = getDuration("REAP_INTERVAL", reapIntervalFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Expiration.Retention, err = getDuration("EXPIRED_RETENTION", expiredRetentionFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Password.MaxLinkAttempts, err = getInt("PASSWORD_LINK_ATTEMPTS", linkAttemptsFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Password.MaxClientAttempts, err = getInt("PASSWORD_CLIENT_ATTEMPTS", clientAttemptsFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Password.AttemptWindow, err = getDuration("PASSWORD_ATTEMPT_WINDOW", attemptWindowFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Deletion.BatchSize, err = getInt("DELETE_BATCH_SIZE", deleteBatchSizeFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Deletion.FlushInterval, err = getDuration("DELETE_FLUSH_INTERVAL", deleteFlushFlag); err != nil {
+		return nil, err
+	}
+	if cfg.File.CompactionInterval, err = getDuration("FILE_COMPACTION_INTERVAL", compactionIntervalFlag); err != nil {
+		return nil, err
+	}
+	if cfg.File.MaxJournalRecords, err = getInt("FILE_COMPACTION_MAX_RECORDS", maxJournalRecordsFlag); err != nil {
+		return nil, err
+	}
+	if cfg.File.MaxJournalSize, err = getInt64("FILE_COMPACTION_MAX_SIZE", maxJournalSizeFlag); err != nil {
+		return nil, err
+	}
+	if cfg.Postgres.MaxConns, err = getInt("DATABASE_MAX_CONNS", maxConnsFlag); err != nil {
+		return nil, err
+	}
+
+	if cfg.StorageType == "" {
+		cfg.StorageType = defaultStorageType(cfg, isSet("f", "FILE_STORAGE_PATH"))
+	}
+
+	return cfg, nil
+}
+
+// defaultStorageType выбирает хранилище, если тип не задан явно: база данных, если задана
+// строка подключения, иначе файл, если путь к нему задан явно (fileSet), иначе память.
+func defaultStorageType(cfg *Config, fileSet bool) string {
+	switch {
+	case cfg.Postgres.DSN != "":
+		return "postgres"
+	case fileSet && cfg.File.Path != "":
+		return "file"
+	default:
+		return "memory"
+	}
+}
+
+// isSet сообщает, задан ли параметр явно: флагом name или переменной окружения envVarKey.
+func isSet(name string, envVarKey string) bool {
+	if _, ok := os.LookupEnv(envVarKey); ok {
+		return true
+	}
 
-	return &Config{
-		Address:         address,
-		BaseURL:         baseURL,
-		FileStoragePath: fileStoragePath,
-	}, nil
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 func getValue(envVarKey string, flagValue *string) string {
@@ -39,3 +367,66 @@ func getValue(envVarKey string, flagValue *string) string {
 
 	return *flagValue
 }
+
+// splitList разбирает список через запятую, пропуская пустые элементы.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getDuration(envVarKey string, flagValue *time.Duration) (time.Duration, error) {
+	envVar, ok := os.LookupEnv(envVarKey)
+	if !ok {
+		return *flagValue, nil
+	}
+
+	value, err := time.ParseDuration(envVar)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVarKey, err)
+	}
+	return value, nil
+}
+
+func getInt(envVarKey string, flagValue *int) (int, error) {
+	envVar, ok := os.LookupEnv(envVarKey)
+	if !ok {
+		return *flagValue, nil
+	}
+
+	value, err := strconv.Atoi(envVar)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVarKey, err)
+	}
+	return value, nil
+}
+
+func getBool(envVarKey string, flagValue *bool) (bool, error) {
+	envVar, ok := os.LookupEnv(envVarKey)
+	if !ok {
+		return *flagValue, nil
+	}
+
+	value, err := strconv.ParseBool(envVar)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", envVarKey, err)
+	}
+	return value, nil
+}
+
+func getInt64(envVarKey string, flagValue *int64) (int64, error) {
+	envVar, ok := os.LookupEnv(envVarKey)
+	if !ok {
+		return *flagValue, nil
+	}
+
+	value, err := strconv.ParseInt(envVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVarKey, err)
+	}
+	return value, nil
+}
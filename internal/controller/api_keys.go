@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"linkshrink/internal/auth"
+	"linkshrink/internal/service"
+	"linkshrink/internal/utils/logger"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type IAPIKeyController interface {
+	CreateAPIKey(w http.ResponseWriter, r *http.Request)
+	ListAPIKeys(w http.ResponseWriter, r *http.Request)
+	RevokeAPIKey(w http.ResponseWriter, r *http.Request)
+}
+
+// APIKeyController обрабатывает запросы администратора к API-ключам.
+type APIKeyController struct {
+	service service.IAPIKeyService
+	logger  logger.Logger
+}
+
+type CreateAPIKeyRequest struct {
+	// Owner - пользователь, от имени которого действует ключ: владелец уже выданного ключа.
+	// Пустой - ключу выдается новый пользователь.
+	Owner  string   `json:"owner"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	ID        string       `json:"id"`
+	Key       string       `json:"key,omitempty"` // Сам ключ, возвращается только при создании
+	Owner     string       `json:"owner"`
+	Name      string       `json:"name,omitempty"`
+	Scopes    []auth.Scope `json:"scopes"`
+	CreatedAt time.Time    `json:"created_at"`
+	RevokedAt *time.Time   `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKeyController создает новый экземпляр APIKeyController.
+func NewAPIKeyController(srv service.IAPIKeyService, log logger.Logger) *APIKeyController {
+	componentLogger := log.With(zap.String("component", "APIKeyController"))
+	return &APIKeyController{service: srv, logger: componentLogger}
+}
+
+// CreateAPIKey выдает API-ключ. Ключ возвращается в ответе один раз, сохранить его должен клиент.
+func (c *APIKeyController) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.logger.Error("Error on decoding", zap.Error(err))
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	key, err := c.service.Create(r.Context(), req.Owner, req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeyRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.logger.Error("Error creating API key", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	resp := apiKeyResponse(key.APIKeyInfo)
+	resp.Key = key.Token
+	// Ответ с ключом не должен оседать в кешах между администратором и сервисом.
+	w.Header().Set("Cache-Control", "no-store")
+	c.writeJSON(w, http.StatusCreated, resp)
+}
+
+// ListAPIKeys возвращает все API-ключи без их секретов.
+func (c *APIKeyController) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := c.service.List(r.Context())
+	if err != nil {
+		c.logger.Error("Error listing API keys", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, apiKeyResponse(key))
+	}
+	c.writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeAPIKey отзывает API-ключ. Повторный отзыв ключа не является ошибкой.
+func (c *APIKeyController) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := c.service.Revoke(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		http.Error(w, "API key not found", http.StatusNotFound)
+	default:
+		c.logger.Error("Error revoking API key", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+	}
+}
+
+func (c *APIKeyController) writeJSON(w http.ResponseWriter, status int, resp any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		c.logger.Error("Error on encoding", zap.Error(err))
+	}
+}
+
+func apiKeyResponse(key service.APIKeyInfo) APIKeyResponse {
+	return APIKeyResponse{
+		ID:        key.ID,
+		Owner:     key.Owner,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"linkshrink/internal/service"
 	"linkshrink/internal/utils/logger"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -17,6 +19,10 @@ type IURLController interface {
 	ShortenURL(w http.ResponseWriter, r *http.Request)
 	RedirectURL(w http.ResponseWriter, r *http.Request)
 	ShortenURLJSON(w http.ResponseWriter, r *http.Request)
+	ShortenURLBatch(w http.ResponseWriter, r *http.Request)
+	UnlockURL(w http.ResponseWriter, r *http.Request)
+	GetUserURLs(w http.ResponseWriter, r *http.Request)
+	DeleteUserURLs(w http.ResponseWriter, r *http.Request)
 }
 
 type URLController struct {
@@ -26,16 +32,45 @@ type URLController struct {
 }
 
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL     string `json:"url"`
+	Private bool   `json:"private"` // Выдать приватную ссылку с длинным неперебираемым ID
+	Alias   string `json:"alias"`   // Выбранный пользователем ID вместо сгенерированного
+	// Срок действия ссылки: момент истечения в RFC 3339 или длительность вида "72h". Не более одного из двух.
+	ExpiresAt time.Time `json:"expires_at"`
+	TTL       string    `json:"ttl"`
+	MaxClicks int       `json:"max_clicks"` // Количество переходов, после которого ссылка перестает работать
+	// Пароль, без которого ссылка не открывается. Принимается только в теле запроса:
+	// параметры URL попадают в логи.
+	Password string `json:"password"`
 }
 
 type ShortenResponse struct {
 	Result string `json:"result"`
 }
 
+// BatchShortenRequest - элемент пакетного запроса. Ограничения ссылки задаются так же, как в ShortenRequest.
+type BatchShortenRequest struct {
+	CorrelationID string    `json:"correlation_id"`
+	OriginalURL   string    `json:"original_url"`
+	Private       bool      `json:"private"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	TTL           string    `json:"ttl"`
+	MaxClicks     int       `json:"max_clicks"`
+	Password      string    `json:"password"`
+}
+
+type BatchShortenResponse struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+}
+
 const (
 	ErrInvalidURL = "Invalid URL"
 	ErrInternal   = "Internal server error"
+	ErrAliasTaken = "Alias already taken"
+	ErrURLBlocked = "URL is blocked"
+	ErrURLExpired = "URL expired"
+	ErrURLDeleted = "URL deleted"
 )
 
 // NewURLController создает новый экземпляр URLController.
@@ -58,20 +93,44 @@ func (c *URLController) ShortenURL(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	shortURL, err := c.service.Shorten(c.cfg.BaseURL, string(url))
-	if err != nil {
-		// Проверяем тип ошибки и отправляем соответствующий ответ.
-		if errors.Is(err, service.ErrInvalidURL) {
-			http.Error(w, ErrInvalidURL, http.StatusBadRequest)
+	// Приватная ссылка запрашивается параметром ?private=true, алиас - параметром ?alias=,
+	// срок действия - параметром ?expires_at= (RFC 3339) или ?ttl= (например, 72h),
+	// лимит переходов - параметром ?max_clicks=.
+	query := r.URL.Query()
+	opts := service.ShortenOptions{Alias: query.Get("alias"), UserID: userID(r)}
+	if value := query.Get("private"); value != "" {
+		if opts.Private, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid private parameter", http.StatusBadRequest)
 			return
 		}
-		c.logger.Error("Error shortening URL", zap.Error(err))
-		http.Error(w, ErrInternal, http.StatusInternalServerError)
+	}
+	if value := query.Get("expires_at"); value != "" {
+		if opts.ExpiresAt, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, "Invalid expires_at parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("ttl"); value != "" {
+		if opts.TTL, err = time.ParseDuration(value); err != nil {
+			http.Error(w, "Invalid ttl parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("max_clicks"); value != "" {
+		if opts.MaxClicks, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid max_clicks parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	shortURL, err := c.service.Shorten(r.Context(), c.cfg.BaseURL, string(url), opts)
+	status, ok := c.shortenStatus(w, err)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
 	var data = []byte(shortURL)
 	n, err := w.Write(data)
 	if err != nil {
@@ -85,7 +144,41 @@ func (c *URLController) ShortenURL(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// shortenStatus выбирает код ответа по результату сокращения.
+// Если URL уже был сокращен, отвечаем 409 с существующей ссылкой в обычном формате тела.
+// При остальных ошибках ответ уже записан и возвращается false.
+func (c *URLController) shortenStatus(w http.ResponseWriter, err error) (int, bool) {
+	switch {
+	case err == nil:
+		return http.StatusCreated, true
+	case errors.Is(err, service.ErrURLConflict):
+		return http.StatusConflict, true
+	case errors.Is(err, service.ErrInvalidURL):
+		http.Error(w, ErrInvalidURL, http.StatusBadRequest)
+		return 0, false
+	case errors.Is(err, service.ErrInvalidAlias), errors.Is(err, service.ErrInvalidExpiration),
+		errors.Is(err, service.ErrInvalidMaxClicks), errors.Is(err, service.ErrInvalidPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	case errors.Is(err, service.ErrAliasTaken):
+		http.Error(w, ErrAliasTaken, http.StatusConflict)
+		return 0, false
+	case errors.Is(err, service.ErrURLBlocked):
+		http.Error(w, ErrURLBlocked, http.StatusForbidden)
+		return 0, false
+	case errors.Is(err, service.ErrSelfReference), errors.Is(err, service.ErrShortenerURL),
+		errors.Is(err, service.ErrRedirectLoop), errors.Is(err, service.ErrPrivateDestination):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return 0, false
+	default:
+		c.logger.Error("Error shortening URL", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+		return 0, false
+	}
+}
+
 // RedirectURL обрабатывает запрос на перенаправление по ID.
+// Для ссылки, защищенной паролем, вместо перенаправления отдается форма ввода пароля.
 func (c *URLController) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
@@ -96,16 +189,14 @@ func (c *URLController) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	originalURL, err := c.service.GetOriginalURL(id)
+	originalURL, err := c.service.GetOriginalURL(r.Context(), c.cfg.BaseURL, id)
 
 	if err != nil {
-		if errors.Is(err, service.ErrURLNotFound) {
-			http.Error(w, "URL not found", http.StatusBadRequest)
+		if errors.Is(err, service.ErrPasswordRequired) {
+			c.renderPasswordForm(w, http.StatusOK, passwordFormData{ID: id})
 			return
 		}
-
-		c.logger.Error("Error on GetOriginalURL", zap.Error(err))
-		http.Error(w, ErrInternal, http.StatusInternalServerError)
+		c.redirectError(w, err)
 		return
 	}
 
@@ -119,6 +210,27 @@ func (c *URLController) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// redirectError отвечает на ошибку получения оригинального URL.
+func (c *URLController) redirectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrURLNotFound):
+		http.Error(w, "URL not found", http.StatusBadRequest)
+	case errors.Is(err, service.ErrURLBlocked):
+		http.Error(w, ErrURLBlocked, http.StatusForbidden)
+	case errors.Is(err, service.ErrURLExpired):
+		http.Error(w, ErrURLExpired, http.StatusGone)
+	case errors.Is(err, service.ErrURLDeleted):
+		http.Error(w, ErrURLDeleted, http.StatusGone)
+	case errors.Is(err, service.ErrRedirectLoop):
+		http.Error(w, "Redirect loop detected", http.StatusLoopDetected)
+	case errors.Is(err, service.ErrLimitedChain):
+		http.Error(w, "Chain of links with click limits", http.StatusConflict)
+	default:
+		c.logger.Error("Error on GetOriginalURL", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+	}
+}
+
 func (c *URLController) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 	var req ShortenRequest
 
@@ -134,25 +246,102 @@ func (c *URLController) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts := service.ShortenOptions{
+		Private:   req.Private,
+		Alias:     req.Alias,
+		ExpiresAt: req.ExpiresAt,
+		MaxClicks: req.MaxClicks,
+		Password:  req.Password,
+		UserID:    userID(r),
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			http.Error(w, "Invalid ttl", http.StatusBadRequest)
+			return
+		}
+		opts.TTL = ttl
+	}
+
 	// Вызываем метод контроллера для сокращения URL.
-	shortURL, err := c.service.Shorten(c.cfg.BaseURL, req.URL)
+	shortURL, err := c.service.Shorten(r.Context(), c.cfg.BaseURL, req.URL, opts)
+	status, ok := c.shortenStatus(w, err)
+	if !ok {
+		return
+	}
+
+	// Формируем ответ.
+	resp := ShortenResponse{Result: shortURL}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		c.logger.Error("Error on encoding", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+	}
+}
+
+// ShortenURLBatch обрабатывает запрос на сокращение пакета URL.
+// Пакет обрабатывается целиком: при ошибке в любом элементе не сокращается ни один URL.
+func (c *URLController) ShortenURLBatch(w http.ResponseWriter, r *http.Request) {
+	var req []BatchShortenRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.logger.Error("Error on decoding", zap.Error(err))
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	items := make([]service.BatchItem, 0, len(req))
+	for _, item := range req {
+		batchItem := service.BatchItem{
+			CorrelationID: item.CorrelationID,
+			OriginalURL:   item.OriginalURL,
+			Private:       item.Private,
+			ExpiresAt:     item.ExpiresAt,
+			MaxClicks:     item.MaxClicks,
+			Password:      item.Password,
+		}
+		if item.TTL != "" {
+			ttl, err := time.ParseDuration(item.TTL)
+			if err != nil {
+				http.Error(w, "Invalid ttl", http.StatusBadRequest)
+				return
+			}
+			batchItem.TTL = ttl
+		}
+		items = append(items, batchItem)
+	}
+
+	results, err := c.service.ShortenBatch(r.Context(), c.cfg.BaseURL, userID(r), items)
 	if err != nil {
-		// Проверяем тип ошибки и отправляем соответствующий ответ.
-		if errors.Is(err, service.ErrInvalidURL) {
-			http.Error(w, ErrInvalidURL, http.StatusBadRequest)
+		if errors.Is(err, service.ErrInvalidURL) || errors.Is(err, service.ErrInvalidBatch) ||
+			errors.Is(err, service.ErrInvalidExpiration) || errors.Is(err, service.ErrInvalidMaxClicks) ||
+			errors.Is(err, service.ErrInvalidPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		c.logger.Error("Error shortening URL", zap.Error(err))
+		if errors.Is(err, service.ErrURLBlocked) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrSelfReference) || errors.Is(err, service.ErrShortenerURL) ||
+			errors.Is(err, service.ErrRedirectLoop) || errors.Is(err, service.ErrPrivateDestination) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		c.logger.Error("Error shortening batch", zap.Error(err))
 		http.Error(w, ErrInternal, http.StatusInternalServerError)
 		return
 	}
 
-	// Формируем ответ.
-	resp := ShortenResponse{Result: shortURL}
+	resp := make([]BatchShortenResponse, 0, len(results))
+	for _, result := range results {
+		resp = append(resp, BatchShortenResponse{CorrelationID: result.CorrelationID, ShortURL: result.ShortURL})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		c.logger.Error("Error on encoding", zap.Error(err))
-		http.Error(w, ErrInternal, http.StatusInternalServerError)
 	}
 }
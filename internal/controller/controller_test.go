@@ -2,13 +2,16 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"linkshrink/internal/auth"
 	"linkshrink/internal/config"
 	"linkshrink/internal/service"
 
@@ -24,16 +27,54 @@ type MockURLService struct {
 	mock.Mock
 }
 
-func (m *MockURLService) Shorten(baseURL string, url string) (string, error) {
-	args := m.Called(baseURL, url)
+func (m *MockURLService) Shorten(
+	ctx context.Context,
+	baseURL string,
+	url string,
+	opts service.ShortenOptions,
+) (string, error) {
+	args := m.Called(ctx, baseURL, url, opts)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockURLService) GetOriginalURL(id string) (string, error) {
-	args := m.Called(id)
+func (m *MockURLService) ShortenBatch(
+	ctx context.Context,
+	baseURL string,
+	userID string,
+	items []service.BatchItem,
+) ([]service.BatchResult, error) {
+	args := m.Called(ctx, baseURL, userID, items)
+	results, _ := args.Get(0).([]service.BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *MockURLService) GetOriginalURL(ctx context.Context, baseURL string, id string) (string, error) {
+	args := m.Called(ctx, baseURL, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockURLService) UnlockURL(
+	ctx context.Context,
+	baseURL string,
+	id string,
+	password string,
+	client string,
+) (string, error) {
+	args := m.Called(ctx, baseURL, id, password, client)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockURLService) GetUserURLs(ctx context.Context, baseURL string, userID string) ([]service.UserURL, error) {
+	args := m.Called(ctx, baseURL, userID)
+	urls, _ := args.Get(0).([]service.UserURL)
+	return urls, args.Error(1)
+}
+
+func (m *MockURLService) DeleteUserURLs(ctx context.Context, userID string, ids []string) error {
+	args := m.Called(ctx, userID, ids)
+	return args.Error(0)
+}
+
 var cfg = config.Config{
 	Address: "Address",
 	BaseURL: "BaseURL",
@@ -51,22 +92,54 @@ func TestShortenURL(t *testing.T) {
 			name: "Valid URL",
 			body: "http://example.com",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://example.com").Return("short.ly/abc123", nil)
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("short.ly/abc123", nil)
 			},
 			expectedCode: http.StatusCreated,
 			expectedBody: "short.ly/abc123",
 		},
+		{
+			name: "Already shortened",
+			body: "http://example.com",
+			mockShorten: func(m *MockURLService) {
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("short.ly/abc123", service.ErrURLConflict)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: "short.ly/abc123",
+		},
 		{
 			name:         "Empty URL",
 			body:         "",
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "Invalid URL\n",
 		},
+		{
+			name: "Blocked URL",
+			body: "http://evil.com",
+			mockShorten: func(m *MockURLService) {
+				m.On("Shorten", mock.Anything, "BaseURL", "http://evil.com", service.ShortenOptions{}).
+					Return("", service.ErrURLBlocked)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "URL is blocked\n",
+		},
+		{
+			name: "Shortener URL",
+			body: "https://bit.ly/abc",
+			mockShorten: func(m *MockURLService) {
+				m.On("Shorten", mock.Anything, "BaseURL", "https://bit.ly/abc", service.ShortenOptions{}).
+					Return("", service.ErrShortenerURL)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: "URL of another shortener\n",
+		},
 		{
 			name: "Invalid URL",
 			body: "http://invalid-url",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://invalid-url").Return("", service.ErrInvalidURL)
+				m.On("Shorten", mock.Anything, "BaseURL", "http://invalid-url", service.ShortenOptions{}).
+					Return("", service.ErrInvalidURL)
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "Invalid URL\n",
@@ -75,7 +148,8 @@ func TestShortenURL(t *testing.T) {
 			name: "Internal Server Error",
 			body: "http://example.com",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://example.com").Return("", errors.New("some error"))
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("", errors.New("some error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: "Internal server error\n",
@@ -112,6 +186,150 @@ func TestShortenURL(t *testing.T) {
 	}
 }
 
+func TestShortenURL_Private(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{Private: true}).
+		Return("short.ly/private", nil)
+	controller := NewURLController(&cfg, mockService, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/?private=true", bytes.NewBufferString("http://example.com"))
+	rr := httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "short.ly/private", rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/?private=maybe", bytes.NewBufferString("http://example.com"))
+	rr = httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenURL_Alias(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{Alias: "q4-report"}).
+		Return("short.ly/q4-report", nil).Once()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://another.com", service.ShortenOptions{Alias: "q4-report"}).
+		Return("", service.ErrAliasTaken).Once()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://another.com", service.ShortenOptions{Alias: "api"}).
+		Return("", service.ErrInvalidAlias).Once()
+	controller := NewURLController(&cfg, mockService, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/?alias=q4-report", bytes.NewBufferString("http://example.com"))
+	rr := httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "short.ly/q4-report", rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://another.com", "alias": "q4-report"}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://another.com", "alias": "api"}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenURL_Expiration(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	expiresAt := time.Date(2026, time.March, 31, 23, 59, 59, 0, time.UTC)
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{TTL: 72 * time.Hour}).
+		Return("short.ly/sale", nil).Once()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com",
+		service.ShortenOptions{ExpiresAt: expiresAt}).Return("short.ly/sale", nil).Once()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://another.com", service.ShortenOptions{TTL: -time.Hour}).
+		Return("", service.ErrInvalidExpiration).Once()
+	controller := NewURLController(&cfg, mockService, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/?ttl=72h", bytes.NewBufferString("http://example.com"))
+	rr := httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com", "expires_at": "2026-03-31T23:59:59Z"}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://another.com", "ttl": "-1h"}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	for _, query := range []string{"/?ttl=3days", "/?expires_at=tomorrow", "/?max_clicks=once"} {
+		req = httptest.NewRequest(http.MethodPost, query, bytes.NewBufferString("http://example.com"))
+		rr = httptest.NewRecorder()
+		controller.ShortenURL(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenURL_MaxClicks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{MaxClicks: 1}).
+		Return("short.ly/once", nil).Twice()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{MaxClicks: -1}).
+		Return("", service.ErrInvalidMaxClicks).Once()
+	controller := NewURLController(&cfg, mockService, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/?max_clicks=1", bytes.NewBufferString("http://example.com"))
+	rr := httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com", "max_clicks": 1}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com", "max_clicks": -1}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenURLJSON_Password(t *testing.T) {
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com",
+		service.ShortenOptions{Password: "secret"}).Return("short.ly/locked", nil).Once()
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com",
+		service.ShortenOptions{Password: "long"}).Return("", service.ErrInvalidPassword).Once()
+	controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com", "password": "secret"}`))
+	rr := httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com", "password": "long"}`))
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
 func TestShortenURLJSON(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -125,11 +343,22 @@ func TestShortenURLJSON(t *testing.T) {
 			name: "Valid URL",
 			body: "http://example.com",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://example.com").Return("short.ly/abc123", nil)
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("short.ly/abc123", nil)
 			},
 			expectedCode: http.StatusCreated,
 			expectedBody: "short.ly/abc123",
 		},
+		{
+			name: "Already shortened",
+			body: "http://example.com",
+			mockShorten: func(m *MockURLService) {
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("short.ly/abc123", service.ErrURLConflict)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: "short.ly/abc123",
+		},
 		{
 			name:          "Empty URL",
 			body:          "",
@@ -140,7 +369,8 @@ func TestShortenURLJSON(t *testing.T) {
 			name: "Invalid URL",
 			body: "http://invalid-url",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://invalid-url").Return("", service.ErrInvalidURL)
+				m.On("Shorten", mock.Anything, "BaseURL", "http://invalid-url", service.ShortenOptions{}).
+					Return("", service.ErrInvalidURL)
 			},
 			expectedCode:  http.StatusBadRequest,
 			expectedError: "Invalid URL\n",
@@ -149,7 +379,8 @@ func TestShortenURLJSON(t *testing.T) {
 			name: "Internal Server Error",
 			body: "http://example.com",
 			mockShorten: func(m *MockURLService) {
-				m.On("Shorten", "BaseURL", "http://example.com").Return("", errors.New("some error"))
+				m.On("Shorten", mock.Anything, "BaseURL", "http://example.com", service.ShortenOptions{}).
+					Return("", errors.New("some error"))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedError: "Internal server error\n",
@@ -206,7 +437,7 @@ func TestRedirectURL(t *testing.T) {
 			name: "Valid ID",
 			id:   "abc123",
 			mockGetOriginal: func(m *MockURLService) {
-				m.On("GetOriginalURL", "abc123").Return("http://example.com", nil)
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("http://example.com", nil)
 			},
 			expectedCode:     http.StatusTemporaryRedirect,
 			expectedLocation: "http://example.com",
@@ -215,15 +446,55 @@ func TestRedirectURL(t *testing.T) {
 			name: "URL Not Found",
 			id:   "nonexistent",
 			mockGetOriginal: func(m *MockURLService) {
-				m.On("GetOriginalURL", "nonexistent").Return("", service.ErrURLNotFound)
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "nonexistent").Return("", service.ErrURLNotFound)
 			},
 			expectedCode: http.StatusBadRequest,
 		},
+		{
+			name: "Blocked URL",
+			id:   "abc123",
+			mockGetOriginal: func(m *MockURLService) {
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", service.ErrURLBlocked)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "Expired URL",
+			id:   "abc123",
+			mockGetOriginal: func(m *MockURLService) {
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", service.ErrURLExpired)
+			},
+			expectedCode: http.StatusGone,
+		},
+		{
+			name: "Deleted URL",
+			id:   "abc123",
+			mockGetOriginal: func(m *MockURLService) {
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", service.ErrURLDeleted)
+			},
+			expectedCode: http.StatusGone,
+		},
+		{
+			name: "Redirect Loop",
+			id:   "abc123",
+			mockGetOriginal: func(m *MockURLService) {
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", service.ErrRedirectLoop)
+			},
+			expectedCode: http.StatusLoopDetected,
+		},
+		{
+			name: "Chain of limited links",
+			id:   "abc123",
+			mockGetOriginal: func(m *MockURLService) {
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", service.ErrLimitedChain)
+			},
+			expectedCode: http.StatusConflict,
+		},
 		{
 			name: "Internal Server Error",
 			id:   "abc123",
 			mockGetOriginal: func(m *MockURLService) {
-				m.On("GetOriginalURL", "abc123").Return("", errors.New("some error"))
+				m.On("GetOriginalURL", mock.Anything, "BaseURL", "abc123").Return("", errors.New("some error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 		},
@@ -262,3 +533,482 @@ func TestRedirectURL(t *testing.T) {
 		})
 	}
 }
+
+// TestRedirectURL_Password тестирует, что вместо перенаправления по ссылке с паролем отдается форма.
+func TestRedirectURL_Password(t *testing.T) {
+	mockService := new(MockURLService)
+	mockService.On("GetOriginalURL", mock.Anything, "BaseURL", "secret").Return("", service.ErrPasswordRequired)
+	controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+	r := mux.NewRouter()
+	r.HandleFunc("/{id}", controller.RedirectURL)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/secret", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Location"))
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rr.Body.String(), `action="/secret"`)
+	assert.Contains(t, rr.Body.String(), `type="password"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestUnlockURL(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedCode     int
+		expectedLocation string
+		expectedHeader   map[string]string
+		expectedBody     string
+	}{
+		{
+			name:             "Correct Password",
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "http://example.com/secret",
+		},
+		{
+			name:         "Wrong Password",
+			err:          service.ErrWrongPassword,
+			expectedCode: http.StatusForbidden,
+			expectedBody: ErrWrongPassword,
+		},
+		{
+			name:           "Too Many Attempts",
+			err:            &service.ThrottledError{RetryAfter: 90*time.Second + time.Millisecond},
+			expectedCode:   http.StatusTooManyRequests,
+			expectedHeader: map[string]string{"Retry-After": "91"},
+		},
+		{
+			name:         "Expired URL",
+			err:          service.ErrURLExpired,
+			expectedCode: http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockURLService)
+			originalURL := ""
+			if tt.err == nil {
+				originalURL = "http://example.com/secret"
+			}
+			mockService.On("UnlockURL", mock.Anything, "BaseURL", "secret", "p@ss word", "192.0.2.1").
+				Return(originalURL, tt.err)
+			controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+			r := mux.NewRouter()
+			r.HandleFunc("/{id}", controller.UnlockURL).Methods(http.MethodPost)
+
+			req := httptest.NewRequest(http.MethodPost, "/secret", bytes.NewBufferString("password=p%40ss+word"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+			for key, value := range tt.expectedHeader {
+				assert.Equal(t, value, rr.Header().Get(key))
+			}
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestShortenURLBatch(t *testing.T) {
+	items := []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com"},
+		{CorrelationID: "2", OriginalURL: "http://another.com"},
+	}
+	validBody := `[{"correlation_id":"1","original_url":"http://example.com"},` +
+		`{"correlation_id":"2","original_url":"http://another.com"}]`
+
+	tests := []struct {
+		name         string
+		body         string
+		mockShorten  func(m *MockURLService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Valid batch",
+			body: validBody,
+			mockShorten: func(m *MockURLService) {
+				m.On("ShortenBatch", mock.Anything, "BaseURL", "", items).Return([]service.BatchResult{
+					{CorrelationID: "1", ShortURL: "BaseURL/abc"},
+					{CorrelationID: "2", ShortURL: "BaseURL/def"},
+				}, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `[{"correlation_id":"1","short_url":"BaseURL/abc"},` +
+				`{"correlation_id":"2","short_url":"BaseURL/def"}]` + "\n",
+		},
+		{
+			name: "Item with limits",
+			body: `[{"correlation_id":"1","original_url":"http://example.com","ttl":"1h","max_clicks":3,` +
+				`"password":"secret"}]`,
+			mockShorten: func(m *MockURLService) {
+				m.On("ShortenBatch", mock.Anything, "BaseURL", "", []service.BatchItem{{
+					CorrelationID: "1", OriginalURL: "http://example.com", TTL: time.Hour, MaxClicks: 3, Password: "secret",
+				}}).Return([]service.BatchResult{{CorrelationID: "1", ShortURL: "BaseURL/abc"}}, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `[{"correlation_id":"1","short_url":"BaseURL/abc"}]` + "\n",
+		},
+		{
+			name:         "Invalid ttl",
+			body:         `[{"correlation_id":"1","original_url":"http://example.com","ttl":"soon"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Invalid ttl\n",
+		},
+		{
+			name:         "Invalid payload",
+			body:         `{"url":"http://example.com"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Invalid request payload\n",
+		},
+		{
+			name: "Invalid limit",
+			body: validBody,
+			mockShorten: func(m *MockURLService) {
+				m.On("ShortenBatch", mock.Anything, "BaseURL", "", items).Return(nil, service.ErrInvalidMaxClicks)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: service.ErrInvalidMaxClicks.Error() + "\n",
+		},
+		{
+			name: "Invalid item",
+			body: validBody,
+			mockShorten: func(m *MockURLService) {
+				m.On("ShortenBatch", mock.Anything, "BaseURL", "", items).Return(nil, service.ErrInvalidURL)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid URL\n",
+		},
+		{
+			name: "Internal Server Error",
+			body: validBody,
+			mockShorten: func(m *MockURLService) {
+				m.On("ShortenBatch", mock.Anything, "BaseURL", "", items).Return(nil, errors.New("some error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal server error\n",
+		},
+	}
+
+	logger := zaptest.NewLogger(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockURLService)
+			if tt.mockShorten != nil {
+				tt.mockShorten(mockService)
+			}
+
+			controller := NewURLController(&cfg, mockService, logger)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			controller.ShortenURLBatch(rr, req)
+
+			res := rr.Result()
+			assert.Equal(t, tt.expectedCode, res.StatusCode)
+
+			body, _ := io.ReadAll(res.Body)
+			err := res.Body.Close()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedBody, string(body))
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetUserURLs(t *testing.T) {
+	tests := []struct {
+		name         string
+		identity     *auth.Identity
+		urls         []service.UserURL
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:     "User URLs",
+			identity: &auth.Identity{UserID: "user1"},
+			urls: []service.UserURL{
+				{ShortURL: "BaseURL/abc", OriginalURL: "http://example.com"},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"short_url":"BaseURL/abc","original_url":"http://example.com"}]`,
+		},
+		{
+			name:         "No URLs",
+			identity:     &auth.Identity{UserID: "user1"},
+			urls:         []service.UserURL{},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Invalid Cookie",
+			identity:     &auth.Identity{UserID: "user2", Rejected: true},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "No Identity",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockURLService)
+			if tt.urls != nil {
+				mockService.On("GetUserURLs", mock.Anything, "BaseURL", tt.identity.UserID).Return(tt.urls, nil)
+			}
+			controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls", http.NoBody)
+			if tt.identity != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.identity))
+			}
+			rr := httptest.NewRecorder()
+			controller.GetUserURLs(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// TestShortenURL_User тестирует, что ссылка создается от имени пользователя запроса.
+func TestShortenURL_User(t *testing.T) {
+	mockService := new(MockURLService)
+	mockService.On("Shorten", mock.Anything, "BaseURL", "http://example.com",
+		service.ShortenOptions{UserID: "user1"}).Return("short.ly/abc", nil).Twice()
+	controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: "user1"})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("http://example.com")).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	controller.ShortenURL(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "http://example.com"}`)).WithContext(ctx)
+	rr = httptest.NewRecorder()
+	controller.ShortenURLJSON(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteUserURLs(t *testing.T) {
+	tests := []struct {
+		name         string
+		identity     *auth.Identity
+		body         string
+		err          error
+		expectedCode int
+	}{
+		{
+			name:         "Accepted",
+			identity:     &auth.Identity{UserID: "user1"},
+			body:         `["abc", "def"]`,
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "Invalid Request",
+			identity:     &auth.Identity{UserID: "user1"},
+			body:         `["abc", "def"]`,
+			err:          service.ErrInvalidDeleteRequest,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Shutting Down",
+			identity:     &auth.Identity{UserID: "user1"},
+			body:         `["abc", "def"]`,
+			err:          service.ErrDeleterStopped,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Queue Full",
+			identity:     &auth.Identity{UserID: "user1"},
+			body:         `["abc", "def"]`,
+			err:          service.ErrDeleterBusy,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Invalid Payload",
+			identity:     &auth.Identity{UserID: "user1"},
+			body:         `{"ids": ["abc"]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid Cookie",
+			identity:     &auth.Identity{UserID: "user2", Rejected: true},
+			body:         `["abc", "def"]`,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockURLService)
+			if tt.expectedCode != http.StatusUnauthorized && tt.body[0] == '[' {
+				mockService.On("DeleteUserURLs", mock.Anything, tt.identity.UserID, []string{"abc", "def"}).
+					Return(tt.err)
+			}
+			controller := NewURLController(&cfg, mockService, zaptest.NewLogger(t))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString(tt.body))
+			req = req.WithContext(auth.WithIdentity(req.Context(), *tt.identity))
+			rr := httptest.NewRecorder()
+			controller.DeleteUserURLs(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// MockAPIKeyService - мок-сервис API-ключей.
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) Create(
+	ctx context.Context,
+	owner string,
+	name string,
+	scopes []string,
+) (service.CreatedAPIKey, error) {
+	args := m.Called(ctx, owner, name, scopes)
+	key, _ := args.Get(0).(service.CreatedAPIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyService) List(ctx context.Context) ([]service.APIKeyInfo, error) {
+	args := m.Called(ctx)
+	keys, _ := args.Get(0).([]service.APIKeyInfo)
+	return keys, args.Error(1)
+}
+
+func (m *MockAPIKeyService) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, token string) (auth.Identity, error) {
+	args := m.Called(ctx, token)
+	identity, _ := args.Get(0).(auth.Identity)
+	return identity, args.Error(1)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	created := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	key := service.CreatedAPIKey{
+		APIKeyInfo: service.APIKeyInfo{ID: "k1", Owner: "alice", Name: "CI",
+			Scopes: []auth.Scope{auth.ScopeShorten}, CreatedAt: created},
+		Token: "lsk_k1_secret",
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Created",
+			body:         `{"owner": "alice", "name": "CI", "scopes": ["shorten"]}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"k1","key":"lsk_k1_secret","owner":"alice","name":"CI","scopes":["shorten"],` +
+				`"created_at":"2026-03-01T12:00:00Z"}`,
+		},
+		{
+			name:         "Invalid Request",
+			body:         `{"owner": "alice", "name": "CI", "scopes": ["shorten"]}`,
+			err:          service.ErrInvalidAPIKeyRequest,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid Payload",
+			body:         `["shorten"]`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAPIKeyService)
+			if tt.body[0] == '{' {
+				mockService.On("Create", mock.Anything, "alice", "CI", []string{"shorten"}).Return(key, tt.err)
+			}
+			controller := NewAPIKeyController(mockService, zaptest.NewLogger(t))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/keys", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			controller.CreateAPIKey(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+				assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	created := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	revoked := created.Add(time.Hour)
+	mockService := new(MockAPIKeyService)
+	mockService.On("List", mock.Anything).Return([]service.APIKeyInfo{
+		{ID: "k1", Owner: "alice", Scopes: []auth.Scope{auth.ScopeRead}, CreatedAt: created, RevokedAt: &revoked},
+	}, nil)
+	controller := NewAPIKeyController(mockService, zaptest.NewLogger(t))
+
+	rr := httptest.NewRecorder()
+	controller.ListAPIKeys(rr, httptest.NewRequest(http.MethodGet, "/api/admin/keys", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	// Секрет ключа в списке не возвращается.
+	assert.JSONEq(t, `[{"id":"k1","owner":"alice","scopes":["read"],"created_at":"2026-03-01T12:00:00Z",`+
+		`"revoked_at":"2026-03-01T13:00:00Z"}]`, rr.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{name: "Revoked", expectedCode: http.StatusNoContent},
+		{name: "Not Found", err: service.ErrAPIKeyNotFound, expectedCode: http.StatusNotFound},
+		{name: "Internal Error", err: errors.New("storage is down"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAPIKeyService)
+			mockService.On("Revoke", mock.Anything, "k1").Return(tt.err)
+			controller := NewAPIKeyController(mockService, zaptest.NewLogger(t))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/keys/k1", http.NoBody)
+			req = mux.SetURLVars(req, map[string]string{"id": "k1"})
+			rr := httptest.NewRecorder()
+			controller.RevokeAPIKey(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
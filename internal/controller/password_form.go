@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"errors"
+	"html/template"
+	"linkshrink/internal/service"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// maxPasswordFormSize - ограничение размера тела формы ввода пароля.
+const maxPasswordFormSize = 4 << 10
+
+const (
+	ErrWrongPassword   = "Wrong password"
+	ErrTooManyAttempts = "Too many password attempts, try again later"
+)
+
+// passwordForm - страница ввода пароля ссылки. Форма отправляется POST-запросом на адрес самой ссылки.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="/{{.ID}}">
+<p><label for="password">This link is password-protected.</label></p>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<p><input type="password" id="password" name="password" autocomplete="off" autofocus required></p>
+<p><button type="submit">Open</button></p>
+</form>
+</body>
+</html>
+`))
+
+// passwordFormData - данные страницы ввода пароля.
+type passwordFormData struct {
+	ID    string
+	Error string
+}
+
+// renderPasswordForm отвечает страницей ввода пароля ссылки id с кодом status.
+func (c *URLController) renderPasswordForm(w http.ResponseWriter, status int, data passwordFormData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Страница зависит от введенного пароля и не должна попадать в кеши.
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := passwordForm.Execute(w, data); err != nil {
+		c.logger.Error("Error rendering password form", zap.Error(err))
+	}
+}
+
+// UnlockURL обрабатывает отправку формы ввода пароля ссылки.
+// При верном пароле отвечает 303 See Other на оригинальный URL, при неверном - 403 с формой,
+// а при превышении лимита попыток - 429 с заголовком Retry-After.
+func (c *URLController) UnlockURL(w http.ResponseWriter, r *http.Request) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		c.logger.Error("Key 'id' not found in route variables")
+		http.Error(w, "ID not found", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	originalURL, err := c.service.UnlockURL(r.Context(), c.cfg.BaseURL, id, r.PostForm.Get("password"), clientIP(r))
+	var throttled *service.ThrottledError
+	switch {
+	case err == nil:
+		http.Redirect(w, r, originalURL, http.StatusSeeOther)
+	case errors.Is(err, service.ErrWrongPassword):
+		c.renderPasswordForm(w, http.StatusForbidden, passwordFormData{ID: id, Error: ErrWrongPassword})
+	case errors.As(err, &throttled):
+		seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(w, ErrTooManyAttempts, http.StatusTooManyRequests)
+	default:
+		c.redirectError(w, err)
+	}
+}
+
+// clientIP возвращает адрес клиента, по которому ограничивается подбор паролей.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"linkshrink/internal/auth"
+	"linkshrink/internal/service"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ErrUnauthorized = "Unauthorized"
+
+	// deleteRetryAfter - через сколько секунд повторить запрос на удаление, если очередь удаления заполнена.
+	deleteRetryAfter = "1"
+)
+
+type UserURLResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+// userID возвращает пользователя запроса, определенного middleware, или пустую строку.
+func userID(r *http.Request) string {
+	identity, _ := auth.FromContext(r.Context())
+	return identity.UserID
+}
+
+// GetUserURLs возвращает ссылки, созданные пользователем запроса.
+// Если ссылок нет, отвечает 204, а если cookie пользователя не прошла проверку подписи - 401.
+func (c *URLController) GetUserURLs(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok || identity.Rejected {
+		http.Error(w, ErrUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	urls, err := c.service.GetUserURLs(r.Context(), c.cfg.BaseURL, identity.UserID)
+	if err != nil {
+		c.logger.Error("Error getting user URLs", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]UserURLResponse, 0, len(urls))
+	for _, url := range urls {
+		resp = append(resp, UserURLResponse{ShortURL: url.ShortURL, OriginalURL: url.OriginalURL})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		c.logger.Error("Error on encoding", zap.Error(err))
+	}
+}
+
+// DeleteUserURLs принимает JSON-массив ID ссылок пользователя запроса на удаление.
+// Ссылки удаляются в фоне, поэтому ответ 202 приходит сразу; чужие ссылки не удаляются.
+// Если очередь удаления заполнена, отвечает 503 с заголовком Retry-After.
+func (c *URLController) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok || identity.Rejected {
+		http.Error(w, ErrUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		c.logger.Error("Error on decoding", zap.Error(err))
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.DeleteUserURLs(r.Context(), identity.UserID, ids)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, service.ErrInvalidDeleteRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrDeleterStopped):
+		http.Error(w, "Service is shutting down", http.StatusServiceUnavailable)
+	case errors.Is(err, service.ErrDeleterBusy):
+		w.Header().Set("Retry-After", deleteRetryAfter)
+		http.Error(w, "Too many delete requests, retry later", http.StatusServiceUnavailable)
+	default:
+		c.logger.Error("Error deleting user URLs", zap.Error(err))
+		http.Error(w, ErrInternal, http.StatusInternalServerError)
+	}
+}
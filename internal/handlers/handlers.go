@@ -1,37 +1,92 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"linkshrink/internal/auth"
 	"linkshrink/internal/config"
 	"linkshrink/internal/controller"
 	"linkshrink/internal/middleware"
 	"linkshrink/internal/utils/logger"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-func StartServer(cfg *config.Config, urlController controller.IURLController, log logger.Logger) error {
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 10 * time.Second // Время на завершение обрабатываемых запросов при остановке
+)
+
+// StartServer запускает HTTP-сервер и работает, пока не будет отменен ctx.
+// После отмены сервер перестает принимать соединения и дожидается завершения текущих запросов.
+func StartServer(
+	ctx context.Context,
+	cfg *config.Config,
+	urlController controller.IURLController,
+	keyController controller.IAPIKeyController,
+	signer *auth.Signer,
+	keyAuth *middleware.KeyAuth,
+	log logger.Logger,
+) error {
 	r := mux.NewRouter()
 
 	componentLogger := log.With(zap.String("component", "handlers"))
 
-	middlewareChain := middleware.InitMiddlewares(log)
+	middlewareChain := middleware.InitMiddlewares(log, signer, keyAuth)
 
 	r.Use(middlewareChain)
 
-	r.HandleFunc("/", urlController.ShortenURL).Methods("POST")
+	// scoped требует для обработчика право API-ключа scope (см. KeyAuth.Require).
+	// Переход по коротким ссылкам доступен всем и права не требует.
+	scoped := func(scope auth.Scope, handler http.HandlerFunc) http.Handler {
+		return keyAuth.Require(scope)(handler)
+	}
+
+	r.Handle("/", scoped(auth.ScopeShorten, urlController.ShortenURL)).Methods("POST")
 	r.HandleFunc("/{id}", urlController.RedirectURL).Methods("GET")
-	r.HandleFunc("/api/shorten", urlController.ShortenURLJSON).Methods("POST")
+	r.HandleFunc("/{id}", urlController.UnlockURL).Methods("POST")
+	r.Handle("/api/shorten", scoped(auth.ScopeShorten, urlController.ShortenURLJSON)).Methods("POST")
+	r.Handle("/api/shorten/batch", scoped(auth.ScopeShorten, urlController.ShortenURLBatch)).Methods("POST")
+	r.Handle("/api/user/urls", scoped(auth.ScopeRead, urlController.GetUserURLs)).Methods("GET")
+	r.Handle("/api/user/urls", scoped(auth.ScopeDelete, urlController.DeleteUserURLs)).Methods("DELETE")
+	r.Handle("/api/admin/keys", scoped(auth.ScopeAdmin, keyController.CreateAPIKey)).Methods("POST")
+	r.Handle("/api/admin/keys", scoped(auth.ScopeAdmin, keyController.ListAPIKeys)).Methods("GET")
+	r.Handle("/api/admin/keys/{id}", scoped(auth.ScopeAdmin, keyController.RevokeAPIKey)).Methods("DELETE")
 
 	componentLogger.Info("Starting server", zap.String("address", cfg.Address))
 
-	err := http.ListenAndServe(cfg.Address, r)
+	server := &http.Server{
+		Addr:              cfg.Address,
+		Handler:           r,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
 
-	if err != nil {
+	select {
+	case err := <-serveErr:
 		componentLogger.Error("Error on serve", zap.Error(err))
 		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	componentLogger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		componentLogger.Error("Error on shutdown", zap.Error(err))
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
 	}
 
 	return nil
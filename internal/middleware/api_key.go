@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/auth"
+	"linkshrink/internal/utils/logger"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKeyPolicyAllow  = "allow"  // Запросы без ключа выполняются от имени пользователя из cookie
+	APIKeyPolicyReject = "reject" // Запросы без ключа отклоняются
+
+	bearerPrefix = "Bearer "
+)
+
+var (
+	// ErrUnknownAPIKeyPolicy возвращается для неизвестной политики запросов без API-ключа.
+	ErrUnknownAPIKeyPolicy = errors.New("unknown API key policy")
+
+	// errUnsupportedScheme - заголовок Authorization с другой схемой, чем Bearer.
+	errUnsupportedScheme = errors.New("unsupported authorization scheme")
+)
+
+// keyErrorKey - ключ контекста запроса, под которым хранится ошибка проверки его API-ключа.
+type keyErrorKey struct{}
+
+// IAPIKeyAuthenticator проверяет API-ключи. Для недействительного ключа возвращает auth.ErrInvalidAPIKey.
+type IAPIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Identity, error)
+}
+
+// KeyAuth проверяет API-ключи запросов и права на действия.
+type KeyAuth struct {
+	keys   IAPIKeyAuthenticator
+	reject bool // Отклонять запросы без ключа
+	logger logger.Logger
+}
+
+// NewKeyAuth создает проверку API-ключей с политикой policy для запросов без ключа.
+func NewKeyAuth(keys IAPIKeyAuthenticator, policy string, log logger.Logger) (*KeyAuth, error) {
+	if policy != APIKeyPolicyAllow && policy != APIKeyPolicyReject {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAPIKeyPolicy, policy)
+	}
+	return &KeyAuth{
+		keys:   keys,
+		reject: policy == APIKeyPolicyReject,
+		logger: log.With(zap.String("component", "KeyAuth")),
+	}, nil
+}
+
+// Middleware проверяет API-ключ из заголовка Authorization: Bearer и кладет в контекст запроса
+// владельца ключа и его права. Запрос без заголовка или с ключом, который не удалось проверить,
+// передается дальше, и пользователь определяется по cookie (см. AuthMiddleware). Ошибка проверки
+// сохраняется в контексте: маршруты, требующие прав (см. Require), отклоняют такой запрос,
+// а переход по короткой ссылке ключа не требует и выполняется как без заголовка.
+func (a *KeyAuth) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, bearerPrefix)
+			if !ok {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyErrorKey{}, errUnsupportedScheme)))
+				return
+			}
+			identity, err := a.keys.Authenticate(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidAPIKey) {
+					a.logger.Error("Error checking API key", zap.Error(err))
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyErrorKey{}, err)))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// Require пропускает запрос, если у его API-ключа есть право scope. Запрос без ключа
+// пропускается, только если это разрешает политика; управление ключами без ключа недоступно.
+// Запрос с недействительным ключом отклоняется с 401, даже если политика разрешает запросы
+// без ключа: клиент явно пытался авторизоваться.
+func (a *KeyAuth) Require(scope auth.Scope) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err, ok := r.Context().Value(keyErrorKey{}).(error); ok {
+				switch {
+				case errors.Is(err, errUnsupportedScheme):
+					unauthorized(w, "Unsupported authorization scheme")
+				case errors.Is(err, auth.ErrInvalidAPIKey):
+					unauthorized(w, "Invalid API key")
+				default:
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			identity, _ := auth.FromContext(r.Context())
+			switch {
+			case identity.ByAPIKey() && !identity.Allows(scope):
+				http.Error(w, fmt.Sprintf("API key has no %q scope", scope), http.StatusForbidden)
+				return
+			case !identity.ByAPIKey() && (a.reject || scope == auth.ScopeAdmin):
+				unauthorized(w, "API key required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unauthorized отвечает 401 с указанием схемы авторизации.
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"linkshrink/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeKeys принимает ключи из карты токен -> пользователь.
+type fakeKeys map[string]auth.Identity
+
+func (k fakeKeys) Authenticate(_ context.Context, token string) (auth.Identity, error) {
+	if token == "broken" {
+		return auth.Identity{}, errors.New("storage is down")
+	}
+	identity, ok := k[token]
+	if !ok {
+		return auth.Identity{}, auth.ErrInvalidAPIKey
+	}
+	return identity, nil
+}
+
+func TestKeyAuth(t *testing.T) {
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("k"), auth.MinSecretLength))
+	require.NoError(t, err)
+	keys := fakeKeys{
+		"reader": {UserID: "alice", KeyID: "k1", Scopes: []auth.Scope{auth.ScopeRead}},
+		"admin":  {KeyID: "k2", Scopes: []auth.Scope{auth.ScopeAdmin}},
+	}
+
+	tests := []struct {
+		name          string
+		policy        string
+		scope         auth.Scope
+		authorization string
+		expectedCode  int
+		expectedUser  string
+	}{
+		{name: "key with scope", policy: APIKeyPolicyReject, scope: auth.ScopeRead, authorization: "Bearer reader",
+			expectedCode: http.StatusOK, expectedUser: "alice"},
+		{name: "key without scope", policy: APIKeyPolicyAllow, scope: auth.ScopeDelete,
+			authorization: "Bearer reader", expectedCode: http.StatusForbidden},
+		{name: "admin key", policy: APIKeyPolicyAllow, scope: auth.ScopeAdmin, authorization: "Bearer admin",
+			expectedCode: http.StatusOK},
+		{name: "invalid key", policy: APIKeyPolicyAllow, scope: auth.ScopeRead, authorization: "Bearer stolen",
+			expectedCode: http.StatusUnauthorized},
+		{name: "other scheme", policy: APIKeyPolicyAllow, scope: auth.ScopeRead, authorization: "Basic cmVhZGVy",
+			expectedCode: http.StatusUnauthorized},
+		{name: "storage error", policy: APIKeyPolicyAllow, scope: auth.ScopeRead, authorization: "Bearer broken",
+			expectedCode: http.StatusInternalServerError},
+		{name: "no key allowed", policy: APIKeyPolicyAllow, scope: auth.ScopeDelete, expectedCode: http.StatusOK},
+		{name: "no key rejected", policy: APIKeyPolicyReject, scope: auth.ScopeShorten,
+			expectedCode: http.StatusUnauthorized},
+		{name: "no key admin", policy: APIKeyPolicyAllow, scope: auth.ScopeAdmin,
+			expectedCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyAuth, err := NewKeyAuth(keys, tt.policy, zaptest.NewLogger(t))
+			require.NoError(t, err)
+
+			var got auth.Identity
+			final := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got, _ = auth.FromContext(r.Context())
+			})
+			handler := InitMiddlewares(zaptest.NewLogger(t), signer, keyAuth)(keyAuth.Require(tt.scope)(final))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls", http.NoBody)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			res := rr.Result()
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, tt.expectedCode, res.StatusCode)
+			if tt.expectedCode == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", res.Header.Get("WWW-Authenticate"))
+			}
+			if tt.expectedUser != "" {
+				assert.Equal(t, tt.expectedUser, got.UserID)
+				// Запросу с ключом cookie не выдается.
+				assert.Empty(t, res.Cookies())
+			}
+		})
+	}
+}
+
+// TestKeyAuth_PublicRoute тестирует, что недействительный ключ не мешает переходу по короткой ссылке:
+// маршрут без Require выполняется как запрос без ключа.
+func TestKeyAuth_PublicRoute(t *testing.T) {
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("k"), auth.MinSecretLength))
+	require.NoError(t, err)
+	keyAuth, err := NewKeyAuth(fakeKeys{}, APIKeyPolicyReject, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	handler := InitMiddlewares(zaptest.NewLogger(t), signer, keyAuth)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			identity, _ := auth.FromContext(r.Context())
+			assert.False(t, identity.ByAPIKey())
+			http.Redirect(w, r, "http://example.com", http.StatusTemporaryRedirect)
+		}))
+
+	for _, authorization := range []string{"Bearer stolen", "Basic cmVhZGVy", "Bearer broken"} {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", http.NoBody)
+		req.Header.Set("Authorization", authorization)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		res := rr.Result()
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode, authorization)
+	}
+}
+
+func TestNewKeyAuth_UnknownPolicy(t *testing.T) {
+	_, err := NewKeyAuth(fakeKeys{}, "maybe", zaptest.NewLogger(t))
+	require.ErrorIs(t, err, ErrUnknownAPIKeyPolicy)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"linkshrink/internal/auth"
+	"linkshrink/internal/utils/logger"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// userCookieMaxAge - срок жизни cookie пользователя.
+const userCookieMaxAge = 365 * 24 * time.Hour
+
+// AuthMiddleware определяет пользователя по подписанной cookie и кладет его в контекст запроса.
+// Если cookie нет или ее подпись неверна, пользователю выдается новый ID и новая cookie;
+// во втором случае обработчик может отклонить запрос по Identity.Rejected.
+// Запрос, пользователь которого уже определен по API-ключу, передается дальше без cookie.
+func AuthMiddleware(signer *auth.Signer, log logger.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		componentLogger := log.With(zap.String("component", "AuthMiddleware"))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if identity, ok := auth.FromContext(r.Context()); ok && identity.ByAPIKey() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var identity auth.Identity
+			if cookie, err := r.Cookie(auth.CookieName); err == nil {
+				if identity.UserID, err = signer.Verify(cookie.Value); err != nil {
+					identity.Rejected = true
+				}
+			}
+
+			if identity.UserID == "" {
+				userID, err := auth.NewUserID()
+				if err != nil {
+					componentLogger.Error("Error generating user ID", zap.Error(err))
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				identity.UserID = userID
+				http.SetCookie(w, &http.Cookie{
+					Name:     auth.CookieName,
+					Value:    signer.Sign(userID),
+					Path:     "/",
+					MaxAge:   int(userCookieMaxAge.Seconds()),
+					HttpOnly: true,
+					Secure:   r.TLS != nil,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"linkshrink/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("k"), auth.MinSecretLength))
+	require.NoError(t, err)
+
+	var got auth.Identity
+	handler := AuthMiddleware(signer, zaptest.NewLogger(t))(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var ok bool
+		got, ok = auth.FromContext(r.Context())
+		require.True(t, ok)
+	}))
+	serve := func(cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// Без cookie выдается новый пользователь.
+	res := serve(nil)
+	require.NoError(t, res.Body.Close())
+	require.Len(t, res.Cookies(), 1)
+	issued := res.Cookies()[0]
+	assert.Equal(t, auth.CookieName, issued.Name)
+	assert.True(t, issued.HttpOnly)
+	assert.NotEmpty(t, got.UserID)
+	assert.False(t, got.Rejected)
+	userID := got.UserID
+
+	// С действующей cookie пользователь сохраняется, новая cookie не выдается.
+	res = serve(&http.Cookie{Name: auth.CookieName, Value: issued.Value})
+	require.NoError(t, res.Body.Close())
+	assert.Empty(t, res.Cookies())
+	assert.Equal(t, auth.Identity{UserID: userID}, got)
+
+	// Поддельная cookie отклоняется, и выдается новый пользователь.
+	res = serve(&http.Cookie{Name: auth.CookieName, Value: "intruder" + issued.Value[len(userID):]})
+	require.NoError(t, res.Body.Close())
+	require.Len(t, res.Cookies(), 1)
+	assert.True(t, got.Rejected)
+	assert.NotEqual(t, userID, got.UserID)
+	assert.NotEqual(t, "intruder", got.UserID)
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"linkshrink/internal/auth"
 	"linkshrink/internal/utils/logger"
 	"net/http"
 	"strings"
@@ -14,8 +15,13 @@ import (
 	"go.uber.org/zap"
 )
 
-func InitMiddlewares(log logger.Logger) func(http.Handler) http.Handler {
+// InitMiddlewares собирает цепочку middleware сервера. Пользователь определяется первым - по API-ключу,
+// а без ключа по cookie, - чтобы ключ проверялся и cookie выдавалась один раз, даже если следующие
+// middleware повторяют обработку запроса.
+func InitMiddlewares(log logger.Logger, signer *auth.Signer, keyAuth *KeyAuth) func(http.Handler) http.Handler {
 	return chain(
+		keyAuth.Middleware(),
+		AuthMiddleware(signer, log),
 		GzipRequestMiddleware(log),
 		GzipResponseMiddleware(log),
 		loggingMiddleware(log),
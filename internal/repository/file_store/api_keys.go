@@ -0,0 +1,149 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"linkshrink/internal/repository"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// apiKeysSuffix - суффикс файла API-ключей. Ключей немного и меняются они редко, поэтому
+// они хранятся отдельно от журнала ссылок и файл целиком переписывается при каждом изменении.
+const apiKeysSuffix = ".keys"
+
+func (r *FileStore) apiKeysPath() string {
+	return r.filePath + apiKeysSuffix
+}
+
+// loadAPIKeys загружает API-ключи. Отсутствие файла означает, что ключей еще нет.
+func (r *FileStore) loadAPIKeys() error {
+	data, err := os.ReadFile(r.apiKeysPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("не удалось прочитать файл API-ключей: " + err.Error())
+	}
+
+	var keys []repository.APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return errors.New("не удалось разобрать файл API-ключей: " + err.Error())
+	}
+	for _, key := range keys {
+		r.memory.PutAPIKey(key)
+	}
+	return nil
+}
+
+// SaveAPIKey сохраняет новый API-ключ: сначала на диск, затем в память.
+func (r *FileStore) SaveAPIKey(ctx context.Context, key repository.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.memory.GetAPIKey(ctx, key.ID)
+	if err == nil {
+		return repository.ErrIDAlreadyExists
+	}
+	if !errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return errors.New("не удалось проверить API-ключ: " + err.Error())
+	}
+	return r.putAPIKey(ctx, key)
+}
+
+// GetAPIKey ищет API-ключ по ID.
+func (r *FileStore) GetAPIKey(ctx context.Context, id string) (repository.APIKey, error) {
+	key, err := r.memory.GetAPIKey(ctx, id)
+	if err != nil {
+		return repository.APIKey{}, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке создания.
+func (r *FileStore) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	keys, err := r.memory.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ id в момент now.
+func (r *FileStore) RevokeAPIKey(ctx context.Context, id string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, err := r.memory.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.Revoked() {
+		return nil
+	}
+	key.RevokedAt = &now
+	return r.putAPIKey(ctx, key)
+}
+
+// putAPIKey переписывает файл ключей с ключом key и после успешной записи обновляет память,
+// чтобы ключ, не сохраненный на диск, не начал действовать. Вызывается под r.mu.
+func (r *FileStore) putAPIKey(ctx context.Context, key repository.APIKey) error {
+	keys, err := r.memory.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range keys {
+		if keys[i].ID == key.ID {
+			keys[i], replaced = key, true
+		}
+	}
+	if !replaced {
+		keys = append(keys, key)
+	}
+
+	if err := r.writeAPIKeys(keys); err != nil {
+		return err
+	}
+	r.memory.PutAPIKey(key)
+	return nil
+}
+
+// writeAPIKeys атомарно заменяет файл API-ключей.
+func (r *FileStore) writeAPIKeys(keys []repository.APIKey) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return errors.New("не удалось сериализовать API-ключи: " + err.Error())
+	}
+
+	dir := filepath.Dir(r.apiKeysPath())
+	tmp, err := os.CreateTemp(dir, filepath.Base(r.apiKeysPath())+".tmp-*")
+	if err != nil {
+		return errors.New("не удалось создать временный файл: " + err.Error())
+	}
+	defer func() {
+		// После успешного переименования файла уже нет, ошибку игнорируем.
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.New("не удалось записать API-ключи: " + err.Error())
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errors.New("не удалось сбросить API-ключи на диск: " + err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.New("не удалось закрыть файл API-ключей: " + err.Error())
+	}
+	if err := os.Chmod(tmp.Name(), filePermission); err != nil {
+		return errors.New("не удалось изменить права файла API-ключей: " + err.Error())
+	}
+	if err := os.Rename(tmp.Name(), r.apiKeysPath()); err != nil {
+		return errors.New("не удалось заменить файл API-ключей: " + err.Error())
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,282 @@
+package filestore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotSuffix   = ".snapshot"   // Суффикс файла снимка
+	compactingSuffix = ".compacting" // Суффикс журнала, который сворачивается в снимок
+	backupSuffix     = ".bak"        // Суффикс резервных копий предыдущего снимка и свернутого журнала
+)
+
+// CompactionConfig - настройки компактизации журнала.
+// Нулевое значение порога отключает соответствующий триггер.
+type CompactionConfig struct {
+	Interval          time.Duration // Периодичность плановой компактизации
+	MaxJournalSize    int64         // Размер журнала в байтах, после которого запускается компактизация
+	MaxJournalRecords int           // Количество записей в журнале, после которого запускается компактизация
+}
+
+// DefaultCompactionConfig возвращает настройки компактизации по умолчанию.
+func DefaultCompactionConfig() CompactionConfig {
+	return CompactionConfig{
+		Interval:          config.DefaultCompactionInterval,
+		MaxJournalSize:    config.DefaultMaxJournalSize,
+		MaxJournalRecords: config.DefaultMaxJournalRecords,
+	}
+}
+
+func (c CompactionConfig) exceeded(records int, size int64) bool {
+	return (c.MaxJournalRecords > 0 && records >= c.MaxJournalRecords) ||
+		(c.MaxJournalSize > 0 && size >= c.MaxJournalSize)
+}
+
+// CompactionStatus - результат последней компактизации.
+type CompactionStatus struct {
+	Runs     int           // Количество выполненных компактизаций
+	LastRun  time.Time     // Время начала последней компактизации
+	Duration time.Duration // Длительность последней компактизации
+	Records  int           // Количество записей в снимке
+	Err      error         // Ошибка последней компактизации
+}
+
+// CompactionStatus возвращает результат последней компактизации.
+func (r *FileStore) CompactionStatus() CompactionStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+func (r *FileStore) snapshotPath() string {
+	return r.filePath + snapshotSuffix
+}
+
+func (r *FileStore) compactingPath() string {
+	return r.filePath + compactingSuffix
+}
+
+func (r *FileStore) snapshotBackupPath() string {
+	return r.snapshotPath() + backupSuffix
+}
+
+func (r *FileStore) journalBackupPath() string {
+	return r.compactingPath() + backupSuffix
+}
+
+// triggerCompaction сигнализирует фоновой горутине о необходимости компактизации, не блокируясь.
+func (r *FileStore) triggerCompaction() {
+	select {
+	case r.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+// runCompactor запускает компактизацию по таймеру и по превышению порогов журнала.
+func (r *FileStore) runCompactor() {
+	defer r.wg.Done()
+
+	var tick <-chan time.Time
+	if r.compaction.Interval > 0 {
+		ticker := time.NewTicker(r.compaction.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-tick:
+		case <-r.compactCh:
+		}
+
+		if err := r.Compact(); err != nil {
+			r.logger.Error("Ошибка компактизации журнала", zap.Error(err))
+		}
+	}
+}
+
+// Compact сворачивает журнал в снимок.
+// Под блокировкой выполняется только копирование данных и ротация журнала,
+// поэтому Save и Find не ждут записи снимка на диск.
+// Снимок записывается во временный файл, сбрасывается на диск и атомарно
+// переименовывается. Предыдущий снимок и свернутый журнал сохраняются как
+// резервная копия: вместе они содержат те же данные, что и новый снимок.
+func (r *FileStore) Compact() error {
+	r.compactMu.Lock()
+	defer r.compactMu.Unlock()
+
+	start := time.Now()
+
+	r.mu.Lock()
+	urls := make([]repository.URLData, 0, len(r.memory.Store))
+	for _, url := range r.memory.Store {
+		urls = append(urls, url)
+	}
+	err := r.rotateJournal()
+	r.mu.Unlock()
+
+	if err == nil {
+		err = r.writeSnapshot(urls)
+	}
+	if err == nil {
+		err = r.backupCompacted()
+	}
+
+	r.mu.Lock()
+	r.status = CompactionStatus{
+		Runs:     r.status.Runs + 1,
+		LastRun:  start,
+		Duration: time.Since(start),
+		Records:  len(urls),
+		Err:      err,
+	}
+	r.mu.Unlock()
+
+	if err == nil {
+		r.logger.Info("Журнал свернут в снимок",
+			zap.Int("records", len(urls)), zap.Duration("duration", time.Since(start)))
+	}
+	return err
+}
+
+// backupCompacted сохраняет свернутый журнал как резервную копию.
+func (r *FileStore) backupCompacted() error {
+	if err := os.Rename(r.compactingPath(), r.journalBackupPath()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Журнал был пуст: резервный снимок уже содержит все данные.
+			return r.removeFile(r.journalBackupPath())
+		}
+		return errors.New("не удалось сохранить свернутый журнал: " + err.Error())
+	}
+	return syncDir(filepath.Dir(r.filePath))
+}
+
+func (r *FileStore) removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("не удалось удалить файл: " + err.Error())
+	}
+	return nil
+}
+
+// rotateJournal переименовывает текущий журнал для последующего сворачивания, новые записи
+// пойдут в новый журнал. Если журнал от прошлой неудачной компактизации еще не удален,
+// ротация пропускается: иначе его записи могли бы пропасть до записи нового снимка.
+// Вызывается под блокировкой mu.
+func (r *FileStore) rotateJournal() error {
+	if _, err := os.Stat(r.compactingPath()); err == nil {
+		return nil
+	}
+
+	if err := r.closeJournal(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.filePath, r.compactingPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("не удалось переименовать журнал: " + err.Error())
+	}
+
+	r.journalRecords = 0
+	r.journalSize = 0
+	return nil
+}
+
+// writeSnapshot атомарно заменяет файл снимка.
+func (r *FileStore) writeSnapshot(urls []repository.URLData) error {
+	tmp, err := writeTemp(r.snapshotPath(), urls)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// После успешного переименования файла уже нет, ошибку игнорируем.
+		_ = os.Remove(tmp)
+	}()
+
+	// Текущий снимок становится резервной копией. Жесткая ссылка вместо переименования
+	// гарантирует, что корректный снимок существует в любой момент.
+	if err := r.removeFile(r.snapshotBackupPath()); err != nil {
+		return err
+	}
+	if err := os.Link(r.snapshotPath(), r.snapshotBackupPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("не удалось создать резервную копию снимка: " + err.Error())
+	}
+
+	if err := os.Rename(tmp, r.snapshotPath()); err != nil {
+		return errors.New("не удалось заменить снимок: " + err.Error())
+	}
+	return syncDir(filepath.Dir(r.filePath))
+}
+
+// replaceFile атомарно заменяет файл path записями urls в формате журнала.
+func replaceFile(path string, urls []repository.URLData) error {
+	tmp, err := writeTemp(path, urls)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.New("не удалось заменить файл: " + err.Error())
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeTemp записывает urls во временный файл рядом с path, сбрасывает его на диск и возвращает его имя.
+func writeTemp(path string, urls []repository.URLData) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", errors.New("не удалось создать временный файл: " + err.Error())
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, url := range urls {
+		if err = encoder.Encode(url); err != nil {
+			err = errors.New("не удалось сериализовать данные: " + err.Error())
+			break
+		}
+	}
+	if err == nil {
+		if flushErr := writer.Flush(); flushErr != nil {
+			err = errors.New("не удалось записать файл: " + flushErr.Error())
+		}
+	}
+	if err == nil {
+		if syncErr := tmp.Sync(); syncErr != nil {
+			err = errors.New("не удалось сбросить файл на диск: " + syncErr.Error())
+		}
+	}
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = errors.New("не удалось закрыть файл: " + closeErr.Error())
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// syncDir сбрасывает на диск изменения каталога, чтобы переименование пережило падение.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.New("не удалось открыть каталог: " + err.Error())
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+
+	if err := d.Sync(); err != nil {
+		return errors.New("не удалось сбросить каталог на диск: " + err.Error())
+	}
+	return nil
+}
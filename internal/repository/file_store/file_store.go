@@ -1,57 +1,197 @@
 package filestore
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
 	memorystore "linkshrink/internal/repository/memory_store"
 	"linkshrink/internal/utils/logger"
 	"os"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-type URLData struct {
-	UUID        string `json:"uuid"`
-	OriginalURL string `json:"original_url"`
+func init() {
+	repository.Register("file", func(cfg *config.Config, log logger.Logger) (repository.IURLRepository, error) {
+		compaction := CompactionConfig{
+			Interval:          cfg.File.CompactionInterval,
+			MaxJournalSize:    cfg.File.MaxJournalSize,
+			MaxJournalRecords: cfg.File.MaxJournalRecords,
+		}
+
+		store, err := NewFileStoreWithConfig(cfg.File.Path, compaction, log)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	})
 }
 
 type IFileStore interface {
-	Save(id string, originalURL string) error
-	Find(id string) (string, error)
+	Save(ctx context.Context, id string, originalURL string) error
+	SaveURL(ctx context.Context, url repository.URLData) error
+	SaveBatch(ctx context.Context, urls []repository.URLData) error
+	Find(ctx context.Context, id string) (string, error)
+	Get(ctx context.Context, id string) (repository.URLData, error)
+	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+	DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error)
+	Click(ctx context.Context, id string, now time.Time) (repository.URLData, error)
+	ReserveIDs(ctx context.Context, ids []string) ([]string, error)
+	ReleaseIDs(ctx context.Context, ids []string) error
+	SaveAPIKey(ctx context.Context, key repository.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (repository.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]repository.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, now time.Time) error
 	LoadFromFile() error
 	SaveToFile() error
+	Close() error
 }
 
+const filePermission = 0o600 // Read and write for owner only
+
+// FileStore хранит данные в памяти и ведет журнал изменений в файле:
+// каждая запись URLData или отметка об ее удалении дописывается в конец файла отдельной строкой.
+// В фоне журнал периодически сворачивается в снимок (см. Compact).
+// API-ключи хранятся в отдельном файле (см. apiKeysSuffix).
 type FileStore struct {
 	memory   memorystore.MemoryStore // Встраивание MemoryStore
 	mu       *sync.Mutex             // Мьютекс для обеспечения потокобезопасности
 	logger   logger.Logger
 	filePath string
+	journal  *os.File // Файл журнала, открытый на дозапись
+
+	compaction     CompactionConfig
+	compactMu      sync.Mutex       // Исключает одновременный запуск нескольких компактизаций
+	compactCh      chan struct{}    // Сигнал о превышении порогов журнала
+	done           chan struct{}    // Закрывается при остановке хранилища
+	wg             sync.WaitGroup   // Ожидание фоновой горутины компактизации
+	status         CompactionStatus // Результат последней компактизации, защищен mu
+	journalRecords int              // Количество записей в текущем журнале, защищено mu
+	journalSize    int64            // Размер текущего журнала в байтах, защищен mu
 }
 
-func NewFileStore(filePath string, log logger.Logger) *FileStore {
+// NewFileStore создает файловое хранилище с настройками компактизации по умолчанию.
+func NewFileStore(filePath string, log logger.Logger) (*FileStore, error) {
+	return NewFileStoreWithConfig(filePath, DefaultCompactionConfig(), log)
+}
+
+// NewFileStoreWithConfig создает файловое хранилище и запускает фоновую компактизацию журнала.
+// Если сохраненные данные не удается восстановить, возвращается ошибка: запуск с пустым
+// хранилищем привел бы к потере ссылок при следующей компактизации.
+func NewFileStoreWithConfig(
+	filePath string,
+	compaction CompactionConfig,
+	log logger.Logger,
+) (*FileStore, error) {
 	componentLogger := log.With(zap.String("component", "FileStore"))
 	repo := &FileStore{
-		memory:   *memorystore.NewMemoryStore(log),
-		filePath: filePath,
-		mu:       &sync.Mutex{},
-		logger:   componentLogger,
+		memory:     *memorystore.NewMemoryStore(log),
+		filePath:   filePath,
+		mu:         &sync.Mutex{},
+		logger:     componentLogger,
+		compaction: compaction,
+		compactCh:  make(chan struct{}, 1),
+		done:       make(chan struct{}),
 	}
 
 	if err := repo.LoadFromFile(); err != nil {
 		componentLogger.Error("Ошибка при загрузке из файла", zap.Error(err))
+		return nil, fmt.Errorf("не удалось загрузить данные из файла %s: %w", filePath, err)
 	}
 
-	return repo
+	repo.wg.Add(1)
+	go repo.runCompactor()
+
+	return repo, nil
 }
 
-// LoadFromFile загружает данные из файла в репозиторий.
+// ErrNoValidData возвращается, если ни снимок, ни его резервная копия не могут быть загружены.
+var ErrNoValidData = errors.New("no valid data found in file storage")
+
+// replayMode определяет, как обрабатывается оборванная последняя запись файла.
+type replayMode int
+
+const (
+	replayStrict   replayMode = iota // Любая поврежденная запись - ошибка (снимки пишутся атомарно)
+	replayTolerant                   // Оборванная последняя запись отбрасывается
+	replayRepair                     // Оборванная последняя запись отбрасывается и удаляется из файла
+)
+
+// LoadFromFile восстанавливает данные репозитория: загружает снимок и проигрывает журналы.
+// Если снимок поврежден, данные восстанавливаются из резервной копии снимка и свернутого в него журнала.
+// Оборванная последняя строка журнала (например, после падения во время записи) отбрасывается.
+// Файл в старом формате (JSON-массив) загружается и переписывается в формат журнала.
 func (r *FileStore) LoadFromFile() error {
-	file, err := os.Open(r.filePath)
+	if err := r.loadSnapshot(); err != nil {
+		r.logger.Error("Не удалось загрузить снимок, восстанавливаем из резервной копии", zap.Error(err))
+		r.memory.Reset()
+		if backupErr := r.loadBackup(); backupErr != nil {
+			return fmt.Errorf("%w: %s: %w", ErrNoValidData, err.Error(), backupErr)
+		}
+		r.logger.Info("Данные восстановлены из резервной копии снимка")
+	}
+
+	// Журнал, оставшийся от прерванной компактизации.
+	if _, err := r.replayFile(r.compactingPath(), replayTolerant); err != nil {
+		return err
+	}
+
+	records, err := r.replayFile(r.filePath, replayRepair)
+	if err != nil {
+		return err
+	}
+	r.journalRecords = records
+	if info, err := os.Stat(r.filePath); err == nil {
+		r.journalSize = info.Size()
+	}
+	return r.loadAPIKeys()
+}
+
+// loadSnapshot загружает основной снимок. Отсутствие снимка при наличии
+// резервной копии означает, что снимок был утерян.
+func (r *FileStore) loadSnapshot() error {
+	if _, err := os.Stat(r.snapshotPath()); errors.Is(err, os.ErrNotExist) {
+		if _, bakErr := os.Stat(r.snapshotBackupPath()); bakErr == nil {
+			return errors.New("снимок отсутствует, но найдена резервная копия")
+		}
+		return nil
+	}
+
+	_, err := r.replayFile(r.snapshotPath(), replayStrict)
+	return err
+}
+
+// loadBackup загружает предыдущий снимок и журнал, который был свернут в текущий снимок.
+func (r *FileStore) loadBackup() error {
+	_, snapshotErr := os.Stat(r.snapshotBackupPath())
+	_, journalErr := os.Stat(r.journalBackupPath())
+	if errors.Is(snapshotErr, os.ErrNotExist) && errors.Is(journalErr, os.ErrNotExist) {
+		return errors.New("резервная копия отсутствует")
+	}
+
+	if _, err := r.replayFile(r.snapshotBackupPath(), replayStrict); err != nil {
+		return err
+	}
+	_, err := r.replayFile(r.journalBackupPath(), replayTolerant)
+	return err
+}
+
+// replayFile загружает записи из файла журнала в память и возвращает их количество.
+func (r *FileStore) replayFile(path string, mode replayMode) (int, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return errors.New("не удалось открыть файл: " + err.Error())
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, errors.New("не удалось открыть файл: " + err.Error())
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
@@ -59,61 +199,398 @@ func (r *FileStore) LoadFromFile() error {
 		}
 	}()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return errors.New("не удалось прочитать файл: " + err.Error())
+	reader := bufio.NewReader(file)
+	if isLegacyFormat(reader) {
+		return r.loadLegacy(path, reader)
 	}
 
-	var urls []URLData
-	if err := json.Unmarshal(data, &urls); err != nil {
-		return errors.New("не удалось декодировать файл: " + err.Error())
+	var (
+		offset  int64 // Смещение конца последней корректной записи
+		lineNum int
+		records int
+	)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return records, errors.New("не удалось прочитать файл: " + readErr.Error())
+		}
+		if len(line) == 0 {
+			break
+		}
+		lineNum++
+
+		complete := line[len(line)-1] == '\n'
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record journalRecord
+			if err := json.Unmarshal(trimmed, &record); err != nil {
+				if complete || mode == replayStrict {
+					return records, fmt.Errorf("не удалось декодировать строку %d файла %s: %w", lineNum, path, err)
+				}
+				r.logger.Info("Отброшена оборванная запись в конце журнала",
+					zap.String("file", path), zap.Int("line", lineNum))
+				if mode != replayRepair {
+					return records, nil
+				}
+				return records, r.truncateJournal(offset)
+			}
+			if record.Removed {
+				r.memory.Remove(record.UUID)
+			} else {
+				r.memory.Put(upgrade(record.URLData))
+			}
+			records++
+		}
+
+		if !complete {
+			if mode != replayRepair {
+				return records, nil
+			}
+			// Последняя запись корректна, но не завершена переводом строки:
+			// дописываем его, чтобы следующая запись начиналась с новой строки.
+			return records, r.appendRecords([]byte{'\n'})
+		}
+		offset += int64(len(line))
 	}
 
-	for _, url := range urls {
-		r.memory.Store[url.UUID] = url.OriginalURL
+	return records, nil
+}
+
+// journalRecord - строка журнала: запись URLData либо отметка об удалении записи с ID UUID.
+// Более поздняя строка с тем же ID заменяет или удаляет прежнюю.
+type journalRecord struct {
+	repository.URLData
+	Removed bool `json:"removed,omitempty"`
+}
+
+// upgrade приводит запись, сохраненную прежней версией сервиса, к текущим правилам:
+// ссылки со сроком действия, лимитом переходов и паролем, а также удаленные не участвуют в дедупликации.
+func upgrade(url repository.URLData) repository.URLData {
+	if url.ExpiresAt != nil || url.MaxClicks > 0 || url.PasswordHash != "" || url.Deleted {
+		url.Exclusive = true
 	}
+	return url
+}
 
-	return nil
+// isLegacyFormat проверяет, записан ли файл в старом формате - одним JSON-массивом.
+func isLegacyFormat(reader *bufio.Reader) bool {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			_ = reader.UnreadByte()
+			return b == '['
+		}
+	}
 }
 
-// SaveToFile сохраняет данные репозитория в файл.
-func (r *FileStore) SaveToFile() error {
-	const initialCapacity = 1000
-	urls := make([]URLData, 0, initialCapacity)
-	for id, originalURL := range r.memory.Store {
-		urls = append(urls, URLData{
-			UUID:        id,
-			OriginalURL: originalURL,
-		})
+// loadLegacy загружает файл в формате JSON-массива и переписывает его на месте в формат журнала:
+// записи сохраняются во временный файл, который атомарно заменяет исходный.
+func (r *FileStore) loadLegacy(path string, reader io.Reader) (int, error) {
+	var urls []repository.URLData
+	if err := json.NewDecoder(reader).Decode(&urls); err != nil {
+		return 0, errors.New("не удалось декодировать файл: " + err.Error())
 	}
 
-	data, err := json.Marshal(urls)
-	if err != nil {
-		return errors.New("не удалось сериализовать данные: " + err.Error())
+	for i, url := range urls {
+		urls[i] = upgrade(url)
+		r.memory.Put(urls[i])
 	}
 
-	const filePermission = 0o600 // Read and write for owner only
-	if err := os.WriteFile(r.filePath, data, filePermission); err != nil {
-		return errors.New("не удалось записать файл: " + err.Error())
+	if err := replaceFile(path, urls); err != nil {
+		return 0, err
+	}
+	r.logger.Info("Файл хранилища преобразован в формат журнала",
+		zap.String("file", path), zap.Int("records", len(urls)))
+	return len(urls), nil
+}
+
+// truncateJournal обрезает журнал до последней корректной записи.
+func (r *FileStore) truncateJournal(size int64) error {
+	if err := os.Truncate(r.filePath, size); err != nil {
+		return errors.New("не удалось обрезать журнал: " + err.Error())
 	}
 	return nil
 }
 
-// Save сохраняет оригинальный URL по ID и затем сохраняет в файл.
-func (r *FileStore) Save(id string, originalURL string) error {
+// SaveToFile сохраняет снимок данных репозитория в файл и очищает журнал.
+func (r *FileStore) SaveToFile() error {
+	return r.Compact()
+}
+
+// Save сохраняет оригинальный URL по ID и дописывает запись в журнал.
+func (r *FileStore) Save(ctx context.Context, id string, originalURL string) error {
+	return r.SaveURL(ctx, repository.URLData{UUID: id, OriginalURL: originalURL})
+}
+
+// SaveURL сохраняет запись со всеми ее полями и дописывает ее в журнал.
+func (r *FileStore) SaveURL(ctx context.Context, url repository.URLData) error {
+	return r.SaveBatch(ctx, []repository.URLData{url})
+}
+
+// SaveBatch сохраняет пакет записей целиком или не сохраняет ничего.
+// Все записи пакета дописываются в журнал одной операцией записи.
+func (r *FileStore) SaveBatch(ctx context.Context, urls []repository.URLData) error {
 	r.mu.Lock() // Блокируем мьютекс
 	defer r.mu.Unlock()
 
-	if err := r.memory.Save(id, originalURL); err != nil {
-		return errors.New("не удалось сохранить в файл: " + err.Error())
+	// Ожидание блокировки могло занять время: не пишем в журнал, если запрос уже отменен.
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("сохранение отменено: %w", err)
+	}
+
+	// Проверяем ID до записи в журнал, чтобы в нем не появлялись дубликаты.
+	if err := r.memory.CheckBatch(urls); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, url := range urls {
+		if err := encoder.Encode(url); err != nil {
+			return errors.New("не удалось сериализовать данные: " + err.Error())
+		}
+	}
+
+	if err := r.appendRecords(buf.Bytes()); err != nil {
+		return err
 	}
-	return r.SaveToFile() // Сохраняем в файл после сохранения в память
+
+	if err := r.memory.SaveBatch(ctx, urls); err != nil {
+		return fmt.Errorf("не удалось сохранить в память: %w", err)
+	}
+
+	r.journalRecords += len(urls)
+	r.journalSize += int64(buf.Len())
+	if r.compaction.exceeded(r.journalRecords, r.journalSize) {
+		r.triggerCompaction()
+	}
+	return nil
 }
 
-func (r *FileStore) Find(id string) (string, error) {
-	originalURL, err := r.memory.Find(id)
+// appendRecords дописывает строки записей в конец журнала, открывая его при необходимости,
+// и сбрасывает журнал на диск.
+func (r *FileStore) appendRecords(records []byte) error {
+	if r.journal == nil {
+		file, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePermission)
+		if err != nil {
+			return errors.New("не удалось открыть журнал: " + err.Error())
+		}
+		r.journal = file
+	}
+
+	// Запись одним вызовом Write, чтобы строки не перемешивались с другими.
+	if _, err := r.journal.Write(records); err != nil {
+		return errors.New("не удалось записать в журнал: " + err.Error())
+	}
+	// Изменение считается сохраненным только после сброса журнала на диск.
+	if err := r.journal.Sync(); err != nil {
+		return errors.New("не удалось сбросить журнал на диск: " + err.Error())
+	}
+	return nil
+}
+
+// closeJournal сбрасывает журнал на диск и закрывает его.
+func (r *FileStore) closeJournal() error {
+	if r.journal == nil {
+		return nil
+	}
+	syncErr := r.journal.Sync()
+	closeErr := r.journal.Close()
+	r.journal = nil
+	if syncErr != nil {
+		return errors.New("не удалось сбросить журнал на диск: " + syncErr.Error())
+	}
+	if closeErr != nil {
+		return errors.New("не удалось закрыть журнал: " + closeErr.Error())
+	}
+	return nil
+}
+
+// Close останавливает фоновую компактизацию и закрывает файл журнала.
+func (r *FileStore) Close() error {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closeJournal()
+}
+
+func (r *FileStore) Find(ctx context.Context, id string) (string, error) {
+	originalURL, err := r.memory.Find(ctx, id)
 	if err != nil {
-		return "", errors.New("URL not found")
+		return "", err
 	}
 	return originalURL, nil
 }
+
+// Get ищет запись по ID.
+func (r *FileStore) Get(ctx context.Context, id string) (repository.URLData, error) {
+	url, err := r.memory.Get(ctx, id)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+	return url, nil
+}
+
+// DeleteExpired удаляет истекшие записи: отметки об удалении дописываются в журнал
+// одной операцией записи, и только после этого записи удаляются из памяти,
+// поэтому удаленные ссылки не восстанавливаются после перезапуска.
+func (r *FileStore) DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("удаление отменено: %w", err)
+	}
+
+	expired := r.memory.Expired(before, ids...)
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, url := range expired {
+		if err := encoder.Encode(journalRecord{URLData: repository.URLData{UUID: url.UUID}, Removed: true}); err != nil {
+			return 0, errors.New("не удалось сериализовать данные: " + err.Error())
+		}
+	}
+	if err := r.appendRecords(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	for _, url := range expired {
+		r.memory.Remove(url.UUID)
+	}
+
+	r.journalRecords += len(expired)
+	r.journalSize += int64(buf.Len())
+	if r.compaction.exceeded(r.journalRecords, r.journalSize) {
+		r.triggerCompaction()
+	}
+	return len(expired), nil
+}
+
+// Click засчитывает переход по ссылке id и дописывает обновленную запись в журнал:
+// при загрузке более поздняя запись с тем же ID заменяет прежнюю, поэтому счетчик переживает перезапуск.
+// Переход засчитывается в памяти только после записи в журнал.
+func (r *FileStore) Click(ctx context.Context, id string, now time.Time) (repository.URLData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, err := r.memory.Get(ctx, id)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+	url, err = url.Click(now)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+
+	record, err := json.Marshal(url)
+	if err != nil {
+		return repository.URLData{}, errors.New("не удалось сериализовать данные: " + err.Error())
+	}
+	record = append(record, '\n')
+	if err := r.appendRecords(record); err != nil {
+		return repository.URLData{}, err
+	}
+	r.memory.Put(url)
+
+	r.journalRecords++
+	r.journalSize += int64(len(record))
+	if r.compaction.exceeded(r.journalRecords, r.journalSize) {
+		r.triggerCompaction()
+	}
+	return url, nil
+}
+
+// DeleteUserURLs помечает удаленными ссылки из links, принадлежащие указанным пользователям,
+// и дописывает обновленные записи в журнал одной операцией записи, как Click.
+func (r *FileStore) DeleteUserURLs(ctx context.Context, links []repository.UserLink) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("удаление отменено: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	deleted := make(map[string]repository.URLData, len(links))
+	for _, link := range links {
+		if _, ok := deleted[link.ID]; ok {
+			continue
+		}
+		url, err := r.memory.Get(ctx, link.ID)
+		if err != nil || !url.Owned(link.UserID) {
+			continue
+		}
+		url = url.Delete()
+		if err := encoder.Encode(url); err != nil {
+			return 0, errors.New("не удалось сериализовать данные: " + err.Error())
+		}
+		deleted[link.ID] = url
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	if err := r.appendRecords(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	for _, url := range deleted {
+		r.memory.Put(url)
+	}
+
+	r.journalRecords += len(deleted)
+	r.journalSize += int64(buf.Len())
+	if r.compaction.exceeded(r.journalRecords, r.journalSize) {
+		r.triggerCompaction()
+	}
+	return len(deleted), nil
+}
+
+// FindByUser возвращает ссылки пользователя userID.
+func (r *FileStore) FindByUser(ctx context.Context, userID string) ([]repository.URLData, error) {
+	urls, err := r.memory.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// FindByOriginalURL ищет ID по оригинальному URL.
+func (r *FileStore) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	id, err := r.memory.FindByOriginalURL(ctx, originalURL)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ReserveIDs резервирует свободные ID для пула ключей.
+// Резерв не записывается в журнал: файл принадлежит одному процессу,
+// а после перезапуска пул резервирует ID заново.
+func (r *FileStore) ReserveIDs(ctx context.Context, ids []string) ([]string, error) {
+	reserved, err := r.memory.ReserveIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// ReleaseIDs снимает резерв с неиспользованных ID.
+func (r *FileStore) ReleaseIDs(ctx context.Context, ids []string) error {
+	return r.memory.ReleaseIDs(ctx, ids)
+}
@@ -0,0 +1,163 @@
+package filestore_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"linkshrink/internal/repository"
+	filestore "linkshrink/internal/repository/file_store"
+	"linkshrink/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFileStore_CompactionByRecords(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+
+	store, err := filestore.NewFileStoreWithConfig(filePath, filestore.CompactionConfig{MaxJournalRecords: 10}, logger)
+	require.NoError(t, err)
+
+	for i := range utils.Intrange(0, 25) {
+		require.NoError(t, store.Save(context.Background(), fmt.Sprintf("id%d", i), fmt.Sprintf("http://url%d.com", i)))
+	}
+
+	// Компактизация выполняется в фоне после превышения порога.
+	require.Eventually(t, func() bool {
+		return store.CompactionStatus().Runs > 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, store.Close())
+
+	status := store.CompactionStatus()
+	require.NoError(t, status.Err)
+	assert.False(t, status.LastRun.IsZero())
+
+	_, err = os.Stat(filePath + ".snapshot")
+	require.NoError(t, err)
+
+	// Снимок и остаток журнала вместе содержат все записи.
+	reloaded, err := filestore.NewFileStore(filePath, logger)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, reloaded.Close())
+	}()
+	for i := range utils.Intrange(0, 25) {
+		originalURL, err := reloaded.Find(context.Background(), fmt.Sprintf("id%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("http://url%d.com", i), originalURL)
+	}
+}
+
+func TestFileStore_CompactTruncatesJournal(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+
+	store, err := filestore.NewFileStoreWithConfig(filePath, filestore.CompactionConfig{}, logger)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), "abc123", "http://original.url"))
+	require.NoError(t, store.Compact())
+
+	// Журнал свернут, новые записи попадают в новый журнал.
+	_, err = os.Stat(filePath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	require.NoError(t, store.Save(context.Background(), "def456", "http://another.url"))
+	require.NoError(t, store.Close())
+
+	journal, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(journal), "def456")
+	assert.NotContains(t, string(journal), "abc123")
+
+	reloaded, err := filestore.NewFileStore(filePath, logger)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, reloaded.Close())
+	}()
+	originalURL, err := reloaded.Find(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://original.url", originalURL)
+}
+
+func TestFileStore_RecoverFromBackup(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+
+	store, err := filestore.NewFileStoreWithConfig(filePath, filestore.CompactionConfig{}, logger)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), "abc123", "http://original.url"))
+	require.NoError(t, store.Compact())
+	require.NoError(t, store.Save(context.Background(), "def456", "http://another.url"))
+	require.NoError(t, store.Compact())
+	require.NoError(t, store.Save(context.Background(), "ghi789", "http://third.url"))
+	require.NoError(t, store.Close())
+
+	// Повреждаем основной снимок.
+	require.NoError(t, os.WriteFile(filePath+".snapshot", []byte(`[{"uuid":"abc`), 0o600))
+
+	reloaded, err := filestore.NewFileStore(filePath, logger)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, reloaded.Close())
+	}()
+	for id, expected := range map[string]string{
+		"abc123": "http://original.url",
+		"def456": "http://another.url",
+		"ghi789": "http://third.url",
+	} {
+		originalURL, err := reloaded.Find(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, expected, originalURL)
+	}
+}
+
+func TestFileStore_RefuseToStartWithoutValidData(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("corrupted legacy file", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "storage.json")
+		require.NoError(t, os.WriteFile(filePath, []byte(`[{"uuid":"abc123","original_url":"http://or`), 0o600))
+
+		_, err := filestore.NewFileStore(filePath, logger)
+		require.Error(t, err)
+	})
+
+	t.Run("corrupted snapshot without backup", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "storage.json")
+		require.NoError(t, os.WriteFile(filePath+".snapshot", []byte("{\"uuid\":\n"), 0o600))
+
+		_, err := filestore.NewFileStore(filePath, logger)
+		require.ErrorIs(t, err, filestore.ErrNoValidData)
+	})
+}
+
+// TestFileStore_UpgradeExpiring тестирует, что ссылки со сроком действия, записанные прежней версией,
+// при загрузке исключаются из дедупликации.
+func TestFileStore_UpgradeExpiring(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	journal := `{"uuid":"ttl123","original_url":"http://sale.url","expires_at":"2026-03-01T12:00:00Z"}` + "\n" +
+		`{"uuid":"abc123","original_url":"http://other.url"}` + "\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(journal), 0o600))
+
+	store, err := filestore.NewFileStore(filePath, logger)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	_, err = store.FindByOriginalURL(context.Background(), "http://sale.url")
+	require.ErrorIs(t, err, repository.ErrURLNotFound)
+	url, err := store.Get(context.Background(), "ttl123")
+	require.NoError(t, err)
+	assert.True(t, url.Exclusive)
+
+	id, err := store.FindByOriginalURL(context.Background(), "http://other.url")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", id)
+}
@@ -1,68 +1,481 @@
 package memorystore
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
 	"linkshrink/internal/utils/logger"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-var (
-	ErrURLNotFound     = errors.New("URL not found")
-	ErrIDAlreadyExists = errors.New("ID already exists")
-)
-
-type URLData struct {
-	UUID        string `json:"uuid"`
-	OriginalURL string `json:"original_url"`
+func init() {
+	repository.Register("memory", func(_ *config.Config, log logger.Logger) (repository.IURLRepository, error) {
+		return NewMemoryStore(log), nil
+	})
 }
 
 type IMemoryStore interface {
-	Save(id string, originalURL string) error
-	Find(id string) (string, error)
+	Save(ctx context.Context, id string, originalURL string) error
+	SaveURL(ctx context.Context, url repository.URLData) error
+	SaveBatch(ctx context.Context, urls []repository.URLData) error
+	Find(ctx context.Context, id string) (string, error)
+	Get(ctx context.Context, id string) (repository.URLData, error)
+	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+	DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error)
+	Click(ctx context.Context, id string, now time.Time) (repository.URLData, error)
+	FindByUser(ctx context.Context, userID string) ([]repository.URLData, error)
+	DeleteUserURLs(ctx context.Context, links []repository.UserLink) (int, error)
+	ReserveIDs(ctx context.Context, ids []string) ([]string, error)
+	ReleaseIDs(ctx context.Context, ids []string) error
+	SaveAPIKey(ctx context.Context, key repository.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (repository.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]repository.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, now time.Time) error
 }
 
 type MemoryStore struct {
-	Store  map[string]string // Хранилище для хранения пар ID и оригинальных URL
-	mu     *sync.Mutex       // Мьютекс для обеспечения потокобезопасности
-	logger logger.Logger
+	Store    map[string]repository.URLData // Хранилище записей по ID
+	index    map[string]string             // Обратный индекс: оригинальный URL -> ID
+	reserved map[string]time.Time          // ID, зарезервированные пулом ключей, и момент резерва
+	apiKeys  map[string]repository.APIKey  // API-ключи по ID
+	mu       *sync.Mutex                   // Мьютекс для обеспечения потокобезопасности
+	logger   logger.Logger
 }
 
 // NewMemoryStore создает новый экземпляр MemoryStore.
 func NewMemoryStore(log logger.Logger) *MemoryStore {
 	componentLogger := log.With(zap.String("component", "MemoryStore"))
 	repo := &MemoryStore{
-		Store:  make(map[string]string),
-		mu:     &sync.Mutex{},
-		logger: componentLogger,
+		Store:    make(map[string]repository.URLData),
+		index:    make(map[string]string),
+		reserved: make(map[string]time.Time),
+		apiKeys:  make(map[string]repository.APIKey),
+		mu:       &sync.Mutex{},
+		logger:   componentLogger,
 	}
 
 	return repo
 }
 
 // Save сохраняет оригинальный URL по ID.
-func (r *MemoryStore) Save(id string, originalURL string) error {
+func (r *MemoryStore) Save(ctx context.Context, id string, originalURL string) error {
+	return r.SaveURL(ctx, repository.URLData{UUID: id, OriginalURL: originalURL})
+}
+
+// SaveURL сохраняет запись со всеми ее полями.
+func (r *MemoryStore) SaveURL(ctx context.Context, url repository.URLData) error {
+	return r.SaveBatch(ctx, []repository.URLData{url})
+}
+
+// SaveBatch сохраняет пакет записей целиком или не сохраняет ничего.
+func (r *MemoryStore) SaveBatch(ctx context.Context, urls []repository.URLData) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save batch canceled: %w", err)
+	}
+
 	r.mu.Lock() // Блокируем мьютекс
 	defer r.mu.Unlock()
 
-	_, ok := r.Store[id]
-	if !ok {
-		r.Store[id] = originalURL
-		return nil
+	if err := r.checkBatch(urls); err != nil {
+		return err
+	}
+	for _, url := range urls {
+		r.put(url)
+	}
+	return nil
+}
+
+// CheckBatch проверяет, что ни ID, ни оригинальные URL пакета не заняты
+// ни в хранилище, ни внутри пакета, а ID без признака Reserved не зарезервированы.
+// URL ссылок с Exclusive не проверяются.
+func (r *MemoryStore) CheckBatch(urls []repository.URLData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.checkBatch(urls)
+}
+
+func (r *MemoryStore) checkBatch(urls []repository.URLData) error {
+	now := time.Now()
+	ids := make(map[string]struct{}, len(urls))
+	originalURLs := make(map[string]struct{}, len(urls))
+	for _, url := range urls {
+		if _, ok := r.Store[url.UUID]; ok {
+			return repository.ErrIDAlreadyExists
+		}
+		if _, ok := ids[url.UUID]; ok {
+			return repository.ErrIDAlreadyExists
+		}
+		if !url.Reserved && r.reservedAt(url.UUID, now) {
+			return repository.ErrIDAlreadyExists
+		}
+		ids[url.UUID] = struct{}{}
+		if url.Exclusive {
+			continue
+		}
+		if _, ok := r.index[url.OriginalURL]; ok {
+			return repository.ErrURLAlreadyExists
+		}
+		if _, ok := originalURLs[url.OriginalURL]; ok {
+			return repository.ErrURLAlreadyExists
+		}
+		originalURLs[url.OriginalURL] = struct{}{}
+	}
+	return nil
+}
+
+// reservedAt сообщает, действует ли в момент now резерв ID.
+func (r *MemoryStore) reservedAt(id string, now time.Time) bool {
+	at, ok := r.reserved[id]
+	return ok && now.Sub(at) < repository.ReservationTTL
+}
+
+// Put записывает запись без проверок, заменяя запись с тем же ID.
+// Используется при восстановлении данных, сохраненных ранее.
+func (r *MemoryStore) Put(url repository.URLData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.put(url)
+}
+
+func (r *MemoryStore) put(url repository.URLData) {
+	if previous, ok := r.Store[url.UUID]; ok {
+		r.unindex(previous)
+	}
+	r.Store[url.UUID] = url
+	if !url.Exclusive {
+		r.index[url.OriginalURL] = url.UUID
 	}
+	delete(r.reserved, url.UUID)
+}
 
-	return ErrIDAlreadyExists
+// unindex удаляет запись из обратного индекса, если индекс указывает на нее.
+// После удаления истекшей ссылки тот же URL мог быть сокращен заново под другим ID.
+func (r *MemoryStore) unindex(url repository.URLData) {
+	if r.index[url.OriginalURL] == url.UUID {
+		delete(r.index, url.OriginalURL)
+	}
+}
+
+// Reset удаляет все записи, резервы ID и API-ключи.
+func (r *MemoryStore) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Store = make(map[string]repository.URLData)
+	r.index = make(map[string]string)
+	r.reserved = make(map[string]time.Time)
+	r.apiKeys = make(map[string]repository.APIKey)
 }
 
 // Find ищет оригинальный URL по ID.
-func (r *MemoryStore) Find(id string) (string, error) {
+func (r *MemoryStore) Find(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("find canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.Store[id] // Проверяем, существует ли ID в хранилище
+	if !ok {
+		return "", repository.ErrURLNotFound
+	}
+	return url.OriginalURL, nil
+}
+
+// Get ищет запись по ID.
+func (r *MemoryStore) Get(ctx context.Context, id string) (repository.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.URLData{}, fmt.Errorf("get canceled: %w", err)
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	originalURL, ok := r.Store[id] // Проверяем, существует ли ID в хранилище
+	url, ok := r.Store[id]
 	if !ok {
-		return "", ErrURLNotFound
+		return repository.URLData{}, repository.ErrURLNotFound
+	}
+	return url, nil
+}
+
+// FindByOriginalURL ищет ID по оригинальному URL.
+func (r *MemoryStore) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("find canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.index[originalURL]
+	if !ok {
+		return "", repository.ErrURLNotFound
+	}
+	return id, nil
+}
+
+// DeleteExpired удаляет записи, срок действия которых истек не позже before.
+// Если заданы ids, проверяются только они, иначе - все хранилище.
+func (r *MemoryStore) DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("delete expired canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expired := r.expired(before, ids)
+	for _, url := range expired {
+		r.remove(url.UUID)
+	}
+	return len(expired), nil
+}
+
+// Expired возвращает записи, срок действия которых истек не позже before, не удаляя их.
+// Если заданы ids, проверяются только они, иначе - все хранилище.
+func (r *MemoryStore) Expired(before time.Time, ids ...string) []repository.URLData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.expired(before, ids)
+}
+
+func (r *MemoryStore) expired(before time.Time, ids []string) []repository.URLData {
+	var expired []repository.URLData
+	check := func(url repository.URLData) {
+		if !url.Deleted && url.Expired(before) {
+			expired = append(expired, url)
+		}
+	}
+
+	if len(ids) > 0 {
+		for _, id := range ids {
+			if url, ok := r.Store[id]; ok {
+				check(url)
+			}
+		}
+		return expired
 	}
-	return originalURL, nil
+	for _, url := range r.Store {
+		check(url)
+	}
+	return expired
+}
+
+// Remove удаляет запись без проверок.
+// Используется при восстановлении данных, сохраненных ранее.
+func (r *MemoryStore) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(id)
+}
+
+func (r *MemoryStore) remove(id string) {
+	if url, ok := r.Store[id]; ok {
+		delete(r.Store, id)
+		r.unindex(url)
+	}
+}
+
+// Click засчитывает переход по ссылке id. Проверка лимита и учет перехода выполняются
+// под одной блокировкой, поэтому одновременные переходы не превышают лимит.
+func (r *MemoryStore) Click(ctx context.Context, id string, now time.Time) (repository.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.URLData{}, fmt.Errorf("click canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.Store[id]
+	if !ok {
+		return repository.URLData{}, repository.ErrURLNotFound
+	}
+	url, err := url.Click(now)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+	r.Store[id] = url
+	return url, nil
+}
+
+// FindByUser возвращает ссылки пользователя userID, упорядоченные по ID.
+// Хранилище просматривается целиком.
+func (r *MemoryStore) FindByUser(ctx context.Context, userID string) ([]repository.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("find by user canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var urls []repository.URLData
+	for _, url := range r.Store {
+		if url.UserID == userID {
+			urls = append(urls, url)
+		}
+	}
+	slices.SortFunc(urls, func(a, b repository.URLData) int {
+		return strings.Compare(a.UUID, b.UUID)
+	})
+	return urls, nil
+}
+
+// DeleteUserURLs помечает удаленными ссылки из links, принадлежащие указанным пользователям.
+func (r *MemoryStore) DeleteUserURLs(ctx context.Context, links []repository.UserLink) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("delete canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for _, link := range links {
+		url, ok := r.Store[link.ID]
+		if !ok || !url.Owned(link.UserID) {
+			continue
+		}
+		r.put(url.Delete())
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ReserveIDs резервирует свободные ID из ids и возвращает их. Устаревший резерв занимается заново.
+func (r *MemoryStore) ReserveIDs(ctx context.Context, ids []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("reserve canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	reserved := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := r.Store[id]; ok {
+			continue
+		}
+		if r.reservedAt(id, now) {
+			continue
+		}
+		r.reserved[id] = now
+		reserved = append(reserved, id)
+	}
+	return reserved, nil
+}
+
+// ReleaseIDs снимает резерв с ids.
+func (r *MemoryStore) ReleaseIDs(ctx context.Context, ids []string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("release canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.reserved, id)
+	}
+	return nil
+}
+
+// SaveAPIKey сохраняет новый API-ключ.
+func (r *MemoryStore) SaveAPIKey(ctx context.Context, key repository.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save API key canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.apiKeys[key.ID]; ok {
+		return repository.ErrIDAlreadyExists
+	}
+	r.apiKeys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+// PutAPIKey записывает ключ без проверок, заменяя ключ с тем же ID.
+// Используется при восстановлении данных, сохраненных ранее.
+func (r *MemoryStore) PutAPIKey(key repository.APIKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys[key.ID] = cloneAPIKey(key)
+}
+
+// GetAPIKey ищет API-ключ по ID.
+func (r *MemoryStore) GetAPIKey(ctx context.Context, id string) (repository.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.APIKey{}, fmt.Errorf("get API key canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return repository.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(key), nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке создания.
+func (r *MemoryStore) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("list API keys canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]repository.APIKey, 0, len(r.apiKeys))
+	for _, key := range r.apiKeys {
+		keys = append(keys, cloneAPIKey(key))
+	}
+	slices.SortFunc(keys, func(a, b repository.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ id в момент now.
+func (r *MemoryStore) RevokeAPIKey(ctx context.Context, id string, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("revoke API key canceled: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return repository.ErrAPIKeyNotFound
+	}
+	if !key.Revoked() {
+		key.RevokedAt = &now
+		r.apiKeys[id] = key
+	}
+	return nil
+}
+
+// cloneAPIKey копирует список прав ключа, чтобы вызывающий код не изменил хранимый ключ.
+func cloneAPIKey(key repository.APIKey) repository.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	return key
 }
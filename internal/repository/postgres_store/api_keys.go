@@ -0,0 +1,85 @@
+package postgresstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/repository"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// apiKeyColumns - столбцы API-ключа в порядке, в котором их читает scanAPIKey.
+const apiKeyColumns = `id, hash, owner, name, scopes, created_at, revoked_at`
+
+// SaveAPIKey сохраняет новый API-ключ.
+func (r *PostgresStore) SaveAPIKey(ctx context.Context, key repository.APIKey) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.Hash, key.Owner, key.Name, key.Scopes, key.CreatedAt, key.RevokedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return repository.ErrIDAlreadyExists
+		}
+		return fmt.Errorf("не удалось сохранить API-ключ: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey ищет API-ключ по ID.
+func (r *PostgresStore) GetAPIKey(ctx context.Context, id string) (repository.APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repository.APIKey{}, repository.ErrAPIKeyNotFound
+		}
+		return repository.APIKey{}, fmt.Errorf("не удалось найти API-ключ: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке создания.
+func (r *PostgresStore) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить API-ключи: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []repository.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать API-ключи: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ id в момент now. Момент отзыва уже отозванного ключа не меняется.
+func (r *PostgresStore) RevokeAPIKey(ctx context.Context, id string, now time.Time) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, now)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать API-ключ: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repository.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// scanAPIKey читает API-ключ из строки результата запроса столбцов apiKeyColumns.
+func scanAPIKey(row pgx.Row) (repository.APIKey, error) {
+	var key repository.APIKey
+	err := row.Scan(&key.ID, &key.Hash, &key.Owner, &key.Name, &key.Scopes, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return repository.APIKey{}, fmt.Errorf("не удалось прочитать API-ключ: %w", err)
+	}
+	return key, nil
+}
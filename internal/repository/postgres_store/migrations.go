@@ -0,0 +1,112 @@
+package postgresstore
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// migrations - последовательные изменения схемы базы.
+// Номер примененной миграции хранится в таблице schema_migrations,
+// поэтому существующие миграции менять нельзя - только добавлять новые в конец.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		id           TEXT        NOT NULL,
+		original_url TEXT        NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_id_idx ON urls (id)`,
+	// Обратный индекс для дедупликации. Если в базе уже есть повторяющиеся
+	// оригинальные URL, миграция завершится ошибкой и их нужно будет удалить вручную.
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url)`,
+	// ID, зарезервированные пулами ключей экземпляров сервиса.
+	`CREATE TABLE IF NOT EXISTS reserved_ids (
+		id          TEXT        PRIMARY KEY,
+		reserved_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	// Срок действия ссылки, NULL - ссылка бессрочная.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL`,
+	// Лимит и счетчик переходов, 0 - без ограничения.
+	`ALTER TABLE urls
+		ADD COLUMN IF NOT EXISTS max_clicks INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS clicks     INTEGER NOT NULL DEFAULT 0`,
+	// bcrypt-хеш пароля ссылки, пустой - ссылка без пароля.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT ''`,
+	// Пользователь, создавший ссылку, пустой - ссылка создана анонимно.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id) WHERE user_id <> ''`,
+	// Пометка удаления ссылки владельцем.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_deleted BOOLEAN NOT NULL DEFAULT FALSE`,
+	// API-ключи: хранится только хеш секретной части ключа.
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id         TEXT        PRIMARY KEY,
+		hash       TEXT        NOT NULL,
+		owner      TEXT        NOT NULL,
+		name       TEXT        NOT NULL DEFAULT '',
+		scopes     TEXT[]      NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ
+	)`,
+	// Ссылки, не участвующие в дедупликации (например, приватные): обратный индекс
+	// пересоздается частичным, чтобы такая ссылка не мешала сократить тот же URL заново.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_exclusive BOOLEAN NOT NULL DEFAULT FALSE`,
+	`DROP INDEX IF EXISTS urls_original_url_idx`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url) WHERE NOT is_exclusive`,
+	// Отметка ссылок на другие сервисы сокращения, сохраненных при политике flag.
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_flagged BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE INDEX IF NOT EXISTS urls_flagged_idx ON urls (id) WHERE is_flagged`,
+	// Ссылки со сроком действия не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = TRUE WHERE expires_at IS NOT NULL`,
+	// Ссылки с лимитом переходов не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = TRUE WHERE max_clicks > 0`,
+	// Ссылки с паролем не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = TRUE WHERE password_hash <> ''`,
+	// Удаленные ссылки не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = TRUE WHERE is_deleted`,
+}
+
+// migrationLockID - ключ advisory-блокировки, под которой применяются миграции,
+// чтобы несколько экземпляров сервиса не мигрировали схему одновременно.
+const migrationLockID = 7_346_912_001
+
+// migrate применяет к базе миграции, которые еще не были применены.
+func (r *PostgresStore) migrate(ctx context.Context) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает.
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("не удалось создать таблицу миграций: %w", err)
+	}
+
+	var version int
+	err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.Exec(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("не удалось применить миграцию %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return fmt.Errorf("не удалось обновить версию схемы: %w", err)
+		}
+		r.logger.Info("Применена миграция схемы", zap.Int("version", i+1))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("не удалось зафиксировать миграции: %w", err)
+	}
+	return nil
+}
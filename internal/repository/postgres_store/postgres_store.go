@@ -0,0 +1,345 @@
+package postgresstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func init() {
+	repository.Register("postgres", func(cfg *config.Config, log logger.Logger) (repository.IURLRepository, error) {
+		store, err := NewPostgresStore(cfg.Postgres.DSN, cfg.Postgres.MaxConns, log)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	})
+}
+
+type IPostgresStore interface {
+	Save(ctx context.Context, id string, originalURL string) error
+	SaveURL(ctx context.Context, url repository.URLData) error
+	SaveBatch(ctx context.Context, urls []repository.URLData) error
+	Find(ctx context.Context, id string) (string, error)
+	Get(ctx context.Context, id string) (repository.URLData, error)
+	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+	DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error)
+	Click(ctx context.Context, id string, now time.Time) (repository.URLData, error)
+	ReserveIDs(ctx context.Context, ids []string) ([]string, error)
+	ReleaseIDs(ctx context.Context, ids []string) error
+	Close() error
+}
+
+// PostgresStore хранит пары ID и оригинальных URL в PostgreSQL.
+type PostgresStore struct {
+	pool   *pgxpool.Pool // Пул соединений с базой
+	logger logger.Logger
+}
+
+const (
+	connectTimeout = 5 * time.Second
+	// uniqueViolation - код ошибки PostgreSQL при нарушении уникальности.
+	uniqueViolation = "23505"
+	// originalURLIndex - уникальный индекс по оригинальному URL.
+	originalURLIndex = "urls_original_url_idx"
+	// insertURL сохраняет запись и снимает резерв с ее ID одним запросом: если вставка не удалась,
+	// резерв тоже остается. Действующий резерв ($10 - начало срока действия) снимает и перекрывает
+	// только запись с признаком Reserved ($9), иначе запись не вставляется.
+	insertURL = `WITH released AS (
+			DELETE FROM reserved_ids WHERE id = $1 AND ($9::boolean OR reserved_at <= $10)
+		)
+		INSERT INTO urls (id, original_url, expires_at, max_clicks, password_hash, user_id, is_exclusive, is_flagged)
+		SELECT $1::text, $2::text, $3::timestamptz, $4::integer, $5::text, $6::text, $7::boolean, $8::boolean
+		WHERE $9::boolean OR NOT EXISTS (SELECT 1 FROM reserved_ids WHERE id = $1 AND reserved_at > $10)`
+	// urlColumns - столбцы записи в порядке, в котором их читает scanURL.
+	urlColumns = `id, original_url, expires_at, max_clicks, clicks, password_hash, user_id, is_deleted,
+		is_exclusive, is_flagged`
+)
+
+// NewPostgresStore подключается к PostgreSQL по строке подключения dsn и применяет миграции схемы.
+// Размер пула ограничивается maxConns, если он задан.
+func NewPostgresStore(dsn string, maxConns int, log logger.Logger) (*PostgresStore, error) {
+	componentLogger := log.With(zap.String("component", "PostgresStore"))
+
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная строка подключения: %w", err)
+	}
+	if maxConns > 0 {
+		poolConfig.MaxConns = int32(maxConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать пул соединений: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("не удалось подключиться к базе: %w", err)
+	}
+
+	repo := &PostgresStore{pool: pool, logger: componentLogger}
+	if err := repo.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// Save сохраняет оригинальный URL по ID.
+func (r *PostgresStore) Save(ctx context.Context, id string, originalURL string) error {
+	return r.SaveURL(ctx, repository.URLData{UUID: id, OriginalURL: originalURL})
+}
+
+// SaveURL сохраняет запись одним запросом.
+func (r *PostgresStore) SaveURL(ctx context.Context, url repository.URLData) error {
+	tag, err := r.pool.Exec(ctx, insertURL, url.UUID, url.OriginalURL, url.ExpiresAt, url.MaxClicks, url.PasswordHash,
+		url.UserID, url.Exclusive, url.Flagged, url.Reserved, reservationStart())
+	return checkInsert(tag, err)
+}
+
+// SaveBatch сохраняет пакет записей в одной транзакции: при любой ошибке не сохраняется ничего.
+// Запросы отправляются в базу одним пакетом pgx.Batch.
+func (r *PostgresStore) SaveBatch(ctx context.Context, urls []repository.URLData) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает.
+		_ = tx.Rollback(ctx)
+	}()
+
+	batch := &pgx.Batch{}
+	reservedAfter := reservationStart()
+	for _, url := range urls {
+		batch.Queue(insertURL, url.UUID, url.OriginalURL, url.ExpiresAt, url.MaxClicks, url.PasswordHash,
+			url.UserID, url.Exclusive, url.Flagged, url.Reserved, reservedAfter)
+	}
+	results := tx.SendBatch(ctx, batch)
+	for range urls {
+		if err := checkInsert(results.Exec()); err != nil {
+			_ = results.Close()
+			return err
+		}
+	}
+	if err := results.Close(); err != nil {
+		return mapInsertError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// Find ищет оригинальный URL по ID.
+func (r *PostgresStore) Find(ctx context.Context, id string) (string, error) {
+	var originalURL string
+	err := r.pool.QueryRow(ctx,
+		`SELECT original_url FROM urls WHERE id = $1`, id,
+	).Scan(&originalURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", repository.ErrURLNotFound
+		}
+		return "", fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return originalURL, nil
+}
+
+// Get ищет запись по ID.
+func (r *PostgresStore) Get(ctx context.Context, id string) (repository.URLData, error) {
+	url, err := scanURL(r.pool.QueryRow(ctx, `SELECT `+urlColumns+` FROM urls WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repository.URLData{}, repository.ErrURLNotFound
+		}
+		return repository.URLData{}, fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return url, nil
+}
+
+// Click засчитывает переход по ссылке id одним запросом UPDATE: строка блокируется на время
+// обновления, а условие проверяется заново после ожидания блокировки, поэтому одновременные
+// переходы не превышают лимит. Последний разрешенный переход устанавливает expires_at в now.
+func (r *PostgresStore) Click(ctx context.Context, id string, now time.Time) (repository.URLData, error) {
+	url, err := scanURL(r.pool.QueryRow(ctx, `
+		UPDATE urls SET
+			clicks = clicks + 1,
+			expires_at = CASE WHEN max_clicks > 0 AND clicks + 1 >= max_clicks THEN $2 ELSE expires_at END
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		RETURNING `+urlColumns, id, now))
+	if err == nil {
+		return url, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return repository.URLData{}, fmt.Errorf("не удалось засчитать переход: %w", err)
+	}
+
+	// Запись не обновлена: ссылки нет или ее срок действия истек.
+	if _, err := r.Get(ctx, id); err != nil {
+		return repository.URLData{}, err
+	}
+	return repository.URLData{}, repository.ErrURLExpired
+}
+
+// scanURL читает запись из строки результата запроса столбцов urlColumns.
+func scanURL(row pgx.Row) (repository.URLData, error) {
+	var url repository.URLData
+	err := row.Scan(&url.UUID, &url.OriginalURL, &url.ExpiresAt, &url.MaxClicks, &url.Clicks,
+		&url.PasswordHash, &url.UserID, &url.Deleted, &url.Exclusive, &url.Flagged)
+	if err != nil {
+		return repository.URLData{}, fmt.Errorf("не удалось прочитать запись: %w", err)
+	}
+	return url, nil
+}
+
+// FindByUser возвращает ссылки пользователя userID, упорядоченные по ID.
+func (r *PostgresStore) FindByUser(ctx context.Context, userID string) ([]repository.URLData, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+urlColumns+` FROM urls WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти ссылки пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []repository.URLData
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ссылки пользователя: %w", err)
+	}
+	return urls, nil
+}
+
+// FindByOriginalURL ищет ID по оригинальному URL среди ссылок, участвующих в дедупликации.
+func (r *PostgresStore) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	var id string
+	err := r.pool.QueryRow(ctx, `SELECT id FROM urls WHERE original_url = $1 AND NOT is_exclusive`,
+		originalURL).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", repository.ErrURLNotFound
+		}
+		return "", fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteExpired удаляет записи, срок действия которых истек не позже before.
+// Если заданы ids, проверяются только они.
+func (r *PostgresStore) DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error) {
+	query := `DELETE FROM urls WHERE expires_at <= $1 AND NOT is_deleted`
+	args := []any{before}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, ids)
+	}
+
+	tag, err := r.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить истекшие ссылки: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteUserURLs помечает удаленными ссылки из links, принадлежащие указанным пользователям,
+// одним запросом UPDATE по парам (ID, владелец).
+func (r *PostgresStore) DeleteUserURLs(ctx context.Context, links []repository.UserLink) (int, error) {
+	ids := make([]string, 0, len(links))
+	userIDs := make([]string, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ID)
+		userIDs = append(userIDs, link.UserID)
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE urls SET is_deleted = TRUE, is_exclusive = TRUE
+		FROM unnest($1::text[], $2::text[]) AS d(id, user_id)
+		WHERE urls.id = d.id AND urls.user_id = d.user_id AND urls.user_id <> '' AND NOT urls.is_deleted`,
+		ids, userIDs)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить ссылки: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ReserveIDs резервирует те из ids, которые не заняты и не зарезервированы другими экземплярами.
+// Устаревший резерв (см. repository.ReservationTTL) занимается заново.
+func (r *PostgresStore) ReserveIDs(ctx context.Context, ids []string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		INSERT INTO reserved_ids (id)
+		SELECT DISTINCT c.id FROM unnest($1::text[]) AS c(id)
+		WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.id = c.id)
+		ON CONFLICT (id) DO UPDATE SET reserved_at = now() WHERE reserved_ids.reserved_at <= $2
+		RETURNING id`, ids, reservationStart())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось зарезервировать ID: %w", err)
+	}
+
+	reserved, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("не удалось зарезервировать ID: %w", err)
+	}
+	return reserved, nil
+}
+
+// ReleaseIDs снимает резерв с неиспользованных ID.
+func (r *PostgresStore) ReleaseIDs(ctx context.Context, ids []string) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM reserved_ids WHERE id = ANY($1)`, ids); err != nil {
+		return fmt.Errorf("не удалось освободить ID: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает пул соединений.
+func (r *PostgresStore) Close() error {
+	r.pool.Close()
+	return nil
+}
+
+// reservationStart возвращает момент, раньше которого резерв ID считается устаревшим.
+func reservationStart() time.Time {
+	return time.Now().Add(-repository.ReservationTTL)
+}
+
+// checkInsert проверяет результат insertURL: запрос, не вставивший запись, встретил чужой резерв ID.
+func checkInsert(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return mapInsertError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repository.ErrIDAlreadyExists
+	}
+	return nil
+}
+
+// mapInsertError преобразует нарушение уникальности в ошибку репозитория.
+func mapInsertError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		if pgErr.ConstraintName == originalURLIndex {
+			return repository.ErrURLAlreadyExists
+		}
+		return repository.ErrIDAlreadyExists
+	}
+	return fmt.Errorf("не удалось сохранить URL: %w", err)
+}
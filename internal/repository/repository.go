@@ -1,35 +1,229 @@
 package repository
 
 import (
+	"context"
 	"errors"
-	filestore "linkshrink/internal/repository/file_store"
-	memorystore "linkshrink/internal/repository/memory_store"
+	"fmt"
+	"linkshrink/internal/config"
 	"linkshrink/internal/utils/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
-	ErrURLNotFound     = errors.New("URL not found")
-	ErrIDAlreadyExists = errors.New("ID already exists")
+	ErrURLNotFound         = errors.New("URL not found")
+	ErrIDAlreadyExists     = errors.New("ID already exists")
+	ErrURLAlreadyExists    = errors.New("URL already exists")
+	ErrURLExpired          = errors.New("URL expired")
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+	ErrUnknownStorageType  = errors.New("unknown storage type")
+	ErrStorageTypeConflict = errors.New("storage type already registered")
 )
 
+// ReservationTTL - время, после которого резерв ID (см. IKeyReserver) считается брошенным,
+// например экземпляром сервиса, завершившимся без снятия резерва, и ID снова можно занять.
+const ReservationTTL = 24 * time.Hour
+
 type URLData struct {
-	UUID        string `json:"uuid"`
-	OriginalURL string `json:"original_url"`
+	UUID        string     `json:"uuid"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Момент истечения срока действия, nil - ссылка бессрочная
+	MaxClicks   int        `json:"max_clicks,omitempty"` // Допустимое количество переходов, 0 - без ограничения
+	Clicks      int        `json:"clicks,omitempty"`     // Количество засчитанных переходов
+	// PasswordHash - bcrypt-хеш пароля ссылки (соль входит в хеш), пустой - ссылка без пароля.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// UserID - пользователь, создавший ссылку, пустой - ссылка создана анонимно.
+	UserID string `json:"user_id,omitempty"`
+	// Deleted сообщает, что владелец удалил ссылку. Удаленная ссылка хранится, пока не будет
+	// удалена как истекшая, чтобы отвечать 410 Gone.
+	Deleted bool `json:"is_deleted,omitempty"`
+	// Exclusive сообщает, что ссылка не участвует в дедупликации: FindByOriginalURL ее не находит,
+	// а ее оригинальный URL можно сократить заново. Так сохраняются приватные ссылки.
+	Exclusive bool `json:"exclusive,omitempty"`
+	// Flagged отмечает ссылку на другой сервис сокращения, сохраненную при политике flag:
+	// ее настоящий адрес назначения скрыт, и такие ссылки можно найти и проверить.
+	Flagged bool `json:"flagged,omitempty"`
+	// Reserved сообщает, что ID взят из резерва пула ключей (см. IKeyReserver). Признак не хранится:
+	// он нужен только при сохранении, чтобы отличить владельца резерва от запроса, занимающего чужой ID.
+	Reserved bool `json:"-"`
+}
+
+// Expired сообщает, истек ли срок действия ссылки к моменту now.
+func (u URLData) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// Click возвращает копию записи с засчитанным в момент now переходом.
+// Последний разрешенный переход завершает срок действия ссылки, поэтому исчерпанная ссылка
+// ведет себя как истекшая. Переход по истекшей ссылке не засчитывается: возвращается ErrURLExpired.
+func (u URLData) Click(now time.Time) (URLData, error) {
+	if u.Expired(now) {
+		return URLData{}, ErrURLExpired
+	}
+	u.Clicks++
+	if u.MaxClicks > 0 && u.Clicks >= u.MaxClicks {
+		u.ExpiresAt = &now
+	}
+	return u, nil
+}
+
+// Delete возвращает копию записи, помеченную удаленной. Срок действия при этом не меняется:
+// удаленная ссылка не удаляется вместе с истекшими и ее ID не занимается заново, а сама она
+// выходит из дедупликации, чтобы ее URL можно было сократить заново.
+func (u URLData) Delete() URLData {
+	u.Deleted = true
+	u.Exclusive = true
+	return u
+}
+
+// Owned сообщает, может ли пользователь userID удалить ссылку: она принадлежит ему и еще не удалена.
+func (u URLData) Owned(userID string) bool {
+	return userID != "" && u.UserID == userID && !u.Deleted
 }
 
 type IURLRepository interface {
-	Save(id string, originalURL string) error
-	Find(id string) (string, error)
+	Save(ctx context.Context, id string, originalURL string) error
+	// SaveURL сохраняет запись со всеми ее полями. Ошибки те же, что у SaveBatch.
+	SaveURL(ctx context.Context, url URLData) error
+	// SaveBatch сохраняет все записи или ни одной: если хотя бы один ID
+	// уже занят (в хранилище или внутри пакета), возвращается ErrIDAlreadyExists,
+	// если занят оригинальный URL - ErrURLAlreadyExists.
+	SaveBatch(ctx context.Context, urls []URLData) error
+	Find(ctx context.Context, id string) (string, error)
+	// Get возвращает запись целиком, включая срок действия ссылки.
+	Get(ctx context.Context, id string) (URLData, error)
+	// FindByOriginalURL возвращает ID, под которым сохранен оригинальный URL.
+	// Ссылки с Exclusive не учитываются ни здесь, ни при проверке уникальности URL в SaveBatch.
+	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+}
+
+// IKeyReserver резервирует свободные ID для пула ключей.
+// Зарезервированный ID не выдается другим пулам, а запись с ним сохраняется, только если у нее есть
+// признак Reserved (иначе возвращается ErrIDAlreadyExists). Резерв действует, пока ID не будет
+// сохранен или освобожден либо пока не пройдет ReservationTTL; сохранение записи снимает резерв.
+type IKeyReserver interface {
+	// ReserveIDs резервирует те из ids, которые не заняты и не зарезервированы, и возвращает их.
+	ReserveIDs(ctx context.Context, ids []string) ([]string, error)
+	// ReleaseIDs снимает резерв с неиспользованных ID.
+	ReleaseIDs(ctx context.Context, ids []string) error
+}
+
+// IExpirer удаляет ссылки с истекшим сроком действия.
+type IExpirer interface {
+	// DeleteExpired удаляет записи, срок действия которых истек не позже before, и возвращает их количество.
+	// Удаленные владельцем записи не удаляются. Если заданы ids, проверяются только записи с этими ID.
+	DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error)
+}
+
+// IClickCounter учитывает переходы по ссылкам с ограниченным количеством переходов.
+type IClickCounter interface {
+	// Click атомарно засчитывает переход по ссылке id в момент now и возвращает запись после учета
+	// (см. URLData.Click): одновременные переходы не могут превысить лимит.
+	Click(ctx context.Context, id string, now time.Time) (URLData, error)
+}
+
+// IUserURLs находит ссылки, созданные пользователем.
+type IUserURLs interface {
+	// FindByUser возвращает ссылки пользователя userID, упорядоченные по ID.
+	FindByUser(ctx context.Context, userID string) ([]URLData, error)
+}
+
+// UserLink - ссылка, которую пользователь просит удалить.
+type UserLink struct {
+	UserID string
+	ID     string
+}
+
+// IDeleter удаляет ссылки по запросу их владельцев.
+type IDeleter interface {
+	// DeleteUserURLs помечает удаленными ссылки из links (см. URLData.Delete) и возвращает
+	// их количество. Чужие, несуществующие и уже удаленные ссылки пропускаются.
+	DeleteUserURLs(ctx context.Context, links []UserLink) (int, error)
+}
+
+// APIKey - ключ доступа программного клиента. Секретная часть ключа не хранится: по ней
+// сохраняется только хеш, поэтому ключ показывается клиенту один раз при создании.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash"`           // SHA-256 секретной части ключа в hex
+	Owner     string     `json:"owner"`          // Пользователь, от имени которого действует ключ
+	Name      string     `json:"name,omitempty"` // Описание ключа для администратора
+	Scopes    []string   `json:"scopes"`         // Разрешенные ключу действия
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Момент отзыва ключа, nil - ключ действует
+}
+
+// Revoked сообщает, отозван ли ключ.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IAPIKeyStore хранит API-ключи.
+type IAPIKeyStore interface {
+	// SaveAPIKey сохраняет новый ключ. Если ID уже занят, возвращается ErrIDAlreadyExists.
+	SaveAPIKey(ctx context.Context, key APIKey) error
+	// GetAPIKey возвращает ключ по ID или ErrAPIKeyNotFound.
+	GetAPIKey(ctx context.Context, id string) (APIKey, error)
+	// ListAPIKeys возвращает все ключи, включая отозванные, в порядке создания.
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// RevokeAPIKey отзывает ключ id в момент now. Повторный отзыв не меняет момент отзыва;
+	// для неизвестного ключа возвращается ErrAPIKeyNotFound.
+	RevokeAPIKey(ctx context.Context, id string, now time.Time) error
 }
 
 type URLRepository struct {
 	Store map[string]string // Хранилище для хранения пар ID и оригинальных URL
 }
 
-// NewStore создает новый экземпляр URLRepository.
-func NewStore(storeType string, filePath string, log logger.Logger) IURLRepository {
-	if storeType == "file" {
-		return filestore.NewFileStore(filePath, log)
+// Factory создает хранилище, используя свою секцию конфигурации.
+type Factory func(cfg *config.Config, log logger.Logger) (IURLRepository, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory) // Зарегистрированные типы хранилищ
+)
+
+// Register регистрирует тип хранилища под именем name.
+// Вызывается из init() пакетов хранилищ; повторная регистрация имени - ошибка программиста.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("%v: %s", ErrStorageTypeConflict, name))
+	}
+	registry[name] = factory
+}
+
+// StorageTypes возвращает отсортированный список зарегистрированных типов хранилищ.
+func StorageTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStore создает новый экземпляр хранилища зарегистрированного типа storeType.
+func NewStore(storeType string, cfg *config.Config, log logger.Logger) (IURLRepository, error) {
+	registryMu.RLock()
+	factory, ok := registry[storeType]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q, available: %s",
+			ErrUnknownStorageType, storeType, strings.Join(StorageTypes(), ", "))
+	}
+
+	store, err := factory(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s store: %w", storeType, err)
 	}
-	return memorystore.NewMemoryStore(log)
+	return store, nil
 }
@@ -1,25 +1,62 @@
 package repository_test
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"linkshrink/internal/config"
 	"linkshrink/internal/repository"
+	_ "linkshrink/internal/repository/file_store"
+	_ "linkshrink/internal/repository/memory_store"
+	_ "linkshrink/internal/repository/postgres_store"
+	_ "linkshrink/internal/repository/sqlite_store"
 	"linkshrink/internal/utils"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 )
 
-const testFilePath = "test_storage.json"
+// testDatabaseDSN - строка подключения к тестовой базе PostgreSQL.
+// Если она не задана, тесты PostgresStore пропускаются.
+var testDatabaseDSN = os.Getenv("TEST_DATABASE_DSN")
 
-func setup() {
-	// Удаляем файл перед каждым тестом, чтобы избежать конфликтов
-	_ = os.Remove(testFilePath)
+// setup возвращает конфигурацию тестовых хранилищ. Файлы хранилищ создаются во временном
+// каталоге теста и удаляются вместе с ним, таблицы тестовой базы PostgreSQL очищаются.
+func setup(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	if testDatabaseDSN != "" {
+		truncatePostgres()
+	}
+	return &config.Config{
+		File:     config.FileStorageConfig{Path: filepath.Join(dir, "test_storage.json")},
+		SQLite:   config.SQLiteConfig{Path: filepath.Join(dir, "test_storage.db")},
+		Postgres: config.PostgresConfig{DSN: testDatabaseDSN},
+	}
+}
+
+// truncatePostgres очищает таблицы тестовой базы. Таблиц может еще не быть, ошибку игнорируем.
+func truncatePostgres() {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, testDatabaseDSN)
+	if err != nil {
+		log.Printf("Ошибка подключения к тестовой базе: %v", err)
+		return
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	_, _ = conn.Exec(ctx, "TRUNCATE urls, reserved_ids, api_keys")
 }
 
 var tests = []struct {
@@ -28,27 +65,57 @@ var tests = []struct {
 }{
 	{
 		name:     "MempStore",
-		repoType: "memo",
+		repoType: "memory",
 	},
 	{
 		name:     "FileStore",
 		repoType: "file",
 	},
+	{
+		name:     "SQLiteStore",
+		repoType: "sqlite",
+	},
+	{
+		name:     "PostgresStore",
+		repoType: "postgres",
+	},
+}
+
+func skipUnavailable(t *testing.T, repoType string) {
+	t.Helper()
+	if repoType == "postgres" && testDatabaseDSN == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+}
+
+// newStore создает хранилище и закрывает его по завершении теста,
+// чтобы фоновые горутины хранилища не переживали тест.
+func newStore(t *testing.T, repoType string, cfg *config.Config, logger *zap.Logger) repository.IURLRepository {
+	t.Helper()
+	repo, err := repository.NewStore(repoType, cfg, logger)
+	require.NoError(t, err)
+	if closer, ok := repo.(io.Closer); ok {
+		t.Cleanup(func() {
+			assert.NoError(t, closer.Close())
+		})
+	}
+	return repo
 }
 
 func TestURLRepository_Save(t *testing.T) {
-	setup()
+	cfg := setup(t)
 	logger := zaptest.NewLogger(t)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := repository.NewStore(tt.repoType, testFilePath, logger)
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
 			// Тестирование сохранения URL
-			err := repo.Save("abc123", "http://original.url")
+			err := repo.Save(context.Background(), "abc123", "http://original.url")
 			require.NoError(t, err)
 
 			// Проверяем, что URL сохранен
-			originalURL, err := repo.Find("abc123")
+			originalURL, err := repo.Find(context.Background(), "abc123")
 			require.NoError(t, err)
 			assert.Equal(t, "http://original.url", originalURL)
 		})
@@ -56,23 +123,24 @@ func TestURLRepository_Save(t *testing.T) {
 }
 
 func TestURLRepository_Find(t *testing.T) {
-	setup()
+	cfg := setup(t)
 	logger := zaptest.NewLogger(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := repository.NewStore(tt.repoType, testFilePath, logger)
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
 
 			// Сохраняем URL для дальнейшего поиска
-			err := repo.Save("abc123", "http://original.url")
+			err := repo.Save(context.Background(), "abc123", "http://original.url")
 			require.NoError(t, err)
 
 			// Тестирование поиска существующего URL
-			originalURL, err := repo.Find("abc123")
+			originalURL, err := repo.Find(context.Background(), "abc123")
 			require.NoError(t, err)
 			assert.Equal(t, "http://original.url", originalURL)
 
 			// Тестирование поиска несуществующего URL
-			_, err = repo.Find("nonexistent")
+			_, err = repo.Find(context.Background(), "nonexistent")
 			assert.Error(t, err)
 			assert.Equal(t, "URL not found", err.Error())
 		})
@@ -80,11 +148,12 @@ func TestURLRepository_Find(t *testing.T) {
 }
 
 func TestURLRepository_ConcurrentAccess(t *testing.T) {
-	setup()
+	cfg := setup(t)
 	logger := zaptest.NewLogger(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := repository.NewStore(tt.repoType, testFilePath, logger)
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
 
 			// Используем WaitGroup для ожидания завершения всех горутин
 			var wg sync.WaitGroup
@@ -94,7 +163,7 @@ func TestURLRepository_ConcurrentAccess(t *testing.T) {
 				wg.Add(1)
 				go func(id int) {
 					defer wg.Done()
-					err := repo.Save(fmt.Sprintf("id%d", id), fmt.Sprintf("http://url%d.com", id))
+					err := repo.Save(context.Background(), fmt.Sprintf("id%d", id), fmt.Sprintf("http://url%d.com", id))
 					require.NoError(t, err)
 				}(i)
 			}
@@ -104,7 +173,7 @@ func TestURLRepository_ConcurrentAccess(t *testing.T) {
 
 			// Проверяем, что все URL были сохранены
 			for i := range utils.Intrange(0, 100) {
-				originalURL, err := repo.Find(fmt.Sprintf("id%d", i))
+				originalURL, err := repo.Find(context.Background(), fmt.Sprintf("id%d", i))
 				require.NoError(t, err)
 				assert.Equal(t, fmt.Sprintf("http://url%d.com", i), originalURL)
 			}
@@ -113,33 +182,747 @@ func TestURLRepository_ConcurrentAccess(t *testing.T) {
 }
 
 func TestURLRepository_LoadFromFile(t *testing.T) {
+	cfg := setup(t)
 	// Создаем тестовый репозиторий и сохраняем несколько URL
 	logger := zaptest.NewLogger(t)
 
-	repo := repository.NewStore("file", testFilePath, logger)
+	repo := newStore(t, "file", cfg, logger)
 
 	// Сохраняем несколько URL
-	_ = repo.Save("abc123", "http://original.url")
-	_ = repo.Save("def456", "http://another.url")
+	_ = repo.Save(context.Background(), "abc123", "http://original.url")
+	_ = repo.Save(context.Background(), "def456", "http://another.url")
 
 	// Создаем новый репозиторий, который должен загрузить данные из файла
-	repo2 := repository.NewStore("file", testFilePath, logger)
+	repo2 := newStore(t, "file", cfg, logger)
 
 	// Проверяем, что данные были загружены корректно
-	originalURL, err := repo2.Find("abc123")
+	originalURL, err := repo2.Find(context.Background(), "abc123")
 	require.NoError(t, err)
 	assert.Equal(t, "http://original.url", originalURL)
 
-	originalURL, err = repo2.Find("def456")
+	originalURL, err = repo2.Find(context.Background(), "def456")
 	require.NoError(t, err)
 	assert.Equal(t, "http://another.url", originalURL)
+}
 
-	// Удаляем тестовый файл после теста
-	defer func() {
-		if err := os.Remove(testFilePath); err != nil {
-			log.Printf("Ошибка при удалении файла: %v", err)
-		} else {
-			log.Println("Файл успешно удален.")
-		}
-	}()
+func TestNewStore_UnknownType(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	repo, err := repository.NewStore("redis", cfg, logger)
+	require.ErrorIs(t, err, repository.ErrUnknownStorageType)
+	assert.Nil(t, repo)
+	assert.Contains(t, err.Error(), "file, memory, postgres, sqlite")
+}
+
+func TestURLRepository_SQLitePersistence(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	repo := newStore(t, "sqlite", cfg, logger)
+	require.NoError(t, repo.Save(context.Background(), "abc123", "http://original.url"))
+
+	// Повторное сохранение того же ID отклоняется уникальным индексом.
+	err := repo.Save(context.Background(), "abc123", "http://another.url")
+	require.Error(t, err)
+	assert.Equal(t, "ID already exists", err.Error())
+
+	// Повторное открытие базы не применяет миграции заново и видит сохраненные данные.
+	repo2 := newStore(t, "sqlite", cfg, logger)
+	originalURL, err := repo2.Find(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://original.url", originalURL)
+}
+
+func TestURLRepository_PostgresDuplicateID(t *testing.T) {
+	skipUnavailable(t, "postgres")
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	repo := newStore(t, "postgres", cfg, logger)
+	require.NoError(t, repo.Save(context.Background(), "abc123", "http://original.url"))
+
+	// Нарушение уникальности ID отображается в ErrIDAlreadyExists.
+	err := repo.Save(context.Background(), "abc123", "http://another.url")
+	require.Error(t, err)
+	assert.Equal(t, "ID already exists", err.Error())
+}
+
+func TestURLRepository_LoadFromFile_TruncatedRecord(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	// Последняя запись оборвана, как после падения во время записи.
+	data := `{"uuid":"abc123","original_url":"http://original.url"}` + "\n" + `{"uuid":"def4`
+	require.NoError(t, os.WriteFile(cfg.File.Path, []byte(data), 0o600))
+
+	repo := newStore(t, "file", cfg, logger)
+
+	originalURL, err := repo.Find(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://original.url", originalURL)
+
+	// Новая запись дописывается после последней корректной строки.
+	require.NoError(t, repo.Save(context.Background(), "ghi789", "http://another.url"))
+
+	repo2 := newStore(t, "file", cfg, logger)
+	originalURL, err = repo2.Find(context.Background(), "ghi789")
+	require.NoError(t, err)
+	assert.Equal(t, "http://another.url", originalURL)
+
+	_, err = repo2.Find(context.Background(), "def4")
+	assert.Error(t, err)
+}
+
+func TestURLRepository_LoadFromFile_LegacyFormat(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	data := `[{"uuid":"abc123","original_url":"http://original.url"}]`
+	require.NoError(t, os.WriteFile(cfg.File.Path, []byte(data), 0o600))
+
+	repo := newStore(t, "file", cfg, logger)
+
+	// Файл преобразуется на месте, без компактизации.
+	content, err := os.ReadFile(cfg.File.Path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"uuid":"abc123","original_url":"http://original.url"}`, string(content))
+	assert.NoFileExists(t, cfg.File.Path+".compacting.bak")
+	assert.NoFileExists(t, cfg.File.Path+".snapshot")
+
+	require.NoError(t, repo.Save(context.Background(), "def456", "http://another.url"))
+
+	// После преобразования в журнал обе записи доступны при повторной загрузке.
+	repo2 := newStore(t, "file", cfg, logger)
+	for id, expected := range map[string]string{"abc123": "http://original.url", "def456": "http://another.url"} {
+		originalURL, err := repo2.Find(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, expected, originalURL)
+	}
+}
+
+func TestURLRepository_CanceledContext(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := repo.Save(ctx, "abc123", "http://original.url")
+			require.ErrorIs(t, err, context.Canceled)
+
+			// Отмененное сохранение не должно оставить записи.
+			_, err = repo.Find(context.Background(), "abc123")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+		})
+	}
+}
+
+func TestURLRepository_SaveBatch(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Save(ctx, "abc123", "http://original.url"))
+
+			err := repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "batch1", OriginalURL: "http://batch1.url"},
+				{UUID: "batch2", OriginalURL: "http://batch2.url"},
+			})
+			require.NoError(t, err)
+
+			originalURL, err := repo.Find(ctx, "batch2")
+			require.NoError(t, err)
+			assert.Equal(t, "http://batch2.url", originalURL)
+
+			// Пакет с занятым ID не сохраняется целиком.
+			err = repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "batch3", OriginalURL: "http://batch3.url"},
+				{UUID: "abc123", OriginalURL: "http://another.url"},
+			})
+			require.ErrorIs(t, err, repository.ErrIDAlreadyExists)
+
+			_, err = repo.Find(ctx, "batch3")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+		})
+	}
+}
+
+func TestURLRepository_SaveURL(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	expiresAt := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			link := repository.URLData{
+				UUID:         "limited",
+				OriginalURL:  "http://limited.url",
+				ExpiresAt:    &expiresAt,
+				MaxClicks:    3,
+				PasswordHash: "hash",
+				UserID:       "alice",
+				Exclusive:    true,
+				Flagged:      true,
+			}
+			require.NoError(t, repo.SaveURL(ctx, link))
+
+			saved, err := repo.Get(ctx, "limited")
+			require.NoError(t, err)
+			require.NotNil(t, saved.ExpiresAt)
+			assert.True(t, expiresAt.Equal(*saved.ExpiresAt))
+			saved.ExpiresAt = link.ExpiresAt
+			assert.Equal(t, link, saved)
+
+			err = repo.SaveURL(ctx, repository.URLData{UUID: "limited", OriginalURL: "http://another.url"})
+			require.ErrorIs(t, err, repository.ErrIDAlreadyExists)
+		})
+	}
+}
+
+func TestURLRepository_DuplicateOriginalURL(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Save(ctx, "dup123", "http://duplicate.url"))
+
+			// Повторное сокращение того же URL отклоняется, а существующий ID находится по обратному индексу.
+			err := repo.Save(ctx, "dup456", "http://duplicate.url")
+			require.ErrorIs(t, err, repository.ErrURLAlreadyExists)
+
+			id, err := repo.FindByOriginalURL(ctx, "http://duplicate.url")
+			require.NoError(t, err)
+			assert.Equal(t, "dup123", id)
+
+			_, err = repo.FindByOriginalURL(ctx, "http://missing.url")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+
+			// Пакет с уже сохраненным URL не сохраняется целиком.
+			err = repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "dup789", OriginalURL: "http://fresh.url"},
+				{UUID: "dup000", OriginalURL: "http://duplicate.url"},
+			})
+			require.ErrorIs(t, err, repository.ErrURLAlreadyExists)
+
+			_, err = repo.Find(ctx, "dup789")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+		})
+	}
+
+	// Обратный индекс файлового хранилища восстанавливается при загрузке.
+	repo := newStore(t, "file", cfg, logger)
+	id, err := repo.FindByOriginalURL(context.Background(), "http://duplicate.url")
+	require.NoError(t, err)
+	assert.Equal(t, "dup123", id)
+}
+
+// TestURLRepository_ExclusiveURL тестирует, что ссылки вне дедупликации не мешают сохранять тот же URL
+// и не находятся по обратному индексу.
+func TestURLRepository_ExclusiveURL(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "excl1", OriginalURL: "http://exclusive.url", Exclusive: true},
+				{UUID: "excl2", OriginalURL: "http://exclusive.url", Exclusive: true},
+			}))
+			_, err := repo.FindByOriginalURL(ctx, "http://exclusive.url")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+
+			require.NoError(t, repo.Save(ctx, "shared1", "http://exclusive.url"))
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "excl3", OriginalURL: "http://exclusive.url", Exclusive: true},
+			}))
+			id, err := repo.FindByOriginalURL(ctx, "http://exclusive.url")
+			require.NoError(t, err)
+			assert.Equal(t, "shared1", id)
+
+			url, err := repo.Get(ctx, "excl3")
+			require.NoError(t, err)
+			assert.True(t, url.Exclusive)
+		})
+	}
+
+	// После загрузки файлового хранилища ссылки вне дедупликации не попадают в обратный индекс.
+	repo := newStore(t, "file", cfg, logger)
+	id, err := repo.FindByOriginalURL(context.Background(), "http://exclusive.url")
+	require.NoError(t, err)
+	assert.Equal(t, "shared1", id)
+}
+
+// TestURLRepository_FlaggedURL тестирует сохранение отметки Flagged.
+func TestURLRepository_FlaggedURL(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "flag1", OriginalURL: "https://bit.ly/flagged", Flagged: true},
+			}))
+			require.NoError(t, repo.Save(ctx, "flag2", "http://not-flagged.url"))
+
+			url, err := repo.Get(ctx, "flag1")
+			require.NoError(t, err)
+			assert.True(t, url.Flagged)
+			url, err = repo.Get(ctx, "flag2")
+			require.NoError(t, err)
+			assert.False(t, url.Flagged)
+		})
+	}
+
+	// Отметка восстанавливается при загрузке файлового хранилища.
+	repo := newStore(t, "file", cfg, logger)
+	url, err := repo.Get(context.Background(), "flag1")
+	require.NoError(t, err)
+	assert.True(t, url.Flagged)
+}
+
+func TestURLRepository_ReserveIDs(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			reserver, ok := repo.(repository.IKeyReserver)
+			require.True(t, ok, "storage must support ID reservation")
+			ctx := context.Background()
+
+			require.NoError(t, repo.Save(ctx, "taken1", "http://taken.url"))
+
+			// Занятые и уже зарезервированные ID не резервируются.
+			reserved, err := reserver.ReserveIDs(ctx, []string{"key1", "taken1", "key2"})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"key1", "key2"}, reserved)
+			reserved, err = reserver.ReserveIDs(ctx, []string{"key1", "key3"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key3"}, reserved)
+
+			// Зарезервированный ID может занять только владелец резерва.
+			require.ErrorIs(t, repo.Save(ctx, "key1", "http://reserved.url"), repository.ErrIDAlreadyExists)
+
+			// Сохранение снимает резерв, а освобожденный ID можно зарезервировать снова.
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "key1", OriginalURL: "http://reserved.url", Reserved: true},
+			}))
+			require.NoError(t, reserver.ReleaseIDs(ctx, []string{"key2", "key3"}))
+			reserved, err = reserver.ReserveIDs(ctx, []string{"key1", "key2"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key2"}, reserved)
+			require.NoError(t, reserver.ReleaseIDs(ctx, []string{"key2"}))
+		})
+	}
+}
+
+func TestURLRepository_Expiration(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	hour, twoHours := now.Add(time.Hour), now.Add(2*time.Hour)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			expirer, ok := repo.(repository.IExpirer)
+			require.True(t, ok, "storage must support deleting expired URLs")
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "temp1", OriginalURL: "http://temp1.url", ExpiresAt: &hour},
+				{UUID: "temp2", OriginalURL: "http://temp2.url", ExpiresAt: &twoHours},
+				{UUID: "perm1", OriginalURL: "http://perm1.url"},
+			}))
+
+			url, err := repo.Get(ctx, "temp1")
+			require.NoError(t, err)
+			assert.Equal(t, "http://temp1.url", url.OriginalURL)
+			require.NotNil(t, url.ExpiresAt)
+			assert.True(t, hour.Equal(*url.ExpiresAt), "expires_at: %s", url.ExpiresAt)
+			url, err = repo.Get(ctx, "perm1")
+			require.NoError(t, err)
+			assert.Nil(t, url.ExpiresAt)
+
+			// Еще не истекшая ссылка не удаляется, даже если ее ID задан явно.
+			deleted, err := expirer.DeleteExpired(ctx, now.Add(90*time.Minute), "temp2")
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+
+			deleted, err = expirer.DeleteExpired(ctx, now.Add(90*time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+			_, err = repo.Get(ctx, "temp1")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+			_, err = repo.FindByOriginalURL(ctx, "http://temp1.url")
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+			_, err = repo.Get(ctx, "temp2")
+			require.NoError(t, err)
+
+			// ID и URL удаленной ссылки свободны.
+			require.NoError(t, repo.Save(ctx, "temp1", "http://reused.url"))
+			require.NoError(t, repo.Save(ctx, "reused", "http://temp1.url"))
+		})
+	}
+}
+
+func TestURLRepository_LoadFromFile_Expiration(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	expiresAt := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newStore(t, "file", cfg, logger)
+	require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+		{UUID: "promo", OriginalURL: "http://campaign.url", ExpiresAt: &expiresAt},
+	}))
+
+	// Срок действия сохраняется в журнале.
+	repo2 := newStore(t, "file", cfg, logger)
+	url, err := repo2.Get(ctx, "promo")
+	require.NoError(t, err)
+	require.NotNil(t, url.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+
+	// Удаление пишется в журнал: после перезапуска истекшая запись не восстанавливается.
+	deleted, err := repo2.(repository.IExpirer).DeleteExpired(ctx, expiresAt)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+
+	repo3 := newStore(t, "file", cfg, logger)
+	_, err = repo3.Get(ctx, "promo")
+	require.ErrorIs(t, err, repository.ErrURLNotFound)
+
+	// Занявшая ее ID новая ссылка не оставляет старый URL в обратном индексе.
+	require.NoError(t, repo3.Save(ctx, "promo", "http://next-campaign.url"))
+
+	repo4 := newStore(t, "file", cfg, logger)
+	originalURL, err := repo4.Find(ctx, "promo")
+	require.NoError(t, err)
+	assert.Equal(t, "http://next-campaign.url", originalURL)
+	_, err = repo4.FindByOriginalURL(ctx, "http://campaign.url")
+	require.ErrorIs(t, err, repository.ErrURLNotFound)
+}
+
+func TestURLRepository_Click(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	const maxClicks, clicks = 3, 10
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			counter, ok := repo.(repository.IClickCounter)
+			require.True(t, ok, "storage must support click limits")
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "invite", OriginalURL: "http://invite.url", MaxClicks: maxClicks},
+			}))
+
+			// Одновременные переходы не превышают лимит.
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				counted int
+			)
+			for range clicks {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := counter.Click(ctx, "invite", now)
+					if err == nil {
+						mu.Lock()
+						counted++
+						mu.Unlock()
+						return
+					}
+					assert.ErrorIs(t, err, repository.ErrURLExpired)
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, maxClicks, counted)
+
+			// Последний переход завершил срок действия ссылки.
+			url, err := repo.Get(ctx, "invite")
+			require.NoError(t, err)
+			assert.Equal(t, maxClicks, url.Clicks)
+			require.NotNil(t, url.ExpiresAt)
+			assert.True(t, now.Equal(*url.ExpiresAt), "expires_at: %s", url.ExpiresAt)
+
+			_, err = counter.Click(ctx, "nonexistent", now)
+			require.ErrorIs(t, err, repository.ErrURLNotFound)
+		})
+	}
+}
+
+func TestURLRepository_LoadFromFile_Clicks(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newStore(t, "file", cfg, logger)
+	require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+		{UUID: "invite", OriginalURL: "http://invite.url", MaxClicks: 3},
+	}))
+	for range 2 {
+		_, err := repo.(repository.IClickCounter).Click(ctx, "invite", now)
+		require.NoError(t, err)
+	}
+
+	// Счетчик переходов восстанавливается из журнала.
+	repo2 := newStore(t, "file", cfg, logger)
+	url, err := repo2.Get(ctx, "invite")
+	require.NoError(t, err)
+	assert.Equal(t, 3, url.MaxClicks)
+	assert.Equal(t, 2, url.Clicks)
+
+	counter := repo2.(repository.IClickCounter)
+	_, err = counter.Click(ctx, "invite", now)
+	require.NoError(t, err)
+	_, err = counter.Click(ctx, "invite", now)
+	require.ErrorIs(t, err, repository.ErrURLExpired)
+}
+
+func TestURLRepository_PasswordHash(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	const hash = "$2a$10$abcdefghijklmnopqrstuuNYKBHcqP3v4bBb8XKh9d7n2GPYv/Lea"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "locked", OriginalURL: "http://locked.url", PasswordHash: hash, MaxClicks: 2},
+			}))
+			require.NoError(t, repo.Save(ctx, "open", "http://open.url"))
+
+			url, err := repo.Get(ctx, "locked")
+			require.NoError(t, err)
+			assert.Equal(t, hash, url.PasswordHash)
+			url, err = repo.Get(ctx, "open")
+			require.NoError(t, err)
+			assert.Empty(t, url.PasswordHash)
+
+			// Учет перехода не теряет хеш пароля.
+			if counter, ok := repo.(repository.IClickCounter); ok {
+				url, err = counter.Click(ctx, "locked", now)
+				require.NoError(t, err)
+				assert.Equal(t, hash, url.PasswordHash)
+			}
+
+			if tt.repoType == "file" {
+				repo2 := newStore(t, "file", cfg, logger)
+				url, err = repo2.Get(ctx, "locked")
+				require.NoError(t, err)
+				assert.Equal(t, hash, url.PasswordHash)
+			}
+		})
+	}
+}
+
+func TestURLRepository_FindByUser(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			finder, ok := repo.(repository.IUserURLs)
+			require.True(t, ok, "storage must support user links")
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "b", OriginalURL: "http://b.url", UserID: "alice"},
+				{UUID: "a", OriginalURL: "http://a.url", UserID: "alice"},
+				{UUID: "c", OriginalURL: "http://c.url", UserID: "bob"},
+			}))
+			require.NoError(t, repo.Save(ctx, "anon", "http://anon.url"))
+
+			urls, err := finder.FindByUser(ctx, "alice")
+			require.NoError(t, err)
+			require.Len(t, urls, 2)
+			assert.Equal(t, "a", urls[0].UUID)
+			assert.Equal(t, "http://a.url", urls[0].OriginalURL)
+			assert.Equal(t, "alice", urls[0].UserID)
+			assert.Equal(t, "b", urls[1].UUID)
+
+			urls, err = finder.FindByUser(ctx, "carol")
+			require.NoError(t, err)
+			assert.Empty(t, urls)
+
+			if tt.repoType == "file" {
+				repo2 := newStore(t, "file", cfg, logger)
+				urls, err = repo2.(repository.IUserURLs).FindByUser(ctx, "bob")
+				require.NoError(t, err)
+				require.Len(t, urls, 1)
+				assert.Equal(t, "c", urls[0].UUID)
+			}
+		})
+	}
+}
+
+func TestURLRepository_DeleteUserURLs(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			deleter, ok := repo.(repository.IDeleter)
+			require.True(t, ok, "storage must support deleting links")
+			ctx := context.Background()
+
+			require.NoError(t, repo.SaveBatch(ctx, []repository.URLData{
+				{UUID: "a", OriginalURL: "http://a.url", UserID: "alice"},
+				{UUID: "b", OriginalURL: "http://b.url", UserID: "alice", ExpiresAt: &later},
+				{UUID: "c", OriginalURL: "http://c.url", UserID: "bob"},
+			}))
+			require.NoError(t, repo.Save(ctx, "anon", "http://anon.url"))
+
+			// Удаляются только свои ссылки, повторы и чужие ID пропускаются.
+			deleted, err := deleter.DeleteUserURLs(ctx, []repository.UserLink{
+				{UserID: "alice", ID: "a"},
+				{UserID: "alice", ID: "b"},
+				{UserID: "alice", ID: "a"},
+				{UserID: "alice", ID: "c"},
+				{UserID: "alice", ID: "anon"},
+				{UserID: "alice", ID: "nonexistent"},
+				{UserID: "", ID: "anon"},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 2, deleted)
+
+			check := func(repo repository.IURLRepository) {
+				// Удаление не меняет срок действия ссылки.
+				for _, id := range []string{"a", "b"} {
+					url, err := repo.Get(ctx, id)
+					require.NoError(t, err)
+					assert.True(t, url.Deleted, id)
+					assert.False(t, url.Expired(now), id)
+				}
+				// Удаленная ссылка выходит из дедупликации.
+				_, err := repo.FindByOriginalURL(ctx, "http://a.url")
+				require.ErrorIs(t, err, repository.ErrURLNotFound)
+				for _, id := range []string{"c", "anon"} {
+					url, err := repo.Get(ctx, id)
+					require.NoError(t, err)
+					assert.False(t, url.Deleted, id)
+					assert.False(t, url.Expired(now), id)
+				}
+			}
+			check(repo)
+
+			deleted, err = deleter.DeleteUserURLs(ctx, []repository.UserLink{{UserID: "alice", ID: "a"}})
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+
+			// Удаленные ссылки не удаляются вместе с истекшими, даже когда их срок действия истек.
+			expirer, ok := repo.(repository.IExpirer)
+			require.True(t, ok, "storage must support expiration")
+			deleted, err = expirer.DeleteExpired(ctx, later.Add(time.Hour))
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+			url, err := repo.Get(ctx, "b")
+			require.NoError(t, err)
+			assert.True(t, url.Deleted)
+
+			if tt.repoType == "file" {
+				repo2 := newStore(t, "file", cfg, logger)
+				check(repo2)
+			}
+		})
+	}
+}
+
+func TestURLRepository_APIKeys(t *testing.T) {
+	cfg := setup(t)
+	logger := zaptest.NewLogger(t)
+	created := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	revoked := created.Add(time.Hour)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipUnavailable(t, tt.repoType)
+			repo := newStore(t, tt.repoType, cfg, logger)
+			keys, ok := repo.(repository.IAPIKeyStore)
+			require.True(t, ok, "storage must support API keys")
+			ctx := context.Background()
+
+			bot := repository.APIKey{ID: "k1", Hash: "h1", Owner: "alice", Name: "CI",
+				Scopes: []string{"shorten", "read"}, CreatedAt: created}
+			admin := repository.APIKey{ID: "k2", Hash: "h2", Owner: "root", Scopes: []string{"admin"},
+				CreatedAt: created.Add(time.Minute)}
+			require.NoError(t, keys.SaveAPIKey(ctx, admin))
+			require.NoError(t, keys.SaveAPIKey(ctx, bot))
+			require.ErrorIs(t, keys.SaveAPIKey(ctx, bot), repository.ErrIDAlreadyExists)
+
+			require.NoError(t, keys.RevokeAPIKey(ctx, "k1", revoked))
+			// Повторный отзыв не меняет момент отзыва.
+			require.NoError(t, keys.RevokeAPIKey(ctx, "k1", revoked.Add(time.Hour)))
+			require.ErrorIs(t, keys.RevokeAPIKey(ctx, "nonexistent", revoked), repository.ErrAPIKeyNotFound)
+
+			check := func(keys repository.IAPIKeyStore) {
+				got, err := keys.GetAPIKey(ctx, "k1")
+				require.NoError(t, err)
+				require.NotNil(t, got.RevokedAt)
+				assert.True(t, revoked.Equal(*got.RevokedAt))
+				assert.Equal(t, []string{"shorten", "read"}, got.Scopes)
+				assert.Equal(t, "alice", got.Owner)
+
+				_, err = keys.GetAPIKey(ctx, "nonexistent")
+				require.ErrorIs(t, err, repository.ErrAPIKeyNotFound)
+
+				list, err := keys.ListAPIKeys(ctx)
+				require.NoError(t, err)
+				require.Len(t, list, 2)
+				assert.Equal(t, "k1", list[0].ID)
+				assert.Equal(t, "k2", list[1].ID)
+				assert.False(t, list[1].Revoked())
+			}
+			check(keys)
+
+			if tt.repoType == "file" {
+				repo2 := newStore(t, "file", cfg, logger)
+				keys2, ok := repo2.(repository.IAPIKeyStore)
+				require.True(t, ok)
+				check(keys2)
+			}
+		})
+	}
 }
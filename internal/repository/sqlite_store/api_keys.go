@@ -0,0 +1,108 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"linkshrink/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// apiKeyColumns - столбцы API-ключа в порядке, в котором их читает scanAPIKey.
+const apiKeyColumns = `id, hash, owner, name, scopes, created_at, revoked_at`
+
+// scopesSeparator разделяет права ключа в столбце scopes.
+const scopesSeparator = ","
+
+// SaveAPIKey сохраняет новый API-ключ.
+func (r *SQLiteStore) SaveAPIKey(ctx context.Context, key repository.APIKey) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Hash, key.Owner, key.Name, strings.Join(key.Scopes, scopesSeparator), key.CreatedAt.UTC(),
+		utcTime(key.RevokedAt))
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && isUniqueViolation(sqliteErr) {
+			return repository.ErrIDAlreadyExists
+		}
+		return fmt.Errorf("не удалось сохранить API-ключ: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey ищет API-ключ по ID.
+func (r *SQLiteStore) GetAPIKey(ctx context.Context, id string) (repository.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.APIKey{}, repository.ErrAPIKeyNotFound
+		}
+		return repository.APIKey{}, fmt.Errorf("не удалось найти API-ключ: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке создания.
+func (r *SQLiteStore) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить API-ключи: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Error("Ошибка при закрытии результата запроса", zap.Error(err))
+		}
+	}()
+
+	var keys []repository.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать API-ключи: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ id в момент now. Момент отзыва уже отозванного ключа не меняется.
+func (r *SQLiteStore) RevokeAPIKey(ctx context.Context, id string, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`,
+		now.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать API-ключ: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать API-ключ: %w", err)
+	}
+	if n == 0 {
+		return repository.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// scanAPIKey читает API-ключ из строки результата запроса столбцов apiKeyColumns.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (repository.APIKey, error) {
+	var key repository.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Hash, &key.Owner, &key.Name, &scopes, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		return repository.APIKey{}, fmt.Errorf("не удалось прочитать API-ключ: %w", err)
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, scopesSeparator)
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,98 @@
+package sqlitestore
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// migrations - последовательные изменения схемы базы.
+// Номер примененной миграции хранится в PRAGMA user_version,
+// поэтому существующие миграции менять нельзя - только добавлять новые в конец.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		id           TEXT      NOT NULL,
+		original_url TEXT      NOT NULL,
+		created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_id_idx ON urls (id)`,
+	// Обратный индекс для дедупликации. Если в базе уже есть повторяющиеся
+	// оригинальные URL, миграция завершится ошибкой и их нужно будет удалить вручную.
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url)`,
+	// ID, зарезервированные пулом ключей.
+	`CREATE TABLE IF NOT EXISTS reserved_ids (
+		id          TEXT      PRIMARY KEY,
+		reserved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Срок действия ссылки, NULL - ссылка бессрочная.
+	`ALTER TABLE urls ADD COLUMN expires_at TIMESTAMP`,
+	`CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL`,
+	// Лимит и счетчик переходов, 0 - без ограничения.
+	`ALTER TABLE urls ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE urls ADD COLUMN clicks INTEGER NOT NULL DEFAULT 0`,
+	// bcrypt-хеш пароля ссылки, пустой - ссылка без пароля.
+	`ALTER TABLE urls ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`,
+	// Пользователь, создавший ссылку, пустой - ссылка создана анонимно.
+	`ALTER TABLE urls ADD COLUMN user_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id) WHERE user_id <> ''`,
+	// Пометка удаления ссылки владельцем.
+	`ALTER TABLE urls ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT 0`,
+	// API-ключи: хранится только хеш секретной части ключа, права перечислены через запятую.
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id         TEXT      PRIMARY KEY,
+		hash       TEXT      NOT NULL,
+		owner      TEXT      NOT NULL,
+		name       TEXT      NOT NULL DEFAULT '',
+		scopes     TEXT      NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	)`,
+	// Ссылки, не участвующие в дедупликации (например, приватные): обратный индекс
+	// пересоздается частичным, чтобы такая ссылка не мешала сократить тот же URL заново.
+	`ALTER TABLE urls ADD COLUMN is_exclusive BOOLEAN NOT NULL DEFAULT 0`,
+	`DROP INDEX IF EXISTS urls_original_url_idx`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url) WHERE NOT is_exclusive`,
+	// Отметка ссылок на другие сервисы сокращения, сохраненных при политике flag.
+	`ALTER TABLE urls ADD COLUMN is_flagged BOOLEAN NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS urls_flagged_idx ON urls (id) WHERE is_flagged`,
+	// Ссылки со сроком действия не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = 1 WHERE expires_at IS NOT NULL`,
+	// Ссылки с лимитом переходов не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = 1 WHERE max_clicks > 0`,
+	// Ссылки с паролем не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = 1 WHERE password_hash <> ''`,
+	// Удаленные ссылки не участвуют в дедупликации.
+	`UPDATE urls SET is_exclusive = 1 WHERE is_deleted`,
+}
+
+// migrate применяет к базе миграции, которые еще не были применены.
+func (r *SQLiteStore) migrate() error {
+	var version int
+	if err := r.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return fmt.Errorf("не удалось начать транзакцию: %w", err)
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("не удалось применить миграцию %d: %w", i+1, err)
+		}
+		// PRAGMA не поддерживает параметры запроса, версия - целое число.
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("не удалось обновить версию схемы: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("не удалось зафиксировать миграцию %d: %w", i+1, err)
+		}
+		r.logger.Info("Применена миграция схемы", zap.Int("version", i+1))
+	}
+
+	return nil
+}
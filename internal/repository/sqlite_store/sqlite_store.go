@@ -0,0 +1,416 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+func init() {
+	repository.Register("sqlite", func(cfg *config.Config, log logger.Logger) (repository.IURLRepository, error) {
+		store, err := NewSQLiteStore(cfg.SQLite.Path, log)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	})
+}
+
+type ISQLiteStore interface {
+	Save(ctx context.Context, id string, originalURL string) error
+	SaveURL(ctx context.Context, url repository.URLData) error
+	SaveBatch(ctx context.Context, urls []repository.URLData) error
+	Find(ctx context.Context, id string) (string, error)
+	Get(ctx context.Context, id string) (repository.URLData, error)
+	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+	DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error)
+	Click(ctx context.Context, id string, now time.Time) (repository.URLData, error)
+	ReserveIDs(ctx context.Context, ids []string) ([]string, error)
+	ReleaseIDs(ctx context.Context, ids []string) error
+	Close() error
+}
+
+// SQLiteStore хранит пары ID и оригинальных URL в базе SQLite.
+type SQLiteStore struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// busyTimeout - время ожидания освобождения блокировки базы другим соединением, мс.
+const busyTimeout = 5000
+
+// NewSQLiteStore открывает (или создает) базу SQLite по пути dbPath и применяет миграции схемы.
+func NewSQLiteStore(dbPath string, log logger.Logger) (*SQLiteStore, error) {
+	componentLogger := log.With(zap.String("component", "SQLiteStore"))
+
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", dbPath, busyTimeout)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу: %w", err)
+	}
+
+	repo := &SQLiteStore{db: db, logger: componentLogger}
+	if err := repo.migrate(); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			componentLogger.Error("Ошибка при закрытии базы", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// Save сохраняет оригинальный URL по ID.
+func (r *SQLiteStore) Save(ctx context.Context, id string, originalURL string) error {
+	return r.SaveURL(ctx, repository.URLData{UUID: id, OriginalURL: originalURL})
+}
+
+// SaveURL сохраняет запись со всеми ее полями. Снятие резерва и вставка выполняются
+// в одной транзакции, как в SaveBatch.
+func (r *SQLiteStore) SaveURL(ctx context.Context, url repository.URLData) error {
+	return r.SaveBatch(ctx, []repository.URLData{url})
+}
+
+// SaveBatch сохраняет пакет записей в одной транзакции: при любой ошибке не сохраняется ничего.
+// Резерв с сохраненных ID снимается в той же транзакции; действующий резерв может снять только
+// запись с признаком Reserved.
+func (r *SQLiteStore) SaveBatch(ctx context.Context, urls []repository.URLData) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает.
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO urls
+		(id, original_url, created_at, expires_at, max_clicks, password_hash, user_id, is_exclusive, is_flagged)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("не удалось подготовить запрос: %w", err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			r.logger.Error("Ошибка при закрытии запроса", zap.Error(err))
+		}
+	}()
+
+	releaseStmt, err := tx.PrepareContext(ctx, `DELETE FROM reserved_ids WHERE id = ? RETURNING reserved_at`)
+	if err != nil {
+		return fmt.Errorf("не удалось подготовить запрос: %w", err)
+	}
+	defer func() {
+		if err := releaseStmt.Close(); err != nil {
+			r.logger.Error("Ошибка при закрытии запроса", zap.Error(err))
+		}
+	}()
+
+	createdAt := time.Now().UTC()
+	for _, url := range urls {
+		// Снятие чужого резерва откатывается вместе с транзакцией.
+		var reservedAt time.Time
+		err := releaseStmt.QueryRowContext(ctx, url.UUID).Scan(&reservedAt)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("не удалось снять резерв с ID: %w", err)
+		case !url.Reserved && createdAt.Sub(reservedAt) < repository.ReservationTTL:
+			return repository.ErrIDAlreadyExists
+		}
+
+		_, err = stmt.ExecContext(ctx, url.UUID, url.OriginalURL, createdAt, utcTime(url.ExpiresAt),
+			url.MaxClicks, url.PasswordHash, url.UserID, url.Exclusive, url.Flagged)
+		if err != nil {
+			return mapInsertError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// Find ищет оригинальный URL по ID.
+func (r *SQLiteStore) Find(ctx context.Context, id string) (string, error) {
+	var originalURL string
+	err := r.db.QueryRowContext(ctx, `SELECT original_url FROM urls WHERE id = ?`, id).Scan(&originalURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", repository.ErrURLNotFound
+		}
+		return "", fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return originalURL, nil
+}
+
+// Get ищет запись по ID.
+func (r *SQLiteStore) Get(ctx context.Context, id string) (repository.URLData, error) {
+	url, err := scanURL(r.db.QueryRowContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.URLData{}, repository.ErrURLNotFound
+		}
+		return repository.URLData{}, fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return url, nil
+}
+
+// Click засчитывает переход по ссылке id одним запросом UPDATE: проверка срока действия
+// и лимита выполняется в том же запросе, поэтому одновременные переходы не превышают лимит.
+// Последний разрешенный переход устанавливает expires_at в now.
+func (r *SQLiteStore) Click(ctx context.Context, id string, now time.Time) (repository.URLData, error) {
+	now = now.UTC()
+	url, err := scanURL(r.db.QueryRowContext(ctx, `
+		UPDATE urls SET
+			clicks = clicks + 1,
+			expires_at = CASE WHEN max_clicks > 0 AND clicks + 1 >= max_clicks THEN ? ELSE expires_at END
+		WHERE id = ? AND (expires_at IS NULL OR expires_at > ?)
+		RETURNING `+urlColumns, now, id, now))
+	if err == nil {
+		return url, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return repository.URLData{}, fmt.Errorf("не удалось засчитать переход: %w", err)
+	}
+
+	// Запись не обновлена: ссылки нет или ее срок действия истек.
+	if _, err := r.Get(ctx, id); err != nil {
+		return repository.URLData{}, err
+	}
+	return repository.URLData{}, repository.ErrURLExpired
+}
+
+// urlColumns - столбцы записи в порядке, в котором их читает scanURL.
+const urlColumns = `id, original_url, expires_at, max_clicks, clicks, password_hash, user_id, is_deleted,
+	is_exclusive, is_flagged`
+
+// scanURL читает запись из строки результата запроса столбцов urlColumns.
+func scanURL(row interface{ Scan(dest ...any) error }) (repository.URLData, error) {
+	var url repository.URLData
+	var expiresAt sql.NullTime
+	err := row.Scan(&url.UUID, &url.OriginalURL, &expiresAt, &url.MaxClicks, &url.Clicks, &url.PasswordHash, &url.UserID,
+		&url.Deleted, &url.Exclusive, &url.Flagged)
+	if err != nil {
+		return repository.URLData{}, fmt.Errorf("не удалось прочитать запись: %w", err)
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+	return url, nil
+}
+
+// FindByUser возвращает ссылки пользователя userID, упорядоченные по ID.
+func (r *SQLiteStore) FindByUser(ctx context.Context, userID string) ([]repository.URLData, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти ссылки пользователя: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Error("Ошибка при закрытии результата запроса", zap.Error(err))
+		}
+	}()
+
+	var urls []repository.URLData
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ссылки пользователя: %w", err)
+	}
+	return urls, nil
+}
+
+// FindByOriginalURL ищет ID по оригинальному URL среди ссылок, участвующих в дедупликации.
+func (r *SQLiteStore) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	var id string
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM urls WHERE original_url = ? AND NOT is_exclusive`,
+		originalURL).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", repository.ErrURLNotFound
+		}
+		return "", fmt.Errorf("не удалось найти URL: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteExpired удаляет записи, срок действия которых истек не позже before.
+// Если заданы ids, проверяются только они.
+func (r *SQLiteStore) DeleteExpired(ctx context.Context, before time.Time, ids ...string) (int, error) {
+	before = before.UTC()
+	if len(ids) == 0 {
+		res, err := r.db.ExecContext(ctx,
+			`DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at <= ? AND is_deleted = 0`, before)
+		if err != nil {
+			return 0, fmt.Errorf("не удалось удалить истекшие ссылки: %w", err)
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("не удалось получить количество удаленных ссылок: %w", err)
+		}
+		return int(deleted), nil
+	}
+
+	deleted := 0
+	err := r.inTx(ctx, `DELETE FROM urls WHERE id = ? AND expires_at IS NOT NULL AND expires_at <= ? AND is_deleted = 0`,
+		func(stmt *sql.Stmt) error {
+			for _, id := range ids {
+				res, err := stmt.ExecContext(ctx, id, before)
+				if err != nil {
+					return fmt.Errorf("не удалось удалить истекшую ссылку: %w", err)
+				}
+				if n, err := res.RowsAffected(); err == nil {
+					deleted += int(n)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// DeleteUserURLs помечает удаленными ссылки из links, принадлежащие указанным пользователям,
+// в одной транзакции. Владелец проверяется в условии UPDATE.
+func (r *SQLiteStore) DeleteUserURLs(ctx context.Context, links []repository.UserLink) (int, error) {
+	deleted := 0
+	err := r.inTx(ctx, `UPDATE urls SET is_deleted = 1, is_exclusive = 1
+		WHERE id = ? AND user_id = ? AND user_id <> '' AND is_deleted = 0`,
+		func(stmt *sql.Stmt) error {
+			for _, link := range links {
+				res, err := stmt.ExecContext(ctx, link.ID, link.UserID)
+				if err != nil {
+					return fmt.Errorf("не удалось удалить ссылку: %w", err)
+				}
+				if n, err := res.RowsAffected(); err == nil {
+					deleted += int(n)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// ReserveIDs резервирует те из ids, которые не заняты и не зарезервированы.
+// Устаревший резерв (см. repository.ReservationTTL) занимается заново.
+func (r *SQLiteStore) ReserveIDs(ctx context.Context, ids []string) ([]string, error) {
+	reserved := make([]string, 0, len(ids))
+	err := r.inTx(ctx, `INSERT INTO reserved_ids (id, reserved_at)
+		SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM urls WHERE id = ?)
+		ON CONFLICT (id) DO UPDATE SET reserved_at = excluded.reserved_at WHERE reserved_at <= ?`,
+		func(stmt *sql.Stmt) error {
+			reservedAt := time.Now().UTC()
+			staleBefore := reservedAt.Add(-repository.ReservationTTL)
+			for _, id := range ids {
+				res, err := stmt.ExecContext(ctx, id, reservedAt, id, staleBefore)
+				if err != nil {
+					return fmt.Errorf("не удалось зарезервировать ID: %w", err)
+				}
+				if n, err := res.RowsAffected(); err == nil && n > 0 {
+					reserved = append(reserved, id)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// ReleaseIDs снимает резерв с неиспользованных ID.
+func (r *SQLiteStore) ReleaseIDs(ctx context.Context, ids []string) error {
+	return r.inTx(ctx, `DELETE FROM reserved_ids WHERE id = ?`, func(stmt *sql.Stmt) error {
+		for _, id := range ids {
+			if _, err := stmt.ExecContext(ctx, id); err != nil {
+				return fmt.Errorf("не удалось освободить ID: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// inTx выполняет fn с подготовленным запросом query в транзакции и фиксирует ее, если fn не вернула ошибку.
+func (r *SQLiteStore) inTx(ctx context.Context, query string, fn func(stmt *sql.Stmt) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает.
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("не удалось подготовить запрос: %w", err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			r.logger.Error("Ошибка при закрытии запроса", zap.Error(err))
+		}
+	}()
+
+	if err := fn(stmt); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает соединения с базой.
+func (r *SQLiteStore) Close() error {
+	if err := r.db.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть базу: %w", err)
+	}
+	return nil
+}
+
+// utcTime приводит необязательное время к UTC: SQLite сравнивает время как строки,
+// поэтому все значения должны храниться в одном часовом поясе.
+func utcTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// mapInsertError преобразует нарушение уникальности в ошибку репозитория.
+// SQLite не сообщает имя индекса, поэтому нарушенный столбец определяется по тексту ошибки.
+func mapInsertError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && isUniqueViolation(sqliteErr) {
+		if strings.Contains(sqliteErr.Error(), "urls.original_url") {
+			return repository.ErrURLAlreadyExists
+		}
+		return repository.ErrIDAlreadyExists
+	}
+	return fmt.Errorf("не удалось сохранить URL: %w", err)
+}
+
+func isUniqueViolation(err sqlite3.Error) bool {
+	return err.ExtendedCode == sqlite3.ErrConstraintUnique ||
+		err.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
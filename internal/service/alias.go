@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+var (
+	ErrInvalidAlias = errors.New("invalid alias")
+	ErrAliasTaken   = errors.New("alias already taken")
+)
+
+// reservedAliases - имена, которые нельзя занять алиасом: пути API и служебные страницы.
+// Сравнение без учета регистра.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"admin":   {},
+	"health":  {},
+	"ping":    {},
+	"static":  {},
+	"metrics": {},
+}
+
+// validateAlias проверяет алиас, выбранный пользователем вместо сгенерированного ID:
+// длину, допустимые символы (латинские буквы, цифры, "-" и "_") и зарезервированные имена.
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return fmt.Errorf("%w: length must be from %d to %d characters", ErrInvalidAlias, minAliasLength, maxAliasLength)
+	}
+	for _, c := range alias {
+		if !isAliasChar(c) {
+			return fmt.Errorf("%w: character %q is not allowed", ErrInvalidAlias, c)
+		}
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+func isAliasChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"linkshrink/internal/auth"
+	"linkshrink/internal/repository"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxAPIKeyNameLength - наибольшая длина описания API-ключа в символах.
+	MaxAPIKeyNameLength = 100
+	// BootstrapKeyID - ID, под которым в запросах виден административный ключ из конфигурации.
+	BootstrapKeyID = "bootstrap"
+)
+
+var (
+	// ErrAPIKeyNotFound возвращается при отзыве неизвестного API-ключа.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIKeyRequest возвращается для некорректных параметров нового API-ключа.
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+)
+
+type IAPIKeyService interface {
+	Create(ctx context.Context, owner string, name string, scopes []string) (CreatedAPIKey, error)
+	List(ctx context.Context) ([]APIKeyInfo, error)
+	Revoke(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, token string) (auth.Identity, error)
+}
+
+// APIKeyInfo - API-ключ без секрета.
+type APIKeyInfo struct {
+	ID        string
+	Owner     string
+	Name      string
+	Scopes    []auth.Scope
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// CreatedAPIKey - только что созданный API-ключ. Token показывается клиенту один раз:
+// сервис хранит только хеш секрета и восстановить ключ не может.
+type CreatedAPIKey struct {
+	APIKeyInfo
+	Token string
+}
+
+// APIKeyServiceConfig - параметры сервиса API-ключей. Нулевые значения заменяются значениями по умолчанию.
+type APIKeyServiceConfig struct {
+	// AdminKey - административный ключ из конфигурации, которым создаются первые API-ключи.
+	// Пустой - административный ключ не используется.
+	AdminKey string
+	Now      func() time.Time // Источник текущего времени, по умолчанию time.Now
+}
+
+// APIKeyService выдает, отзывает и проверяет API-ключи программных клиентов.
+type APIKeyService struct {
+	repo         repository.IAPIKeyStore // nil, если хранилище не поддерживает API-ключи
+	adminKeyHash string
+	now          func() time.Time
+}
+
+// NewAPIKeyService создает сервис API-ключей с хранилищем repo. Если repo равен nil,
+// принимается только административный ключ из конфигурации.
+func NewAPIKeyService(repo repository.IAPIKeyStore, cfg APIKeyServiceConfig) (*APIKeyService, error) {
+	if cfg.AdminKey != "" && len(cfg.AdminKey) < auth.MinSecretLength {
+		return nil, fmt.Errorf("%w: admin API key is shorter than %d bytes", auth.ErrInvalidSecret, auth.MinSecretLength)
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	service := &APIKeyService{repo: repo, now: cfg.Now}
+	if cfg.AdminKey != "" {
+		service.adminKeyHash = auth.HashAPIKeySecret(cfg.AdminKey)
+	}
+	return service, nil
+}
+
+// Create выдает API-ключ с правами scopes от имени пользователя owner. Если owner пустой,
+// ключ получает нового пользователя: ссылки, созданные им, видны только по этому ключу.
+// Непустой owner должен быть владельцем уже выданного ключа (например, при замене ключа): иначе
+// администратор мог бы выдать себе ключ от имени пользователя cookie и получить доступ к его ссылкам.
+func (s *APIKeyService) Create(ctx context.Context, owner string, name string, scopes []string) (CreatedAPIKey, error) {
+	if s.repo == nil {
+		return CreatedAPIKey{}, fmt.Errorf("%w: storage doesn't support API keys", ErrInternalServer)
+	}
+	if utf8.RuneCountInString(name) > MaxAPIKeyNameLength {
+		return CreatedAPIKey{}, fmt.Errorf("%w: name is longer than %d characters",
+			ErrInvalidAPIKeyRequest, MaxAPIKeyNameLength)
+	}
+	parsed, err := auth.ParseScopes(scopes)
+	if err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("%w: %w", ErrInvalidAPIKeyRequest, err)
+	}
+	if len(parsed) == 0 {
+		return CreatedAPIKey{}, fmt.Errorf("%w: at least one scope expected", ErrInvalidAPIKeyRequest)
+	}
+	if owner == "" {
+		if owner, err = auth.NewUserID(); err != nil {
+			return CreatedAPIKey{}, fmt.Errorf("%w: %w", ErrInternalServer, err)
+		}
+	} else if err := s.checkOwner(ctx, owner); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	id, secret, token, err := auth.NewAPIKey()
+	if err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("%w: %w", ErrInternalServer, err)
+	}
+	key := repository.APIKey{
+		ID:        id,
+		Hash:      auth.HashAPIKeySecret(secret),
+		Owner:     owner,
+		Name:      name,
+		Scopes:    make([]string, 0, len(parsed)),
+		CreatedAt: s.now().UTC(),
+	}
+	for _, scope := range parsed {
+		key.Scopes = append(key.Scopes, string(scope))
+	}
+	if err := s.repo.SaveAPIKey(ctx, key); err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("%w: failed to save API key: %w", ErrInternalServer, err)
+	}
+	return CreatedAPIKey{APIKeyInfo: apiKeyInfo(key), Token: token}, nil
+}
+
+// checkOwner проверяет, что пользователю owner уже выдавались API-ключи.
+func (s *APIKeyService) checkOwner(ctx context.Context, owner string) error {
+	keys, err := s.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: failed to list API keys: %w", ErrInternalServer, err)
+	}
+	for _, key := range keys {
+		if key.Owner == owner {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: owner %q has no API keys", ErrInvalidAPIKeyRequest, owner)
+}
+
+// List возвращает все API-ключи, включая отозванные, в порядке создания.
+func (s *APIKeyService) List(ctx context.Context) ([]APIKeyInfo, error) {
+	if s.repo == nil {
+		return nil, fmt.Errorf("%w: storage doesn't support API keys", ErrInternalServer)
+	}
+	keys, err := s.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list API keys: %w", ErrInternalServer, err)
+	}
+	result := make([]APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, apiKeyInfo(key))
+	}
+	return result, nil
+}
+
+// Revoke отзывает API-ключ id. Отозванный ключ перестает приниматься сразу.
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	if s.repo == nil {
+		return fmt.Errorf("%w: storage doesn't support API keys", ErrInternalServer)
+	}
+	err := s.repo.RevokeAPIKey(ctx, id, s.now().UTC())
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to revoke API key: %w", ErrInternalServer, err)
+	}
+	return nil
+}
+
+// Authenticate проверяет API-ключ token и возвращает пользователя, от имени которого он действует.
+// Для неизвестного, отозванного или искаженного ключа возвращается auth.ErrInvalidAPIKey.
+func (s *APIKeyService) Authenticate(ctx context.Context, token string) (auth.Identity, error) {
+	if s.adminKeyHash != "" && hashEqual(auth.HashAPIKeySecret(token), s.adminKeyHash) {
+		return auth.Identity{KeyID: BootstrapKeyID, Scopes: []auth.Scope{auth.ScopeAdmin}}, nil
+	}
+
+	id, secret, err := auth.ParseAPIKey(token)
+	if err != nil || s.repo == nil {
+		return auth.Identity{}, auth.ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return auth.Identity{}, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return auth.Identity{}, fmt.Errorf("%w: failed to get API key: %w", ErrInternalServer, err)
+	}
+	if !hashEqual(auth.HashAPIKeySecret(secret), key.Hash) || key.Revoked() {
+		return auth.Identity{}, auth.ErrInvalidAPIKey
+	}
+	return auth.Identity{UserID: key.Owner, KeyID: key.ID, Scopes: apiKeyInfo(key).Scopes}, nil
+}
+
+// hashEqual сравнивает хеши за время, не зависящее от совпадающего префикса.
+func hashEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func apiKeyInfo(key repository.APIKey) APIKeyInfo {
+	scopes := make([]auth.Scope, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, auth.Scope(scope))
+	}
+	return APIKeyInfo{
+		ID:        key.ID,
+		Owner:     key.Owner,
+		Name:      key.Name,
+		Scopes:    scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"linkshrink/internal/auth"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const testAdminKey = "admin-key-admin-key-admin-key-admin-key"
+
+func newAPIKeyService(t *testing.T, clock *fakeClock) *service.APIKeyService {
+	t.Helper()
+	srv, err := service.NewAPIKeyService(memorystore.NewMemoryStore(zaptest.NewLogger(t)),
+		service.APIKeyServiceConfig{AdminKey: testAdminKey, Now: clock.Now})
+	require.NoError(t, err)
+	return srv
+}
+
+// TestAPIKeyService тестирует, что выданный ключ действует от имени владельца с выданными правами,
+// а после отзыва перестает приниматься.
+func TestAPIKeyService(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	srv := newAPIKeyService(t, clock)
+	ctx := context.Background()
+
+	// Ключ без владельца получает нового пользователя.
+	created, err := srv.Create(ctx, "", "CI", []string{"read", "shorten"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.Owner)
+	assert.Equal(t, []auth.Scope{auth.ScopeShorten, auth.ScopeRead}, created.Scopes)
+	assert.Equal(t, clock.now, created.CreatedAt)
+
+	identity, err := srv.Authenticate(ctx, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.Identity{UserID: created.Owner, KeyID: created.ID, Scopes: created.Scopes}, identity)
+
+	// Владелец выданного ключа может получить еще один ключ.
+	clock.Advance(time.Second)
+	bot, err := srv.Create(ctx, created.Owner, "", []string{"delete"})
+	require.NoError(t, err)
+	assert.Equal(t, created.Owner, bot.Owner)
+
+	// Искаженный ключ не принимается.
+	for _, token := range []string{created.Token + "x", strings.Replace(created.Token, created.ID, bot.ID, 1), "garbage"} {
+		_, err := srv.Authenticate(ctx, token)
+		require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+	}
+
+	clock.Advance(time.Minute)
+	require.NoError(t, srv.Revoke(ctx, created.ID))
+	_, err = srv.Authenticate(ctx, created.Token)
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+	require.ErrorIs(t, srv.Revoke(ctx, "nonexistent"), service.ErrAPIKeyNotFound)
+
+	keys, err := srv.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, created.ID, keys[0].ID)
+	require.NotNil(t, keys[0].RevokedAt)
+	assert.Equal(t, clock.now, *keys[0].RevokedAt)
+	assert.Nil(t, keys[1].RevokedAt)
+}
+
+// TestAPIKeyService_AdminKey тестирует, что ключ из конфигурации дает только право управления ключами.
+func TestAPIKeyService_AdminKey(t *testing.T) {
+	srv := newAPIKeyService(t, &fakeClock{now: time.Now()})
+
+	identity, err := srv.Authenticate(context.Background(), testAdminKey)
+	require.NoError(t, err)
+	assert.Equal(t, service.BootstrapKeyID, identity.KeyID)
+	assert.Equal(t, []auth.Scope{auth.ScopeAdmin}, identity.Scopes)
+
+	_, err = service.NewAPIKeyService(nil, service.APIKeyServiceConfig{AdminKey: "short"})
+	require.ErrorIs(t, err, auth.ErrInvalidSecret)
+}
+
+// TestAPIKeyService_InvalidRequest тестирует отклонение некорректных параметров нового ключа.
+func TestAPIKeyService_InvalidRequest(t *testing.T) {
+	srv := newAPIKeyService(t, &fakeClock{now: time.Now()})
+
+	tests := []struct {
+		name    string
+		owner   string
+		keyName string
+		scopes  []string
+	}{
+		{name: "no scopes"},
+		{name: "unknown scope", scopes: []string{"read", "write"}},
+		{name: "long name", keyName: strings.Repeat("x", service.MaxAPIKeyNameLength+1), scopes: []string{"read"}},
+		{name: "unknown owner", owner: "alice", scopes: []string{"read"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srv.Create(context.Background(), tt.owner, tt.keyName, tt.scopes)
+			require.ErrorIs(t, err, service.ErrInvalidAPIKeyRequest)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/utils/logger"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+var (
+	// ErrURLBlocked возвращается для URL, домен которых занесен в блок-лист.
+	ErrURLBlocked       = errors.New("URL is blocked")
+	ErrInvalidBlocklist = errors.New("invalid blocklist")
+)
+
+// Blocklist - список заблокированных доменов, загружаемый из файла.
+// Каждая непустая строка файла, кроме комментариев "#", - одно правило:
+//
+//	example.com      - точное совпадение хоста;
+//	*.example.com    - любой поддомен example.com (сам example.com не включается);
+//	/^phish\d+\./    - регулярное выражение для хоста.
+//
+// Хосты сравниваются в нижнем регистре, международные домены - в punycode.
+// Правила перечитываются при изменении файла или по сигналу (см. Watch) без перезапуска сервиса.
+type Blocklist struct {
+	path   string
+	logger logger.Logger
+	rules  atomic.Pointer[blockRules]
+
+	mu      sync.Mutex // Исключает одновременную перезагрузку и защищает modTime и size
+	modTime time.Time  // Время изменения загруженного файла
+	size    int64      // Размер загруженного файла
+}
+
+// blockRules - разобранные правила блок-листа. После загрузки не изменяются.
+type blockRules struct {
+	exact    map[string]struct{}
+	wildcard map[string]struct{} // Домены, все поддомены которых заблокированы
+	patterns []*regexp.Regexp
+}
+
+// NewBlocklist загружает блок-лист из файла path.
+// Ошибка в файле при запуске - ошибка конфигурации, поэтому она возвращается, а не игнорируется.
+func NewBlocklist(path string, log logger.Logger) (*Blocklist, error) {
+	b := &Blocklist{
+		path:   path,
+		logger: log.With(zap.String("component", "Blocklist")),
+	}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Blocked сообщает, заблокирован ли хост.
+func (b *Blocklist) Blocked(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := normalizeHost(host); err == nil {
+		host = ascii
+	}
+
+	rules := b.rules.Load()
+	if _, ok := rules.exact[host]; ok {
+		return true
+	}
+	for parent := host; ; {
+		i := strings.IndexByte(parent, '.')
+		if i < 0 {
+			break
+		}
+		parent = parent[i+1:]
+		if _, ok := rules.wildcard[parent]; ok {
+			return true
+		}
+	}
+	for _, pattern := range rules.patterns {
+		if pattern.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedURL сообщает, заблокирован ли хост URL. Неразбираемый URL не считается заблокированным:
+// его отклоняет проверка URL.
+func (b *Blocklist) BlockedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return b.Blocked(u.Hostname())
+}
+
+// Len возвращает количество загруженных правил.
+func (b *Blocklist) Len() int {
+	rules := b.rules.Load()
+	return len(rules.exact) + len(rules.wildcard) + len(rules.patterns)
+}
+
+// Reload перечитывает файл блок-листа. Если файл не удается прочитать или разобрать,
+// продолжают действовать прежние правила.
+func (b *Blocklist) Reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to open blocklist %s: %w", b.path, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			b.logger.Error("Error closing blocklist file", zap.Error(err))
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat blocklist %s: %w", b.path, err)
+	}
+	rules, err := parseBlockRules(file)
+	if err != nil {
+		return fmt.Errorf("failed to load blocklist %s: %w", b.path, err)
+	}
+
+	b.rules.Store(rules)
+	b.modTime, b.size = info.ModTime(), info.Size()
+	b.logger.Info("Blocklist loaded", zap.String("path", b.path), zap.Int("rules", b.Len()))
+	return nil
+}
+
+// Watch перечитывает блок-лист, когда файл изменяется (проверяется раз в interval)
+// или приходит сигнал в reload (например, SIGHUP), пока не будет отменен ctx.
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	if interval <= 0 {
+		interval = config.DefaultBlocklistReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+		case <-ticker.C:
+			if !b.changed() {
+				continue
+			}
+		}
+
+		if err := b.Reload(); err != nil {
+			b.logger.Error("Error reloading blocklist, keeping previous rules", zap.Error(err))
+		}
+	}
+}
+
+// changed сообщает, изменился ли файл с момента последней загрузки.
+func (b *Blocklist) changed() bool {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		b.logger.Error("Error checking blocklist file", zap.Error(err))
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !info.ModTime().Equal(b.modTime) || info.Size() != b.size
+}
+
+func parseBlockRules(file *os.File) (*blockRules, error) {
+	rules := &blockRules{
+		exact:    make(map[string]struct{}),
+		wildcard: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+
+		switch {
+		case len(rule) > 2 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/"):
+			pattern, err := regexp.Compile(rule[1 : len(rule)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidBlocklist, line, err)
+			}
+			rules.patterns = append(rules.patterns, pattern)
+		case strings.HasPrefix(rule, "*."):
+			host, err := blockRuleHost(rule[2:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidBlocklist, line, err)
+			}
+			rules.wildcard[host] = struct{}{}
+		default:
+			host, err := blockRuleHost(rule)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidBlocklist, line, err)
+			}
+			rules.exact[host] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return rules, nil
+}
+
+// blockRuleHost приводит хост из правила к виду, в котором с ним сравниваются хосты URL.
+func blockRuleHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || strings.ContainsAny(host, "*/") {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	return ascii, nil
+}
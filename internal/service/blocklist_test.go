@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func writeBlocklist(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+// TestBlocklist_Blocked тестирует точные, wildcard- и regex-правила.
+func TestBlocklist_Blocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, `
+# Фишинговые домены
+Evil.com
+*.phish.net
+/^login-[a-z]+\.example\.org$/
+пример.рф
+`)
+	blocklist, err := service.NewBlocklist(path, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, 4, blocklist.Len())
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{host: "evil.com", blocked: true},
+		{host: "EVIL.com.", blocked: true},
+		{host: "www.evil.com", blocked: false},
+		{host: "a.phish.net", blocked: true},
+		{host: "a.b.phish.net", blocked: true},
+		{host: "phish.net", blocked: false},
+		{host: "login-bank.example.org", blocked: true},
+		{host: "example.org", blocked: false},
+		{host: "xn--e1afmkfd.xn--p1ai", blocked: true},
+		{host: "example.com", blocked: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.blocked, blocklist.Blocked(tt.host), tt.host)
+	}
+	assert.True(t, blocklist.BlockedURL("https://user@a.phish.net:8443/path"))
+}
+
+// TestBlocklist_Reload тестирует перезагрузку правил и сохранение прежних правил при ошибке в файле.
+func TestBlocklist_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.com\n")
+	blocklist, err := service.NewBlocklist(path, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	writeBlocklist(t, path, "evil.com\nbad.org\n")
+	require.NoError(t, blocklist.Reload())
+	assert.True(t, blocklist.Blocked("bad.org"))
+
+	writeBlocklist(t, path, "/[/\n")
+	require.ErrorIs(t, blocklist.Reload(), service.ErrInvalidBlocklist)
+	assert.True(t, blocklist.Blocked("bad.org"))
+
+	_, err = service.NewBlocklist(path, zaptest.NewLogger(t))
+	require.ErrorIs(t, err, service.ErrInvalidBlocklist)
+}
+
+// TestBlocklist_Watch тестирует перезагрузку при изменении файла и по сигналу.
+func TestBlocklist_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.com\n")
+	blocklist, err := service.NewBlocklist(path, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reload := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		blocklist.Watch(ctx, 10*time.Millisecond, reload)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Размер файла меняется, поэтому изменение заметно даже при грубом разрешении времени изменения.
+	writeBlocklist(t, path, "evil.com\nbad.org\n")
+	require.Eventually(t, func() bool { return blocklist.Blocked("bad.org") }, time.Second, 5*time.Millisecond)
+
+	// Файл того же размера с тем же временем изменения перечитывается только по сигналу.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	writeBlocklist(t, path, "evil.com\nbad.net\n")
+	require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+	reload <- syscall.SIGHUP
+	require.Eventually(t, func() bool { return blocklist.Blocked("bad.net") }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/repository"
+	"strings"
+)
+
+var (
+	// ErrInvalidMaxClicks возвращается для некорректного лимита переходов в запросе.
+	ErrInvalidMaxClicks = errors.New("invalid max_clicks")
+	// ErrLimitedChain возвращается для цепочки, в которой лимит переходов есть у нескольких ссылок.
+	ErrLimitedChain = errors.New("chain of links with click limits")
+)
+
+// checkMaxClicks проверяет лимит переходов из запроса.
+func (s *URLService) checkMaxClicks(maxClicks int) error {
+	if maxClicks < 0 {
+		return fmt.Errorf("%w: %d must be positive", ErrInvalidMaxClicks, maxClicks)
+	}
+	if maxClicks == 0 {
+		return nil
+	}
+	if _, ok := s.repo.(repository.IClickCounter); !ok {
+		return fmt.Errorf("%w: storage doesn't support click limits", ErrInvalidMaxClicks)
+	}
+	return nil
+}
+
+// click засчитывает переход по единственной ссылке цепочки с ограниченным количеством переходов.
+// Лимит проверяет хранилище атомарно с учетом перехода: если его исчерпал одновременный запрос,
+// возвращается ErrURLExpired.
+// Переходы по нескольким ссылкам нельзя засчитать атомарно, поэтому цепочки с несколькими
+// ограниченными ссылками не создаются (см. checkChain), а оставшиеся от прежних версий не раскрываются
+// с ErrLimitedChain: иначе отклоненный переход расходовал бы лимит части ссылок.
+func (s *URLService) click(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > 1 {
+		return fmt.Errorf("%w: %s", ErrLimitedChain, strings.Join(ids, ", "))
+	}
+	counter, ok := s.repo.(repository.IClickCounter)
+	if !ok {
+		return fmt.Errorf("%w: storage doesn't support click limits", ErrInternalServer)
+	}
+
+	id := ids[0]
+	_, err := counter.Click(ctx, id, s.now())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrURLExpired):
+		return fmt.Errorf("%s: click limit reached: %w", id, ErrURLExpired)
+	case errors.Is(err, repository.ErrURLNotFound):
+		// Ссылку удалили между чтением и учетом перехода.
+		return fmt.Errorf("%s not found: %w", id, ErrURLNotFound)
+	default:
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("click canceled: %w", ctxErr)
+		}
+		return fmt.Errorf("%w: failed to count click: %w", ErrInternalServer, err)
+	}
+}
@@ -0,0 +1,132 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"linkshrink/internal/repository"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestURLService_MaxClicks тестирует, что одноразовая ссылка раскрывается один раз.
+func TestURLService_MaxClicks(t *testing.T) {
+	srv, store, _ := newExpiringService(t)
+	ctx := context.Background()
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/download", service.ShortenOptions{MaxClicks: 1})
+	require.NoError(t, err)
+	id := strings.TrimPrefix(shortURL, testBaseURL+"/")
+
+	originalURL, err := srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/download", originalURL)
+
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.ErrorIs(t, err, service.ErrURLExpired)
+	assert.Equal(t, 1, store.Store[id].Clicks)
+
+	// Ссылка с лимитом не мешает сократить тот же URL заново.
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/download", service.ShortenOptions{MaxClicks: 1})
+	require.NoError(t, err)
+}
+
+// TestURLService_MaxClicks_NotShared тестирует, что ссылка с лимитом переходов не выдается запросам
+// на тот же URL, а обычная ссылка не выдается вместо нее.
+func TestURLService_MaxClicks_NotShared(t *testing.T) {
+	srv, _, _ := newExpiringService(t)
+	ctx := context.Background()
+
+	public, err := srv.Shorten(ctx, testBaseURL, "http://example.com/invite", service.ShortenOptions{})
+	require.NoError(t, err)
+	limited, err := srv.Shorten(ctx, testBaseURL, "http://example.com/invite", service.ShortenOptions{MaxClicks: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, public, limited)
+
+	// Переход по обычной ссылке не расходует лимит.
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, strings.TrimPrefix(public, testBaseURL+"/"))
+	require.NoError(t, err)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, strings.TrimPrefix(limited, testBaseURL+"/"))
+	require.NoError(t, err)
+
+	existing, err := srv.Shorten(ctx, testBaseURL, "http://example.com/invite", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, public, existing)
+}
+
+// TestURLService_MaxClicks_Chain тестирует, что цепочка из нескольких ссылок с лимитом не раскрывается
+// и не расходует лимит ни одной из них.
+func TestURLService_MaxClicks_Chain(t *testing.T) {
+	srv, store, _ := newExpiringService(t)
+	ctx := context.Background()
+
+	// Такие цепочки могли остаться от прежних версий или появиться при смене BaseURL.
+	require.NoError(t, store.SaveBatch(ctx, []repository.URLData{
+		{UUID: "first", OriginalURL: testBaseURL + "/second", MaxClicks: 1, Exclusive: true},
+		{UUID: "second", OriginalURL: "http://example.com", MaxClicks: 1, Exclusive: true},
+	}))
+
+	_, err := srv.GetOriginalURL(ctx, testBaseURL, "first")
+	require.ErrorIs(t, err, service.ErrLimitedChain)
+	assert.Zero(t, store.Store["first"].Clicks)
+	assert.Zero(t, store.Store["second"].Clicks)
+}
+
+// TestURLService_MaxClicks_Concurrent тестирует, что одновременные переходы не превышают лимит.
+func TestURLService_MaxClicks_Concurrent(t *testing.T) {
+	srv, _, _ := newExpiringService(t)
+	ctx := context.Background()
+	const maxClicks, clicks = 5, 50
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/invite",
+		service.ShortenOptions{MaxClicks: maxClicks})
+	require.NoError(t, err)
+	id := strings.TrimPrefix(shortURL, testBaseURL+"/")
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for range clicks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := srv.GetOriginalURL(ctx, testBaseURL, id)
+			if err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+				return
+			}
+			assert.ErrorIs(t, err, service.ErrURLExpired)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, maxClicks, allowed)
+}
+
+// TestURLService_MaxClicks_Reshorten тестирует, что лимит нельзя обойти, сократив ссылку повторно.
+func TestURLService_MaxClicks_Reshorten(t *testing.T) {
+	srv, _, _ := newExpiringService(t)
+	ctx := context.Background()
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/invite", service.ShortenOptions{MaxClicks: 1})
+	require.NoError(t, err)
+
+	_, err = srv.Shorten(ctx, testBaseURL, shortURL, service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrSelfReference)
+}
+
+// TestURLService_MaxClicks_Invalid тестирует отклонение отрицательного лимита.
+func TestURLService_MaxClicks_Invalid(t *testing.T) {
+	srv, store, _ := newExpiringService(t)
+
+	_, err := srv.Shorten(context.Background(), testBaseURL, "http://example.com", service.ShortenOptions{MaxClicks: -1})
+	require.ErrorIs(t, err, service.ErrInvalidMaxClicks)
+	assert.Empty(t, store.Store)
+}
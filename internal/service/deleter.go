@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxDeleteIDs - наибольшее количество ссылок в одном запросе на удаление.
+	MaxDeleteIDs = 1000
+	// deleteTimeout - ограничение времени удаления одного пакета.
+	deleteTimeout = 30 * time.Second
+)
+
+var (
+	// ErrURLDeleted возвращается для ссылок, удаленных владельцем.
+	ErrURLDeleted = errors.New("URL deleted")
+	// ErrInvalidDeleteRequest возвращается для некорректного списка ссылок на удаление.
+	ErrInvalidDeleteRequest = errors.New("invalid delete request")
+	// ErrDeleterStopped возвращается, если фоновое удаление уже остановлено.
+	ErrDeleterStopped = errors.New("deleter stopped")
+	// ErrDeleterBusy возвращается, если очередь удаления заполнена и запрос нужно повторить позже.
+	ErrDeleterBusy = errors.New("deleter queue is full")
+)
+
+// DeleteUserURLs ставит в очередь на удаление ссылки ids пользователя userID и сразу возвращается.
+// Удаляются только ссылки, принадлежащие userID; остальные ID пропускаются без ошибки.
+func (s *URLService) DeleteUserURLs(ctx context.Context, userID string, ids []string) error {
+	if s.deleter == nil {
+		return fmt.Errorf("%w: storage doesn't support deleting links", ErrInternalServer)
+	}
+	if userID == "" {
+		return fmt.Errorf("%w: user is unknown", ErrInvalidDeleteRequest)
+	}
+	if len(ids) == 0 || len(ids) > MaxDeleteIDs {
+		return fmt.Errorf("%w: from 1 to %d IDs expected, got %d", ErrInvalidDeleteRequest, MaxDeleteIDs, len(ids))
+	}
+	for i, id := range ids {
+		if id == "" {
+			return fmt.Errorf("%w: ID %d is empty", ErrInvalidDeleteRequest, i)
+		}
+	}
+	return s.deleter.Enqueue(ctx, userID, ids)
+}
+
+// Deleter удаляет ссылки пользователей в фоне: запросы всех обработчиков сходятся в одну очередь,
+// из которой ссылки удаляются пакетами по batchSize или раз в flushInterval, если пакет не набрался.
+// Очередь вмещает batchSize запросов; запрос, не поместившийся в нее, отклоняется целиком.
+type Deleter struct {
+	repo          repository.IDeleter
+	queue         chan []repository.UserLink // Ссылки запросов, каждый запрос - одним элементом
+	batchSize     int
+	flushInterval time.Duration
+	logger        logger.Logger
+
+	mu     sync.RWMutex // Защищает closed от закрытия очереди во время записи в нее
+	closed bool
+}
+
+// NewDeleter создает фоновое удаление с настройками из конфигурации.
+func NewDeleter(repo repository.IDeleter, cfg config.DeletionConfig, log logger.Logger) *Deleter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = config.DefaultDeleteBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = config.DefaultDeleteFlushInterval
+	}
+	return &Deleter{
+		repo:          repo,
+		queue:         make(chan []repository.UserLink, cfg.BatchSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		logger:        log.With(zap.String("component", "Deleter")),
+	}
+}
+
+// Enqueue ставит ссылки запроса в очередь на удаление целиком и не ждет: если очередь заполнена,
+// возвращается ErrDeleterBusy и в очередь не попадает ни одна ссылка.
+func (d *Deleter) Enqueue(ctx context.Context, userID string, ids []string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("enqueue canceled: %w", err)
+	}
+	links := make([]repository.UserLink, 0, len(ids))
+	for _, id := range ids {
+		links = append(links, repository.UserLink{UserID: userID, ID: id})
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrDeleterStopped
+	}
+	select {
+	case d.queue <- links:
+		return nil
+	default:
+		return fmt.Errorf("%w: %d links of user %s", ErrDeleterBusy, len(ids), userID)
+	}
+}
+
+// Close закрывает очередь. Ссылки, поставленные в очередь раньше, еще будут удалены: Run
+// возвращается только после этого. Вызывается после остановки сервера, когда новых запросов нет.
+func (d *Deleter) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+}
+
+// Run удаляет ссылки из очереди, пока она не будет закрыта и опустошена.
+func (d *Deleter) Run() {
+	ticker := time.NewTicker(d.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]repository.UserLink, 0, d.batchSize)
+	for {
+		select {
+		case links, ok := <-d.queue:
+			if !ok {
+				d.flush(batch)
+				return
+			}
+			batch = append(batch, links...)
+			// Полные пакеты удаляются сразу, остаток ждет следующих запросов или таймера.
+			for len(batch) >= d.batchSize {
+				d.flush(batch[:d.batchSize])
+				batch = append(batch[:0], batch[d.batchSize:]...)
+			}
+		case <-ticker.C:
+			d.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush удаляет пакет ссылок. Ошибка записывается в лог: клиент уже получил ответ.
+func (d *Deleter) flush(batch []repository.UserLink) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+
+	deleted, err := d.repo.DeleteUserURLs(ctx, batch)
+	if err != nil {
+		d.logger.Error("Error deleting URLs", zap.Int("count", len(batch)), zap.Error(err))
+		return
+	}
+	d.logger.Debug("URLs deleted", zap.Int("requested", len(batch)), zap.Int("deleted", deleted))
+}
@@ -0,0 +1,165 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// recordingDeleter запоминает пакеты, переданные хранилищу.
+type recordingDeleter struct {
+	mu      sync.Mutex
+	batches [][]repository.UserLink
+}
+
+func (d *recordingDeleter) DeleteUserURLs(_ context.Context, links []repository.UserLink) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.batches = append(d.batches, append([]repository.UserLink(nil), links...))
+	return len(links), nil
+}
+
+// TestURLService_DeleteUserURLs тестирует, что владелец удаляет свои ссылки, они отвечают ErrURLDeleted
+// и пропадают из списка, чужие ссылки не удаляются, а URL удаленной ссылки можно сократить заново.
+func TestURLService_DeleteUserURLs(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	deleter := service.NewDeleter(store, config.DeletionConfig{FlushInterval: time.Hour}, zaptest.NewLogger(t))
+	srv := service.NewURLServiceWithConfig(store, service.URLServiceConfig{Deleter: deleter, Now: clock.Now})
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deleter.Run()
+	}()
+
+	shorten := func(originalURL string, userID string) string {
+		shortURL, err := srv.Shorten(ctx, testBaseURL, originalURL, service.ShortenOptions{UserID: userID})
+		require.NoError(t, err)
+		return strings.TrimPrefix(shortURL, testBaseURL+"/")
+	}
+	own, kept, foreign := shorten("http://example.com/1", "alice"), shorten("http://example.com/2", "alice"),
+		shorten("http://example.com/3", "bob")
+
+	require.NoError(t, srv.DeleteUserURLs(ctx, "alice", []string{own, foreign}))
+	// Закрытие очереди дожидается удаления всего, что было в нее поставлено.
+	deleter.Close()
+	<-done
+
+	_, err := srv.GetOriginalURL(ctx, testBaseURL, own)
+	require.ErrorIs(t, err, service.ErrURLDeleted)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, foreign)
+	require.NoError(t, err)
+
+	urls, err := srv.GetUserURLs(ctx, testBaseURL, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []service.UserURL{{ShortURL: testBaseURL + "/" + kept, OriginalURL: "http://example.com/2"}}, urls)
+
+	// Ссылка на удаленную ссылку не создается, а сам URL сокращается заново.
+	_, err = srv.Shorten(ctx, testBaseURL, testBaseURL+"/"+own, service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrSelfReference)
+	assert.NotEqual(t, own, shorten("http://example.com/1", "carol"))
+
+	require.ErrorIs(t, srv.DeleteUserURLs(ctx, "alice", []string{kept}), service.ErrDeleterStopped)
+}
+
+// TestURLService_DeleteUserURLs_Invalid тестирует отклонение некорректных запросов на удаление.
+func TestURLService_DeleteUserURLs_Invalid(t *testing.T) {
+	deleter := service.NewDeleter(&recordingDeleter{}, config.DeletionConfig{}, zaptest.NewLogger(t))
+	srv := service.NewURLServiceWithConfig(memorystore.NewMemoryStore(zaptest.NewLogger(t)),
+		service.URLServiceConfig{Deleter: deleter})
+
+	tests := []struct {
+		name   string
+		userID string
+		ids    []string
+	}{
+		{name: "no user", ids: []string{"abc"}},
+		{name: "no ids", userID: "alice"},
+		{name: "empty id", userID: "alice", ids: []string{"abc", ""}},
+		{name: "too many", userID: "alice", ids: make([]string, service.MaxDeleteIDs+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := srv.DeleteUserURLs(context.Background(), tt.userID, tt.ids)
+			require.ErrorIs(t, err, service.ErrInvalidDeleteRequest)
+		})
+	}
+}
+
+// TestDeleter_Batching тестирует, что запросы разных пользователей удаляются общими пакетами
+// не больше batchSize, а неполный пакет удаляется по таймеру.
+func TestDeleter_Batching(t *testing.T) {
+	repo := &recordingDeleter{}
+	deleter := service.NewDeleter(repo, config.DeletionConfig{BatchSize: 3, FlushInterval: 10 * time.Millisecond},
+		zaptest.NewLogger(t))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deleter.Run()
+	}()
+	ctx := context.Background()
+
+	require.NoError(t, deleter.Enqueue(ctx, "alice", []string{"a1", "a2"}))
+	require.NoError(t, deleter.Enqueue(ctx, "bob", []string{"b1", "b2"}))
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		total := 0
+		for _, batch := range repo.batches {
+			total += len(batch)
+		}
+		return total == 4
+	}, time.Second, 5*time.Millisecond)
+
+	deleter.Close()
+	<-done
+
+	var links []repository.UserLink
+	for _, batch := range repo.batches {
+		assert.LessOrEqual(t, len(batch), 3)
+		links = append(links, batch...)
+	}
+	assert.Equal(t, []repository.UserLink{
+		{UserID: "alice", ID: "a1"}, {UserID: "alice", ID: "a2"},
+		{UserID: "bob", ID: "b1"}, {UserID: "bob", ID: "b2"},
+	}, links)
+}
+
+// TestDeleter_Busy тестирует, что запрос, не поместившийся в заполненную очередь, отклоняется целиком
+// и не ждет места.
+func TestDeleter_Busy(t *testing.T) {
+	repo := &recordingDeleter{}
+	deleter := service.NewDeleter(repo, config.DeletionConfig{BatchSize: 1, FlushInterval: time.Hour},
+		zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	// Удаление еще не запущено, поэтому очередь не разбирается.
+	require.NoError(t, deleter.Enqueue(ctx, "alice", []string{"a1", "a2"}))
+	require.ErrorIs(t, deleter.Enqueue(ctx, "bob", []string{"b1"}), service.ErrDeleterBusy)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deleter.Run()
+	}()
+	deleter.Close()
+	<-done
+
+	assert.Equal(t, [][]repository.UserLink{
+		{{UserID: "alice", ID: "a1"}}, {{UserID: "alice", ID: "a2"}},
+	}, repo.batches)
+}
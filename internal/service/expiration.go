@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// reapTimeout - ограничение времени одного прохода удаления.
+const reapTimeout = time.Minute
+
+var (
+	// ErrURLExpired возвращается для ссылок, срок действия которых истек.
+	ErrURLExpired = errors.New("URL expired")
+	// ErrInvalidExpiration возвращается для некорректного срока действия в запросе.
+	ErrInvalidExpiration = errors.New("invalid expiration")
+)
+
+// expiresAt вычисляет момент истечения срока действия ссылки из параметров запроса.
+// Нулевое время означает бессрочную ссылку.
+func (s *URLService) expiresAt(opts ShortenOptions) (time.Time, error) {
+	switch {
+	case opts.TTL != 0 && !opts.ExpiresAt.IsZero():
+		return time.Time{}, fmt.Errorf("%w: expires_at and ttl are mutually exclusive", ErrInvalidExpiration)
+	case opts.TTL < 0:
+		return time.Time{}, fmt.Errorf("%w: ttl %s must be positive", ErrInvalidExpiration, opts.TTL)
+	case opts.TTL > 0:
+		return s.now().Add(opts.TTL).UTC(), nil
+	case !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(s.now()):
+		return time.Time{}, fmt.Errorf("%w: expires_at %s is in the past",
+			ErrInvalidExpiration, opts.ExpiresAt.Format(time.RFC3339))
+	default:
+		return opts.ExpiresAt.UTC(), nil
+	}
+}
+
+// freeExpired удаляет ссылку id, если ее срок действия истек раньше, чем expiredRetention назад,
+// чтобы ее ID и URL можно было занять заново. До тех пор ссылка, как и у Reaper, отвечает 410 Gone,
+// а ее ID не выдается другому адресу. Сообщает, была ли ссылка удалена.
+func (s *URLService) freeExpired(ctx context.Context, id string) bool {
+	expirer, ok := s.repo.(repository.IExpirer)
+	if !ok {
+		return false
+	}
+	before := s.now().Add(-s.expiredRetention)
+	url, err := s.repo.Get(ctx, id)
+	if err != nil || !url.Expired(before) {
+		return false
+	}
+
+	deleted, err := expirer.DeleteExpired(ctx, before, id)
+	if err != nil {
+		s.logger.Error("Error deleting expired URL", zap.String("id", id), zap.Error(err))
+		return false
+	}
+	return deleted > 0
+}
+
+// Reaper периодически удаляет из хранилища ссылки, срок действия которых истек.
+// Истекшая ссылка удаляется не сразу, а через retention: до тех пор она отвечает 410 Gone, а не 404.
+type Reaper struct {
+	expirer   repository.IExpirer
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+	logger    logger.Logger
+}
+
+// NewReaper создает удаление истекших ссылок с настройками из конфигурации, получающее время из now.
+func NewReaper(
+	expirer repository.IExpirer,
+	cfg config.ExpirationConfig,
+	now func() time.Time,
+	log logger.Logger,
+) *Reaper {
+	if cfg.ReapInterval <= 0 {
+		cfg.ReapInterval = config.DefaultReapInterval
+	}
+	if cfg.Retention < 0 {
+		cfg.Retention = 0
+	}
+	return &Reaper{
+		expirer:   expirer,
+		interval:  cfg.ReapInterval,
+		retention: cfg.Retention,
+		now:       now,
+		logger:    log.With(zap.String("component", "Reaper")),
+	}
+}
+
+// Reap удаляет ссылки, истекшие раньше, чем retention назад, и возвращает их количество.
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	deleted, err := r.expirer.DeleteExpired(ctx, r.now().Add(-r.retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired URLs: %w", err)
+	}
+	return deleted, nil
+}
+
+// Run удаляет истекшие ссылки раз в interval, пока не будет отменен ctx.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reapCtx, cancel := context.WithTimeout(ctx, reapTimeout)
+		deleted, err := r.Reap(reapCtx)
+		cancel()
+		if err != nil {
+			r.logger.Error("Error deleting expired URLs", zap.Error(err))
+			continue
+		}
+		if deleted > 0 {
+			r.logger.Info("Expired URLs deleted", zap.Int("count", deleted))
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeClock - часы, которые идут только по команде теста.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newExpiringService(t *testing.T) (*service.URLService, *memorystore.MemoryStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	srv := service.NewURLServiceWithConfig(store, service.URLServiceConfig{Now: clock.Now})
+	return srv, store, clock
+}
+
+// TestURLService_Expiration тестирует, что ссылка работает до истечения срока действия,
+// после него возвращает ErrURLExpired, а URL можно сократить заново.
+func TestURLService_Expiration(t *testing.T) {
+	srv, _, clock := newExpiringService(t)
+	ctx := context.Background()
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	id := strings.TrimPrefix(shortURL, testBaseURL+"/")
+
+	clock.Advance(59 * time.Minute)
+	originalURL, err := srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/sale", originalURL)
+
+	clock.Advance(time.Minute)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.ErrorIs(t, err, service.ErrURLExpired)
+
+	// Ссылка со сроком действия не выдается другим запросам: URL сокращается под новым ID,
+	// а истекшая ссылка продолжает отвечать 410 до удаления.
+	newShortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, shortURL, newShortURL)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.ErrorIs(t, err, service.ErrURLExpired)
+}
+
+// TestURLService_Expiration_NotShared тестирует, что ссылка со сроком действия не выдается запросам
+// на тот же URL, а бессрочная ссылка не выдается вместо нее, в том числе при детерминированных ID.
+func TestURLService_Expiration_NotShared(t *testing.T) {
+	hash, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	srv := service.NewURLServiceWithConfig(memorystore.NewMemoryStore(zaptest.NewLogger(t)), service.URLServiceConfig{
+		IDGenerator: hash,
+		Now:         clock.Now,
+	})
+	ctx := context.Background()
+
+	permanent, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{})
+	require.NoError(t, err)
+	expiring, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	assert.NotEqual(t, permanent, expiring)
+
+	again, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	assert.NotContains(t, []string{permanent, expiring}, again)
+
+	existing, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, permanent, existing)
+}
+
+// TestURLService_Expiration_Retention тестирует, что ID истекшей ссылки освобождается
+// только по прошествии retention.
+func TestURLService_Expiration_Retention(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	srv := service.NewURLServiceWithConfig(memorystore.NewMemoryStore(zaptest.NewLogger(t)), service.URLServiceConfig{
+		ExpiredRetention: time.Hour,
+		Now:              clock.Now,
+	})
+	ctx := context.Background()
+
+	_, err := srv.Shorten(ctx, testBaseURL, "http://example.com/spring",
+		service.ShortenOptions{Alias: "sale", TTL: time.Hour})
+	require.NoError(t, err)
+
+	clock.Advance(90 * time.Minute)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/summer", service.ShortenOptions{Alias: "sale"})
+	require.ErrorIs(t, err, service.ErrAliasTaken)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, "sale")
+	require.ErrorIs(t, err, service.ErrURLExpired)
+
+	clock.Advance(30 * time.Minute)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/summer", service.ShortenOptions{Alias: "sale"})
+	require.NoError(t, err)
+}
+
+// TestURLService_Expiration_Chain тестирует, что ссылку со сроком действия нельзя раскрыть
+// в бессрочную, сократив ее заново.
+func TestURLService_Expiration_Chain(t *testing.T) {
+	srv, _, _ := newExpiringService(t)
+	ctx := context.Background()
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/sale", service.ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, shortURL, service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrSelfReference)
+}
+
+// TestURLService_Expiration_Alias тестирует, что алиас истекшей ссылки можно занять заново.
+func TestURLService_Expiration_Alias(t *testing.T) {
+	srv, _, clock := newExpiringService(t)
+	ctx := context.Background()
+	opts := service.ShortenOptions{Alias: "spring-sale", ExpiresAt: clock.Now().Add(24 * time.Hour)}
+
+	_, err := srv.Shorten(ctx, testBaseURL, "http://example.com/spring", opts)
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/summer", service.ShortenOptions{Alias: "spring-sale"})
+	require.ErrorIs(t, err, service.ErrAliasTaken)
+
+	clock.Advance(24 * time.Hour)
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/summer",
+		service.ShortenOptions{Alias: "spring-sale"})
+	require.NoError(t, err)
+	assert.Equal(t, testBaseURL+"/spring-sale", shortURL)
+
+	originalURL, err := srv.GetOriginalURL(ctx, testBaseURL, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/summer", originalURL)
+}
+
+// TestURLService_Expiration_Invalid тестирует отклонение некорректного срока действия.
+func TestURLService_Expiration_Invalid(t *testing.T) {
+	srv, store, clock := newExpiringService(t)
+
+	tests := []struct {
+		name string
+		opts service.ShortenOptions
+	}{
+		{name: "negative ttl", opts: service.ShortenOptions{TTL: -time.Hour}},
+		{name: "expires in the past", opts: service.ShortenOptions{ExpiresAt: clock.Now().Add(-time.Second)}},
+		{name: "expires now", opts: service.ShortenOptions{ExpiresAt: clock.Now()}},
+		{name: "both", opts: service.ShortenOptions{TTL: time.Hour, ExpiresAt: clock.Now().Add(time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srv.Shorten(context.Background(), testBaseURL, "http://example.com", tt.opts)
+			require.ErrorIs(t, err, service.ErrInvalidExpiration)
+		})
+	}
+	assert.Empty(t, store.Store)
+}
+
+// TestReaper тестирует, что истекшие ссылки удаляются только по прошествии retention.
+func TestReaper(t *testing.T) {
+	srv, store, clock := newExpiringService(t)
+	ctx := context.Background()
+	reaper := service.NewReaper(store, config.ExpirationConfig{Retention: time.Hour}, clock.Now, zaptest.NewLogger(t))
+
+	_, err := srv.Shorten(ctx, testBaseURL, "http://example.com/short", service.ShortenOptions{TTL: time.Minute})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/long", service.ShortenOptions{TTL: 24 * time.Hour})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/forever", service.ShortenOptions{})
+	require.NoError(t, err)
+
+	// Истекшая ссылка еще хранится, чтобы отвечать 410.
+	clock.Advance(30 * time.Minute)
+	deleted, err := reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	clock.Advance(31 * time.Minute)
+	deleted, err = reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = store.FindByOriginalURL(ctx, "http://example.com/short")
+	require.ErrorIs(t, err, repository.ErrURLNotFound)
+	assert.Len(t, store.Store, 2)
+}
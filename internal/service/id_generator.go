@@ -1,27 +1,263 @@
 package service
 
 import (
-	"math/rand"
-	"strconv"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"math"
 	"sync"
-	"time"
 )
 
-// IDGenerator - генератор уникальных идентификаторов.
-type IDGenerator struct {
-	randGen *rand.Rand
-	mu      sync.Mutex
+const (
+	// AlphabetBase62 - цифры и латинские буквы обоих регистров.
+	AlphabetBase62 = "base62"
+	// AlphabetUnambiguous - base62 без легко путаемых символов 0/O и 1/l/I.
+	AlphabetUnambiguous = "unambiguous"
+
+	// Стратегии генерации ID.
+	StrategyRandom     = "random"     // Случайные ID из crypto/rand
+	StrategySequential = "sequential" // Счетчик, обфусцированный в стиле hashids
+	StrategySnowflake  = "snowflake"  // Время + номер узла + последовательность
+	StrategyHash       = "hash"       // Детерминированный хеш оригинального URL
+
+	MaxIDLength = 32 // Длина, дальше которой ID не растет
+
+	// minPrivateEntropyBits - нижняя граница энтропии приватных ссылок, ниже которой их можно перебрать.
+	minPrivateEntropyBits = 64
+
+	// collisionWindow - количество попыток, по которому оценивается доля коллизий.
+	collisionWindow = 100
+	// maxCollisions - количество коллизий в окне, после которого длина ID увеличивается (10%).
+	maxCollisions = collisionWindow / 10
+)
+
+var alphabets = map[string]string{
+	AlphabetBase62:      "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	AlphabetUnambiguous: "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz",
+}
+
+var (
+	ErrInvalidIDConfig  = errors.New("invalid ID generator config")
+	ErrIDSpaceExhausted = errors.New("ID space exhausted")
+)
+
+// IDGenerator - стратегия генерации коротких ID.
+// Сервис сообщает генератору о результате сохранения каждого ID,
+// чтобы стратегия могла реагировать на коллизии.
+type IDGenerator interface {
+	// GenerateID создает ID для оригинального URL. Стратегии, не зависящие от URL, его игнорируют.
+	GenerateID(originalURL string) (string, error)
+	// ReportSuccess учитывает ID, который удалось сохранить.
+	ReportSuccess(id string)
+	// ReportCollision учитывает ID, который оказался занят.
+	// Ошибка означает, что свободный ID стратегия больше не найдет.
+	ReportCollision(id string) error
+}
+
+// NewIDGenerator создает генератор ID стратегии, выбранной в конфигурации.
+func NewIDGenerator(cfg config.IDConfig) (IDGenerator, error) {
+	switch cfg.Strategy {
+	case StrategyRandom:
+		if err := checkEntropy(cfg.Length, cfg.Alphabet, cfg.MinEntropyBits); err != nil {
+			return nil, err
+		}
+		return asIDGenerator(NewRandomIDGenerator(cfg.Length, cfg.Alphabet))
+	case StrategySequential:
+		return asIDGenerator(NewSequentialIDGenerator(cfg.Length, cfg.Alphabet, cfg.Salt))
+	case StrategySnowflake:
+		return asIDGenerator(NewSnowflakeIDGenerator(cfg.Node, cfg.Alphabet))
+	case StrategyHash:
+		return asIDGenerator(NewHashIDGenerator(cfg.Length, cfg.Alphabet))
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidIDConfig, cfg.Strategy)
+	}
+}
+
+// NewPrivateIDGenerator создает генератор ID приватных ссылок: случайные ID из crypto/rand
+// длины, достаточной для cfg.PrivateEntropyBits бит энтропии, независимо от выбранной стратегии.
+func NewPrivateIDGenerator(cfg config.IDConfig) (*RandomIDGenerator, error) {
+	if cfg.PrivateEntropyBits < max(minPrivateEntropyBits, cfg.MinEntropyBits) {
+		return nil, fmt.Errorf("%w: private link entropy %d bits is below %d bits",
+			ErrInvalidIDConfig, cfg.PrivateEntropyBits, max(minPrivateEntropyBits, cfg.MinEntropyBits))
+	}
+	chars, err := lookupAlphabet(cfg.Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRandomIDGenerator(lengthForEntropy(cfg.PrivateEntropyBits, len(chars)), cfg.Alphabet)
+}
+
+// checkEntropy проверяет, что ID длины length из алфавита с именем alphabet
+// содержат не меньше minBits бит энтропии.
+func checkEntropy(length int, alphabet string, minBits int) error {
+	chars, err := lookupAlphabet(alphabet)
+	if err != nil {
+		return err
+	}
+
+	if bits := entropyBits(length, len(chars)); bits < float64(minBits) {
+		return fmt.Errorf("%w: length %d gives %.1f bits of entropy, below the floor of %d bits",
+			ErrInvalidIDConfig, length, bits, minBits)
+	}
+	return nil
+}
+
+// entropyBits возвращает энтропию случайного ID длины length из алфавита размера size.
+func entropyBits(length, size int) float64 {
+	return float64(length) * math.Log2(float64(size))
+}
+
+// lengthForEntropy возвращает минимальную длину ID из алфавита размера size с энтропией не меньше bits.
+func lengthForEntropy(bits, size int) int {
+	return int(math.Ceil(float64(bits) / math.Log2(float64(size))))
+}
+
+// asIDGenerator приводит результат конструктора к интерфейсу так,
+// чтобы при ошибке вернулся nil, а не интерфейс с nil-указателем.
+func asIDGenerator[T IDGenerator](gen T, err error) (IDGenerator, error) {
+	if err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// lookupAlphabet возвращает символы алфавита с именем name.
+func lookupAlphabet(name string) (string, error) {
+	chars, ok := alphabets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown alphabet %q", ErrInvalidIDConfig, name)
+	}
+	return chars, nil
+}
+
+func validateLength(length int) error {
+	if length < 1 || length > MaxIDLength {
+		return fmt.Errorf("%w: length %d is out of range 1..%d", ErrInvalidIDConfig, length, MaxIDLength)
+	}
+	return nil
+}
+
+// lengthPolicy отслеживает долю коллизий и увеличивает длину ID,
+// если на текущей длине она становится слишком высокой.
+type lengthPolicy struct {
+	length     int // Текущая длина генерируемых ID
+	attempts   int // Попыток в текущем окне
+	collisions int // Коллизий в текущем окне
+	mu         sync.Mutex
+}
+
+func (p *lengthPolicy) current() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.length
+}
+
+func (p *lengthPolicy) success(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record(len(id), false)
 }
 
-func NewIDGenerator() *IDGenerator {
-	return &IDGenerator{
-		randGen: rand.New(rand.NewSource(time.Now().UnixNano())),
+// collision учитывает коллизию и при необходимости увеличивает длину ID.
+// ErrIDSpaceExhausted возвращается, когда расти дальше MaxIDLength некуда.
+func (p *lengthPolicy) collision(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.record(len(id), true) {
+		return nil
+	}
+	if p.length >= MaxIDLength {
+		return fmt.Errorf("%w: too many collisions at length %d", ErrIDSpaceExhausted, p.length)
 	}
+	p.length++
+	return nil
 }
 
-func (g *IDGenerator) GenerateID() string {
-	g.mu.Lock()         // Блокируем доступ к генератору
-	defer g.mu.Unlock() // Освобождаем блокировку после выполнения
+// record учитывает попытку и сообщает, пора ли увеличить длину ID.
+// Попытки с ID прежней длины (сгенерированные до роста) не учитываются.
+func (p *lengthPolicy) record(length int, collision bool) bool {
+	if length != p.length {
+		return false
+	}
+
+	p.attempts++
+	if collision {
+		p.collisions++
+	}
 
-	return strconv.FormatInt(g.randGen.Int63(), 10)
+	grow := p.collisions >= maxCollisions
+	if grow || p.attempts >= collisionWindow {
+		p.attempts, p.collisions = 0, 0
+	}
+	return grow
+}
+
+// RandomIDGenerator генерирует случайные ID из символов алфавита, используя crypto/rand.
+type RandomIDGenerator struct {
+	alphabet string
+	policy   lengthPolicy
+}
+
+// NewRandomIDGenerator создает генератор случайных ID начальной длины length из алфавита с именем alphabet.
+func NewRandomIDGenerator(length int, alphabet string) (*RandomIDGenerator, error) {
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+	chars, err := lookupAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RandomIDGenerator{alphabet: chars, policy: lengthPolicy{length: length}}, nil
+}
+
+func (g *RandomIDGenerator) GenerateID(string) (string, error) {
+	length := g.policy.current()
+	id := make([]byte, 0, length)
+	// Байты за пределом, кратным размеру алфавита, отбрасываются, чтобы символы были равновероятны.
+	limit := byte(256 - 256%len(g.alphabet))
+	buf := make([]byte, length)
+
+	for len(id) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if b >= limit || len(id) == length {
+				continue
+			}
+			id = append(id, g.alphabet[int(b)%len(g.alphabet)])
+		}
+	}
+	return string(id), nil
+}
+
+func (g *RandomIDGenerator) ReportSuccess(id string) {
+	g.policy.success(id)
+}
+
+func (g *RandomIDGenerator) ReportCollision(id string) error {
+	return g.policy.collision(id)
+}
+
+// encodeNumber записывает n в системе счисления с цифрами из alphabet.
+func encodeNumber(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
 }
@@ -1,55 +1,282 @@
 package service_test
 
 import (
+	"linkshrink/internal/config"
 	"linkshrink/internal/service"
 	"linkshrink/internal/utils"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var strategies = []string{
+	service.StrategyRandom,
+	service.StrategySequential,
+	service.StrategySnowflake,
+	service.StrategyHash,
+}
+
+func newGenerator(t *testing.T, strategy string) service.IDGenerator {
+	t.Helper()
+	generator, err := service.NewIDGenerator(config.IDConfig{
+		Strategy: strategy,
+		Length:   config.DefaultIDLength,
+		Alphabet: service.AlphabetBase62,
+		Salt:     "test salt",
+	})
+	require.NoError(t, err)
+	return generator
+}
+
 func TestIDGenerator_GenerateID(t *testing.T) {
-	generator := service.NewIDGenerator()
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			generator := newGenerator(t, strategy)
 
-	// Генерируем несколько ID
-	id1 := generator.GenerateID()
-	id2 := generator.GenerateID()
+			// Генерируем несколько ID
+			id1, err := generator.GenerateID("http://example.com")
+			require.NoError(t, err)
+			id2, err := generator.GenerateID("http://another.com")
+			require.NoError(t, err)
 
-	// Проверяем, что ID не пустые
-	require.NotEmpty(t, id1, "Generated ID should not be empty")
-	require.NotEmpty(t, id2, "Generated ID should not be empty")
+			// Проверяем, что ID не пустые
+			require.NotEmpty(t, id1, "Generated ID should not be empty")
+			require.NotEmpty(t, id2, "Generated ID should not be empty")
 
-	// Проверяем, что ID разные
-	assert.NotEqual(t, id1, id2, "Generated IDs should be different")
+			// Проверяем, что ID разные
+			assert.NotEqual(t, id1, id2, "Generated IDs should be different")
+		})
+	}
 }
 
 func TestIDGenerator_ConcurrentAccess(t *testing.T) {
-	generator := service.NewIDGenerator()
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			generator := newGenerator(t, strategy)
 
-	// Используем WaitGroup для ожидания завершения всех горутин
-	var wg sync.WaitGroup
-	idSet := make(map[string]struct{}) // Для хранения уникальных ID
-	mu := sync.Mutex{}                 // Мьютекс для защиты доступа к idSet
+			// Используем WaitGroup для ожидания завершения всех горутин
+			var wg sync.WaitGroup
+			idSet := make(map[string]struct{}) // Для хранения уникальных ID
+			mu := sync.Mutex{}                 // Мьютекс для защиты доступа к idSet
 
-	// Запускаем несколько горутин для генерации ID
-	for range utils.Intrange(0, 100) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			id := generator.GenerateID()
+			// Запускаем несколько горутин для генерации ID
+			for i := range utils.Intrange(0, 100) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					id, err := generator.GenerateID("http://example.com/" + strings.Repeat("a", i))
+					assert.NoError(t, err)
 
-			// Защищаем доступ к idSet
-			mu.Lock()
-			idSet[id] = struct{}{} // Добавляем ID в сет
-			mu.Unlock()
-		}()
+					// Защищаем доступ к idSet
+					mu.Lock()
+					idSet[id] = struct{}{} // Добавляем ID в сет
+					mu.Unlock()
+				}()
+			}
+
+			// Ждем завершения всех горутин
+			wg.Wait()
+
+			// Проверяем, что все сгенерированные ID уникальны
+			assert.Equal(t, 100, len(idSet), "Generated IDs should be unique")
+		})
+	}
+}
+
+// TestIDGenerator_Alphabet тестирует длину ID и исключение неоднозначных символов.
+func TestIDGenerator_Alphabet(t *testing.T) {
+	generator, err := service.NewRandomIDGenerator(8, service.AlphabetUnambiguous)
+	require.NoError(t, err)
+
+	for range 1000 {
+		id, err := generator.GenerateID("")
+		require.NoError(t, err)
+		assert.Len(t, id, 8)
+		assert.False(t, strings.ContainsAny(id, "0O1lI"), "ambiguous character in %q", id)
+	}
+
+	_, err = service.NewRandomIDGenerator(0, service.AlphabetBase62)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+	_, err = service.NewRandomIDGenerator(7, "hex")
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+	_, err = service.NewIDGenerator(config.IDConfig{Strategy: "uuid", Length: 7, Alphabet: service.AlphabetBase62})
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+}
+
+// TestIDGenerator_Sequential тестирует минимальную длину и пропуск занятых значений счетчика.
+func TestIDGenerator_Sequential(t *testing.T) {
+	generator, err := service.NewSequentialIDGenerator(6, service.AlphabetBase62, "salt")
+	require.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	for range 1000 {
+		id, err := generator.GenerateID("")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(id), 6)
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 1000)
+
+	// Другая соль дает другие ID для тех же значений счетчика.
+	other, err := service.NewSequentialIDGenerator(6, service.AlphabetBase62, "pepper")
+	require.NoError(t, err)
+	first, err := generator.GenerateID("")
+	require.NoError(t, err)
+	for range 1000 {
+		_, err := other.GenerateID("")
+		require.NoError(t, err)
+	}
+	otherFirst, err := other.GenerateID("")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, otherFirst)
+
+	// После коллизии генератор перескакивает вперед, а не выдает тот же ID.
+	id, err := generator.GenerateID("")
+	require.NoError(t, err)
+	require.NoError(t, generator.ReportCollision(id))
+	next, err := generator.GenerateID("")
+	require.NoError(t, err)
+	assert.NotEqual(t, id, next)
+}
+
+// TestIDGenerator_Hash тестирует детерминированность ID из хеша URL.
+func TestIDGenerator_Hash(t *testing.T) {
+	generator, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	another, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+
+	id1, err := generator.GenerateID("http://example.com")
+	require.NoError(t, err)
+	id2, err := another.GenerateID("http://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id1, id2)
+	assert.Len(t, id1, config.DefaultIDLength)
+}
+
+// TestIDGenerator_EntropyFloor тестирует отказ от конфигурации с недостаточной энтропией ID.
+func TestIDGenerator_EntropyFloor(t *testing.T) {
+	cfg := config.IDConfig{
+		Strategy:           service.StrategyRandom,
+		Length:             5,
+		Alphabet:           service.AlphabetBase62,
+		MinEntropyBits:     40,
+		PrivateEntropyBits: 32,
 	}
 
-	// Ждем завершения всех горутин
-	wg.Wait()
+	// 5 символов base62 - около 29.8 бит.
+	_, err := service.NewIDGenerator(cfg)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+
+	cfg.Length = 7
+	_, err = service.NewIDGenerator(cfg)
+	require.NoError(t, err)
+
+	// Приватные ссылки не могут быть слабее нижней границы.
+	_, err = service.NewPrivateIDGenerator(cfg)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+
+	cfg.PrivateEntropyBits = 128
+	generator, err := service.NewPrivateIDGenerator(cfg)
+	require.NoError(t, err)
+	id, err := generator.GenerateID("")
+	require.NoError(t, err)
+	assert.Len(t, id, 22)
+}
+
+// TestIDGenerator_Snowflake тестирует уникальность ID разных узлов и обработку отставания часов.
+func TestIDGenerator_Snowflake(t *testing.T) {
+	current := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	setClock := func(value time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = value
+	}
+
+	node1, err := service.NewSnowflakeIDGeneratorWithClock(1, service.AlphabetBase62, clock)
+	require.NoError(t, err)
+	node2, err := service.NewSnowflakeIDGeneratorWithClock(2, service.AlphabetBase62, clock)
+	require.NoError(t, err)
+
+	// В одну и ту же миллисекунду узлы выдают разные ID, а узел - разные ID благодаря последовательности.
+	id1, err := node1.GenerateID("")
+	require.NoError(t, err)
+	id2, err := node2.GenerateID("")
+	require.NoError(t, err)
+	id3, err := node1.GenerateID("")
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+	assert.NotEqual(t, id1, id3)
+
+	// Небольшое отставание часов пережидается: часы догоняют, пока генератор ждет.
+	last := current
+	setClock(last.Add(-5 * time.Millisecond))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		setClock(last.Add(time.Millisecond))
+	}()
+	id4, err := node1.GenerateID("")
+	require.NoError(t, err)
+	assert.NotEqual(t, id3, id4)
+
+	// Большое отставание - ошибка, а не повтор уже выданных ID.
+	setClock(last.Add(-time.Hour))
+	_, err = node1.GenerateID("")
+	require.ErrorIs(t, err, service.ErrClockRollback)
 
-	// Проверяем, что все сгенерированные ID уникальны
-	assert.Equal(t, 100, len(idSet), "Generated IDs should be unique")
+	_, err = service.NewSnowflakeIDGenerator(1024, service.AlphabetBase62)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+}
+
+// TestIDGenerator_Snowflake_SequenceExhausted тестирует, что после исчерпания последовательности
+// генератор ждет следующую миллисекунду, не мешая остальным запросам получить ID после нее.
+func TestIDGenerator_Snowflake_SequenceExhausted(t *testing.T) {
+	var current atomic.Int64
+	current.Store(time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC).UnixMilli())
+	clock := func() time.Time { return time.UnixMilli(current.Load()) }
+
+	generator, err := service.NewSnowflakeIDGeneratorWithClock(1, service.AlphabetBase62, clock)
+	require.NoError(t, err)
+
+	// В одной миллисекунде помещается 4096 ID.
+	seen := make(map[string]struct{})
+	for range 4096 {
+		id, err := generator.GenerateID("")
+		require.NoError(t, err)
+		seen[id] = struct{}{}
+	}
+	require.Len(t, seen, 4096)
+
+	done := make(chan string, 2)
+	for range 2 {
+		go func() {
+			id, err := generator.GenerateID("")
+			assert.NoError(t, err)
+			done <- id
+		}()
+	}
+	select {
+	case <-done:
+		t.Fatal("ID issued before the clock moved")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	current.Add(1)
+	for range 2 {
+		id := <-done
+		assert.NotContains(t, seen, id)
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 4098)
 }
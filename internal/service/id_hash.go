@@ -0,0 +1,52 @@
+package service
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+// HashIDGenerator выдает детерминированные ID - префикс хеша SHA-256 оригинального URL,
+// поэтому один и тот же URL на всех узлах получает один и тот же ID.
+// При частых коллизиях префикс удлиняется.
+type HashIDGenerator struct {
+	alphabet string
+	policy   lengthPolicy
+}
+
+// NewHashIDGenerator создает генератор ID из хеша URL начальной длины length из алфавита с именем alphabet.
+func NewHashIDGenerator(length int, alphabet string) (*HashIDGenerator, error) {
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+	chars, err := lookupAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HashIDGenerator{alphabet: chars, policy: lengthPolicy{length: length}}, nil
+}
+
+func (g *HashIDGenerator) GenerateID(originalURL string) (string, error) {
+	length := g.policy.current()
+	sum := sha256.Sum256([]byte(originalURL))
+
+	// 256 бит хеша дают не меньше 43 символов даже в алфавите из 57 символов, этого хватает до MaxIDLength.
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(g.alphabet)))
+	digit := new(big.Int)
+
+	id := make([]byte, length)
+	for i := range id {
+		n.DivMod(n, base, digit)
+		id[i] = g.alphabet[digit.Int64()]
+	}
+	return string(id), nil
+}
+
+func (g *HashIDGenerator) ReportSuccess(id string) {
+	g.policy.success(id)
+}
+
+func (g *HashIDGenerator) ReportCollision(id string) error {
+	return g.policy.collision(id)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"sync"
+)
+
+// maxSkip - предел, до которого растет шаг пропуска занятых значений счетчика.
+const maxSkip = 1 << 32
+
+// SequentialIDGenerator выдает ID по возрастающему счетчику, обфусцируя его в стиле hashids:
+// алфавит перемешивается солью и значением самого числа, поэтому соседние ID не похожи друг на друга.
+// Счетчик не сохраняется между запусками: после перезапуска занятые значения пропускаются
+// с удваивающимся шагом, пока не найдется свободный диапазон.
+type SequentialIDGenerator struct {
+	alphabet  string // Алфавит, перемешанный солью
+	salt      string
+	minLength int
+	counter   uint64
+	skip      uint64 // Шаг пропуска при следующей коллизии
+	mu        sync.Mutex
+}
+
+// NewSequentialIDGenerator создает генератор последовательных ID длиной не меньше minLength.
+func NewSequentialIDGenerator(minLength int, alphabet string, salt string) (*SequentialIDGenerator, error) {
+	if err := validateLength(minLength); err != nil {
+		return nil, err
+	}
+	chars, err := lookupAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	shuffled := []byte(chars)
+	consistentShuffle(shuffled, []byte(salt))
+
+	return &SequentialIDGenerator{
+		alphabet:  string(shuffled),
+		salt:      salt,
+		minLength: minLength,
+		counter:   1,
+		skip:      1,
+	}, nil
+}
+
+func (g *SequentialIDGenerator) GenerateID(string) (string, error) {
+	g.mu.Lock()
+	n := g.counter
+	g.counter++
+	g.mu.Unlock()
+
+	return g.encode(n), nil
+}
+
+func (g *SequentialIDGenerator) ReportSuccess(string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.skip = 1
+}
+
+// ReportCollision перескакивает через занятые значения счетчика, каждый раз удваивая шаг.
+func (g *SequentialIDGenerator) ReportCollision(string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.counter += g.skip
+	if g.skip < maxSkip {
+		g.skip *= 2
+	}
+	return nil
+}
+
+// encode кодирует число так же, как hashids кодирует одно значение:
+// первый символ ("лотерея") определяет перестановку алфавита для остальных.
+func (g *SequentialIDGenerator) encode(n uint64) string {
+	alphabet := []byte(g.alphabet)
+	lottery := alphabet[n%uint64(len(alphabet))]
+
+	buffer := make([]byte, 0, 1+len(g.salt)+len(alphabet))
+	buffer = append(buffer, lottery)
+	buffer = append(buffer, g.salt...)
+	buffer = append(buffer, alphabet...)
+	consistentShuffle(alphabet, buffer[:len(alphabet)])
+
+	id := string(lottery) + encodeNumber(n, string(alphabet))
+
+	// Короткие ID дополняются символами перемешанного алфавита с обеих сторон.
+	half := len(alphabet) / 2
+	for len(id) < g.minLength {
+		consistentShuffle(alphabet, append([]byte(nil), alphabet...))
+		id = string(alphabet[half:]) + id + string(alphabet[:half])
+		if excess := len(id) - g.minLength; excess > 0 {
+			id = id[excess/2 : excess/2+g.minLength]
+		}
+	}
+	return id
+}
+
+// consistentShuffle детерминированно перемешивает alphabet в зависимости от salt (как в hashids).
+func consistentShuffle(alphabet []byte, salt []byte) {
+	if len(salt) == 0 {
+		return
+	}
+
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+}
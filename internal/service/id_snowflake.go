@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+
+	snowflakeMaxNode     = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+
+	// maxClockRollback - насколько часы могут отстать, чтобы генератор дождался их, а не вернул ошибку.
+	maxClockRollback = 2 * time.Second
+)
+
+// ErrClockRollback возвращается, если часы отстали больше чем на maxClockRollback.
+var ErrClockRollback = errors.New("clock moved backwards")
+
+// snowflakeEpoch - начало отсчета времени в ID. Более позднее начало дает более короткие ID.
+var snowflakeEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeIDGenerator выдает ID в стиле Snowflake: миллисекунды с начала эпохи,
+// номер узла и последовательность внутри миллисекунды, закодированные алфавитом.
+// ID уникальны между узлами с разными номерами и примерно упорядочены по времени,
+// поэтому экземпляры с общим хранилищем не конфликтуют, если у каждого свой номер узла.
+type SnowflakeIDGenerator struct {
+	alphabet string
+	node     int64
+	lastMs   int64 // Миллисекунда последнего выданного ID
+	sequence int64
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// NewSnowflakeIDGenerator создает генератор Snowflake для узла с номером node.
+func NewSnowflakeIDGenerator(node int64, alphabet string) (*SnowflakeIDGenerator, error) {
+	return NewSnowflakeIDGeneratorWithClock(node, alphabet, time.Now)
+}
+
+// NewSnowflakeIDGeneratorWithClock создает генератор Snowflake, получающий время из now.
+func NewSnowflakeIDGeneratorWithClock(
+	node int64,
+	alphabet string,
+	now func() time.Time,
+) (*SnowflakeIDGenerator, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, fmt.Errorf("%w: node %d is out of range 0..%d", ErrInvalidIDConfig, node, snowflakeMaxNode)
+	}
+	chars, err := lookupAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnowflakeIDGenerator{alphabet: chars, node: node, now: now}, nil
+}
+
+// GenerateID выдает следующий ID. Если выдать его сейчас нельзя, генератор ждет без блокировки,
+// чтобы ожидание не задерживало другие запросы дольше необходимого.
+func (g *SnowflakeIDGenerator) GenerateID(string) (string, error) {
+	for {
+		id, wait, err := g.next()
+		if err != nil || wait == 0 {
+			return id, err
+		}
+		time.Sleep(wait)
+	}
+}
+
+// next выдает ID в текущую миллисекунду. Если это невозможно, возвращается время ожидания:
+// часы отстали от последнего выданного ID (например, после синхронизации времени), и продолжать
+// со старого времени нельзя, иначе ID повторились бы, или исчерпана последовательность миллисекунды.
+// Слишком большое отставание - ошибка, чтобы не блокировать запросы надолго.
+// Время последнего ID не сохраняется, поэтому защита действует только в пределах процесса:
+// если часы отстанут между перезапусками, повторный ID отклонит хранилище как занятый.
+func (g *SnowflakeIDGenerator) next() (string, time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	minMs := g.lastMs
+	if g.sequence == snowflakeMaxSequence {
+		minMs++
+	}
+	if ms < minMs {
+		lag := time.Duration(minMs-ms) * time.Millisecond
+		if lag > maxClockRollback {
+			return "", 0, fmt.Errorf("%w by %s", ErrClockRollback, lag)
+		}
+		return "", lag, nil
+	}
+
+	if ms == g.lastMs {
+		g.sequence++
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+	return encodeNumber(uint64(id), g.alphabet), 0, nil
+}
+
+// ReportSuccess ничего не делает: ID Snowflake не зависят от результатов сохранения.
+func (g *SnowflakeIDGenerator) ReportSuccess(string) {}
+
+// ReportCollision ничего не делает: следующий ID все равно будет другим.
+// Коллизия возможна, только если у двух экземпляров одинаковый номер узла.
+func (g *SnowflakeIDGenerator) ReportCollision(string) error {
+	return nil
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// keyPoolRetryInterval - пауза перед повторным пополнением после ошибки хранилища.
+	keyPoolRetryInterval = time.Second
+	// keyPoolReserveTimeout - ограничение времени одного запроса резервирования.
+	keyPoolReserveTimeout = 5 * time.Second
+)
+
+// ErrKeyPoolClosed возвращается при запросе ID из остановленного пула.
+var ErrKeyPoolClosed = errors.New("key pool is closed")
+
+// KeyPoolConfig - настройки пула заранее зарезервированных ID.
+type KeyPoolConfig struct {
+	Size         int // Емкость пула
+	LowWatermark int // Количество ID в пуле, ниже которого он пополняется
+	BatchSize    int // Количество ID, резервируемых за один запрос
+}
+
+// KeyPool - пул ID, заранее сгенерированных и зарезервированных в хранилище.
+// Фоновая горутина пополняет пул, когда в нем остается меньше LowWatermark ID,
+// поэтому при сокращении URL не приходится повторять попытки из-за коллизий.
+// KeyPool сам реализует IDGenerator и подменяет генератор, из которого берет ID.
+type KeyPool struct {
+	generator IDGenerator
+	reserver  repository.IKeyReserver
+	cfg       KeyPoolConfig
+	logger    logger.Logger
+
+	keys        chan string         // Зарезервированные ID, готовые к выдаче
+	outstanding map[string]struct{} // Выданные ID, о результате сохранения которых еще не сообщили
+	mu          sync.Mutex          // Защищает outstanding
+	closeMu     sync.RWMutex        // Исключает выдачу ID во время остановки пула
+	closed      bool                // Пул остановлен, защищено closeMu
+	refill      chan struct{}       // Сигнал о том, что пул опустел ниже LowWatermark
+	done        chan struct{}       // Закрывается при остановке пула
+	wg          sync.WaitGroup      // Ожидание фоновой горутины пополнения
+}
+
+// NewKeyPool создает пул, заполняемый ID из generator, и запускает его пополнение.
+func NewKeyPool(
+	generator IDGenerator,
+	reserver repository.IKeyReserver,
+	cfg KeyPoolConfig,
+	log logger.Logger,
+) (*KeyPool, error) {
+	if cfg.Size < 1 {
+		return nil, fmt.Errorf("%w: key pool size %d must be positive", ErrInvalidIDConfig, cfg.Size)
+	}
+	if cfg.LowWatermark < 0 || cfg.LowWatermark >= cfg.Size {
+		return nil, fmt.Errorf("%w: key pool low watermark %d is out of range 0..%d",
+			ErrInvalidIDConfig, cfg.LowWatermark, cfg.Size-1)
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = config.DefaultPoolBatchSize
+	}
+	if _, ok := generator.(*HashIDGenerator); ok {
+		// ID из хеша зависят от URL, и зарезервировать их заранее нельзя.
+		return nil, fmt.Errorf("%w: key pool can't be used with the %s strategy", ErrInvalidIDConfig, StrategyHash)
+	}
+
+	p := &KeyPool{
+		generator:   generator,
+		reserver:    reserver,
+		cfg:         cfg,
+		logger:      log.With(zap.String("component", "KeyPool")),
+		keys:        make(chan string, cfg.Size),
+		outstanding: make(map[string]struct{}),
+		refill:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.run()
+	p.triggerRefill()
+
+	return p, nil
+}
+
+// GenerateID выдает зарезервированный ID из пула. Если пул пуст (например, сразу после запуска),
+// ID генерируется и резервируется на месте, поэтому все выданные пулом ID зарезервированы.
+func (p *KeyPool) GenerateID(string) (string, error) {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return "", ErrKeyPoolClosed
+	}
+
+	var id string
+	select {
+	case id = <-p.keys:
+		if len(p.keys) < p.cfg.LowWatermark {
+			p.triggerRefill()
+		}
+	default:
+		p.triggerRefill()
+		var err error
+		if id, err = p.reserveOne(); err != nil {
+			return "", err
+		}
+	}
+
+	p.mu.Lock()
+	p.outstanding[id] = struct{}{}
+	p.mu.Unlock()
+	return id, nil
+}
+
+// reserveOne генерирует ID и резервирует его, пока резерв не удастся.
+// Занятые ID учитываются генератором как коллизии, как и при пополнении пула.
+func (p *KeyPool) reserveOne() (string, error) {
+	for {
+		id, err := p.generator.GenerateID("")
+		if err != nil {
+			return "", fmt.Errorf("failed to generate ID: %w", err)
+		}
+		reserved, err := p.reserve([]string{id})
+		if err != nil {
+			return "", err
+		}
+		if len(reserved) > 0 {
+			p.generator.ReportSuccess(id)
+			return id, nil
+		}
+		if err := p.generator.ReportCollision(id); err != nil {
+			return "", fmt.Errorf("failed to reserve ID: %w", err)
+		}
+	}
+}
+
+// ReportSuccess отмечает ID использованным: резерв с него сняло хранилище при сохранении.
+func (p *KeyPool) ReportSuccess(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.outstanding, id)
+}
+
+// ReportCollision ничего не ждет от генератора: следующий ID будет взят из пула.
+// Коллизия возможна, если резерв ID устарел и ID занял кто-то другой.
+func (p *KeyPool) ReportCollision(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.outstanding, id)
+	return nil
+}
+
+// Release снимает резерв с выданных ID, которые не были сохранены не из-за коллизии
+// (например, при конфликте URL), чтобы они не оставались зарезервированными до остановки пула.
+func (p *KeyPool) Release(ids ...string) error {
+	p.mu.Lock()
+	for _, id := range ids {
+		delete(p.outstanding, id)
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyPoolReserveTimeout)
+	defer cancel()
+
+	if err := p.reserver.ReleaseIDs(ctx, ids); err != nil {
+		return fmt.Errorf("failed to release %d reserved IDs: %w", len(ids), err)
+	}
+	return nil
+}
+
+// Close останавливает пополнение и снимает резерв с ID, которые так и не были использованы:
+// с оставшихся в пуле и с выданных, о сохранении которых не сообщили.
+// Close дожидается завершения начатых GenerateID, а последующие возвращают ErrKeyPoolClosed.
+func (p *KeyPool) Close(ctx context.Context) error {
+	p.closeMu.Lock()
+	if p.closed {
+		p.closeMu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.closeMu.Unlock()
+
+	close(p.done)
+	p.wg.Wait()
+
+	unused := make([]string, 0, len(p.keys))
+	for len(p.keys) > 0 {
+		unused = append(unused, <-p.keys)
+	}
+	p.mu.Lock()
+	for id := range p.outstanding {
+		unused = append(unused, id)
+	}
+	p.outstanding = make(map[string]struct{})
+	p.mu.Unlock()
+
+	if len(unused) == 0 {
+		return nil
+	}
+	if err := p.reserver.ReleaseIDs(ctx, unused); err != nil {
+		return fmt.Errorf("failed to release %d reserved IDs: %w", len(unused), err)
+	}
+	p.logger.Info("Released unused reserved IDs", zap.Int("count", len(unused)))
+	return nil
+}
+
+// Len возвращает количество ID, готовых к выдаче.
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+func (p *KeyPool) triggerRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default: // Пополнение уже запрошено
+	}
+}
+
+// run пополняет пул по сигналу, пока пул не будет остановлен.
+func (p *KeyPool) run() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.refill:
+		}
+
+		if err := p.fill(); err != nil {
+			p.logger.Error("Error on refilling key pool", zap.Error(err))
+			select {
+			case <-p.done:
+				return
+			case <-time.After(keyPoolRetryInterval):
+				p.triggerRefill()
+			}
+		}
+	}
+}
+
+// fill резервирует ID пачками, пока пул не заполнится.
+// Пул пополняет одна горутина, а читатели только уменьшают его, поэтому запись в канал не блокируется.
+func (p *KeyPool) fill() error {
+	for {
+		space := cap(p.keys) - len(p.keys)
+		if space == 0 {
+			return nil
+		}
+		select {
+		case <-p.done:
+			return nil
+		default:
+		}
+
+		candidates := make([]string, 0, min(space, p.cfg.BatchSize))
+		for range cap(candidates) {
+			id, err := p.generator.GenerateID("")
+			if err != nil {
+				return fmt.Errorf("failed to generate ID: %w", err)
+			}
+			candidates = append(candidates, id)
+		}
+
+		reserved, err := p.reserve(candidates)
+		if err != nil {
+			return err
+		}
+
+		// Доля занятых кандидатов - та же доля коллизий, по которой генератор увеличивает длину ID.
+		reservedSet := make(map[string]struct{}, len(reserved))
+		for _, id := range reserved {
+			reservedSet[id] = struct{}{}
+			p.keys <- id
+		}
+		for _, id := range candidates {
+			if _, ok := reservedSet[id]; ok {
+				p.generator.ReportSuccess(id)
+				continue
+			}
+			if err := p.generator.ReportCollision(id); err != nil {
+				return fmt.Errorf("failed to reserve IDs: %w", err)
+			}
+		}
+	}
+}
+
+func (p *KeyPool) reserve(ids []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyPoolReserveTimeout)
+	defer cancel()
+
+	reserved, err := p.reserver.ReserveIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve IDs: %w", err)
+	}
+	return reserved, nil
+}
@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestKeyPool тестирует заполнение пула, выдачу зарезервированных ID и снятие резерва при остановке.
+func TestKeyPool(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	store := memorystore.NewMemoryStore(logger)
+	ctx := context.Background()
+
+	generator, err := service.NewSequentialIDGenerator(4, service.AlphabetBase62, "")
+	require.NoError(t, err)
+	// Первый ID последовательности уже занят: пул должен его пропустить.
+	first, err := generator.GenerateID("")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, first, "http://taken.url"))
+	generator, err = service.NewSequentialIDGenerator(4, service.AlphabetBase62, "")
+	require.NoError(t, err)
+
+	pool, err := service.NewKeyPool(generator, store, service.KeyPoolConfig{Size: 10, LowWatermark: 5, BatchSize: 4},
+		logger)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.Len() == 10 }, time.Second, time.Millisecond)
+
+	// Выданный ID зарезервирован: второй пул получить его не может, а сохранить его может только владелец резерва.
+	id, err := pool.GenerateID("")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, id)
+	reserved, err := store.ReserveIDs(ctx, []string{id})
+	require.NoError(t, err)
+	assert.Empty(t, reserved)
+	require.ErrorIs(t, store.Save(ctx, id, "http://example.com"), repository.ErrIDAlreadyExists)
+	require.NoError(t, store.SaveBatch(ctx, []repository.URLData{
+		{UUID: id, OriginalURL: "http://example.com", Reserved: true},
+	}))
+	pool.ReportSuccess(id)
+
+	// Выдача ниже порога запускает пополнение.
+	for range 6 {
+		_, err := pool.GenerateID("")
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool { return pool.Len() == 10 }, time.Second, time.Millisecond)
+
+	// При остановке резерв снимается с неиспользованных ID, включая выданные, но не сохраненные.
+	unused, err := pool.GenerateID("")
+	require.NoError(t, err)
+	require.NoError(t, pool.Close(ctx))
+	reserved, err = store.ReserveIDs(ctx, []string{unused, id})
+	require.NoError(t, err)
+	assert.Equal(t, []string{unused}, reserved)
+
+	_, err = pool.GenerateID("")
+	require.ErrorIs(t, err, service.ErrKeyPoolClosed)
+}
+
+// stalledReserver не дает пулу пополниться: резервирует только по одному ID, а пакеты ждут остановки теста.
+type stalledReserver struct {
+	*memorystore.MemoryStore
+	done chan struct{}
+}
+
+func (r stalledReserver) ReserveIDs(ctx context.Context, ids []string) ([]string, error) {
+	if len(ids) > 1 {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+		}
+		return nil, ctx.Err()
+	}
+	return r.MemoryStore.ReserveIDs(ctx, ids)
+}
+
+// TestKeyPool_Empty тестирует, что пустой пул выдает ID, зарезервированный на месте,
+// и сервис сохраняет ссылку под ним.
+func TestKeyPool_Empty(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	store := stalledReserver{MemoryStore: memorystore.NewMemoryStore(logger), done: make(chan struct{})}
+	ctx := context.Background()
+
+	generator, err := service.NewSequentialIDGenerator(4, service.AlphabetBase62, "")
+	require.NoError(t, err)
+	pool, err := service.NewKeyPool(generator, store, service.KeyPoolConfig{Size: 10, LowWatermark: 5, BatchSize: 4},
+		logger)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		close(store.done)
+		assert.NoError(t, pool.Close(ctx))
+	})
+	require.Zero(t, pool.Len())
+
+	id, err := pool.GenerateID("")
+	require.NoError(t, err)
+	reserved, err := store.ReserveIDs(ctx, []string{id})
+	require.NoError(t, err)
+	assert.Empty(t, reserved, "ID must be reserved")
+
+	srv := service.NewURLServiceWithGenerator(store, pool, newPrivateGenerator(t))
+	shortURL, err := srv.Shorten(ctx, "http://localhost:8080", "http://example.com", service.ShortenOptions{})
+	require.NoError(t, err)
+	saved, err := store.Find(ctx, strings.TrimPrefix(shortURL, "http://localhost:8080/"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", saved)
+}
+
+// TestKeyPool_Release тестирует, что резерв с ID, под которым ссылка не сохранена из-за конфликта URL,
+// снимается сразу, а не при остановке пула.
+func TestKeyPool_Release(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	store := stalledReserver{MemoryStore: memorystore.NewMemoryStore(logger), done: make(chan struct{})}
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, "taken", "http://example.com"))
+
+	// Пул пуст, поэтому ID генерируется на месте: первый ID той же последовательности.
+	expected, err := service.NewSequentialIDGenerator(4, service.AlphabetBase62, "")
+	require.NoError(t, err)
+	id, err := expected.GenerateID("")
+	require.NoError(t, err)
+
+	generator, err := service.NewSequentialIDGenerator(4, service.AlphabetBase62, "")
+	require.NoError(t, err)
+	pool, err := service.NewKeyPool(generator, store, service.KeyPoolConfig{Size: 10, LowWatermark: 5, BatchSize: 4},
+		logger)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		close(store.done)
+		assert.NoError(t, pool.Close(ctx))
+	})
+
+	srv := service.NewURLServiceWithGenerator(store, pool, newPrivateGenerator(t))
+	_, err = srv.Shorten(ctx, "http://localhost:8080", "http://example.com", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrURLConflict)
+
+	reserved, err := store.ReserveIDs(ctx, []string{id})
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, reserved, "ID must be released")
+}
+
+// TestKeyPool_InvalidConfig тестирует отказ от некорректных настроек пула.
+func TestKeyPool_InvalidConfig(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	store := memorystore.NewMemoryStore(logger)
+
+	random, err := service.NewRandomIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	_, err = service.NewKeyPool(random, store, service.KeyPoolConfig{Size: 10, LowWatermark: 10}, logger)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+
+	hash, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	_, err = service.NewKeyPool(hash, store, service.KeyPoolConfig{Size: 10, LowWatermark: 2}, logger)
+	require.ErrorIs(t, err, service.ErrInvalidIDConfig)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// resolveTimeout - ограничение времени разрешения имени хоста при проверке URL.
+const resolveTimeout = 5 * time.Second
+
+// ErrPrivateDestination возвращается для URL, ведущих во внутреннюю сеть.
+var ErrPrivateDestination = errors.New("URL points to a private network")
+
+// Resolver разрешает имя хоста в IP-адреса. Его реализует *net.Resolver,
+// а в тестах его заменяет подделка, не обращающаяся к DNS.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// reservedPrefixes - диапазоны внутренних адресов, которые не покрываются методами netip.Addr.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "Эта" сеть
+	netip.MustParsePrefix("100.64.0.0/10"), // Shared address space (CGNAT), в т. ч. метаданные Alibaba Cloud
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments, в т. ч. метаданные Oracle Cloud
+}
+
+// NetworkGuard не дает сокращать ссылки на внутренние адреса: loopback, link-local
+// (в т. ч. сервис метаданных облака 169.254.169.254), частные сети RFC 1918 и IPv6 ULA.
+// Имя хоста разрешается через Resolver, и ссылка отклоняется, если хотя бы один адрес внутренний.
+type NetworkGuard struct {
+	resolver Resolver
+}
+
+// NewNetworkGuard создает проверку, разрешающую имена хостов через resolver.
+func NewNetworkGuard(resolver Resolver) *NetworkGuard {
+	return &NetworkGuard{resolver: resolver}
+}
+
+// Check проверяет, что хост не ведет во внутреннюю сеть.
+// Ошибка не раскрывает, в какие адреса разрешилось имя.
+func (g *NetworkGuard) Check(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isPrivateAddr(addr) {
+			return fmt.Errorf("%s: %w", host, ErrPrivateDestination)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve host %q: %w", ErrInvalidURL, host, err)
+	}
+	for _, addr := range addrs {
+		if isPrivateAddr(addr) {
+			return fmt.Errorf("%s: %w", host, ErrPrivateDestination)
+		}
+	}
+	return nil
+}
+
+// isPrivateAddr сообщает, относится ли адрес к внутренней сети.
+// IPv4-адреса, записанные как IPv6 (::ffff:127.0.0.1), проверяются как IPv4.
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+var errNoSuchHost = errors.New("no such host")
+
+// fakeResolver разрешает имена по таблице, не обращаясь к DNS.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _ string, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errNoSuchHost
+	}
+	result := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		result = append(result, netip.MustParseAddr(addr))
+	}
+	return result, nil
+}
+
+func TestNetworkGuard_Check(t *testing.T) {
+	guard := service.NewNetworkGuard(fakeResolver{
+		"example.com":              {"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+		"intranet.corp":            {"10.1.2.3"},
+		"metadata.google.internal": {"169.254.169.254"},
+		"rebind.example":           {"93.184.215.14", "127.0.0.1"},
+		"ula.example":              {"fd00:ec2::254"},
+	})
+
+	tests := []struct {
+		host    string
+		private bool
+	}{
+		{host: "example.com", private: false},
+		{host: "8.8.8.8", private: false},
+		{host: "intranet.corp", private: true},
+		{host: "metadata.google.internal", private: true},
+		{host: "rebind.example", private: true},
+		{host: "ula.example", private: true},
+		{host: "127.0.0.1", private: true},
+		{host: "0.0.0.0", private: true},
+		{host: "192.168.1.1", private: true},
+		{host: "172.16.0.1", private: true},
+		{host: "100.100.100.200", private: true},
+		{host: "::1", private: true},
+		{host: "::ffff:127.0.0.1", private: true},
+		{host: "fe80::1", private: true},
+	}
+	for _, tt := range tests {
+		err := guard.Check(context.Background(), tt.host)
+		if tt.private {
+			require.ErrorIs(t, err, service.ErrPrivateDestination, tt.host)
+			assert.NotContains(t, err.Error(), "169.254", "resolved address must not leak")
+		} else {
+			require.NoError(t, err, tt.host)
+		}
+	}
+
+	err := guard.Check(context.Background(), "unknown.example")
+	require.ErrorIs(t, err, service.ErrInvalidURL)
+}
+
+func TestURLService_NetworkGuard(t *testing.T) {
+	guard := service.NewNetworkGuard(fakeResolver{
+		"example.com":   {"93.184.215.14"},
+		"intranet.corp": {"10.1.2.3"},
+	})
+	srv := service.NewURLServiceWithConfig(memorystore.NewMemoryStore(zaptest.NewLogger(t)), service.URLServiceConfig{
+		NetworkGuard: guard,
+	})
+	ctx := context.Background()
+
+	_, err := srv.Shorten(ctx, testBaseURL, "http://example.com/page", service.ShortenOptions{})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://intranet.corp/wiki", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrPrivateDestination)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://[::1]:8081/admin", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrPrivateDestination)
+	_, err = srv.ShortenBatch(ctx, testBaseURL, "", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com/other"},
+		{CorrelationID: "2", OriginalURL: "http://192.168.0.1/"},
+	})
+	require.ErrorIs(t, err, service.ErrPrivateDestination)
+}
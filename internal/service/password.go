@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordLength - максимальная длина пароля ссылки в байтах: bcrypt не учитывает байты после 72-го.
+const MaxPasswordLength = 72
+
+var (
+	// ErrInvalidPassword возвращается для некорректного пароля в запросе на сокращение.
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrPasswordRequired возвращается при переходе по ссылке, защищенной паролем.
+	ErrPasswordRequired = errors.New("password required")
+	// ErrWrongPassword возвращается, если введен неверный пароль ссылки.
+	ErrWrongPassword = errors.New("wrong password")
+)
+
+// hashPassword проверяет пароль из запроса и возвращает его bcrypt-хеш со случайной солью.
+// Пустой пароль означает ссылку без пароля.
+func (s *URLService) hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidPassword, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to hash password: %w", ErrInternalServer, err)
+	}
+	return string(hash), nil
+}
+
+// UnlockURL получает оригинальный URL ссылки, защищенной паролем, если password верен.
+// client - адрес клиента: неудачные попытки ограничиваются для каждой ссылки и каждого клиента,
+// и при превышении лимита пароль не проверяется, а возвращается *ThrottledError.
+// Ссылка без пароля открывается так же, как через GetOriginalURL.
+func (s *URLService) UnlockURL(
+	ctx context.Context,
+	baseURL string,
+	id string,
+	password string,
+	client string,
+) (string, error) {
+	chain, err := s.resolveSelfLink(ctx, baseURL, id)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("unlock URL canceled: %w", ctxErr)
+		}
+		return "", err
+	}
+
+	if link, ok := chain.protected(); ok {
+		// Пароль проверяется для ссылки, защищенной первой: попытки считаются по ее ID.
+		if err := s.passwordLimiter.Acquire(link.UUID, client); err != nil {
+			return "", fmt.Errorf("%s: %w", id, err)
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return "", fmt.Errorf("%s: %w", id, ErrWrongPassword)
+		}
+		s.passwordLimiter.Release(link.UUID, client)
+	}
+	return s.follow(ctx, id, chain)
+}
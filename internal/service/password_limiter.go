@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"sync"
+	"time"
+)
+
+// ErrTooManyAttempts возвращается, если превышено количество неудачных попыток ввода пароля.
+var ErrTooManyAttempts = errors.New("too many password attempts")
+
+// ThrottledError сообщает, через сколько можно повторить попытку ввода пароля.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// attemptWindow - неудачные попытки, засчитанные в текущем окне.
+type attemptWindow struct {
+	count int
+	reset time.Time // Момент окончания окна
+}
+
+// PasswordLimiter ограничивает подбор паролей ссылок: неудачные попытки считаются в фиксированном окне
+// отдельно для каждой ссылки (со всех клиентов) и для каждого клиента (по всем ссылкам).
+// Удачные попытки не расходуют лимит, поэтому популярная ссылка не блокируется своими пользователями.
+type PasswordLimiter struct {
+	mu          sync.Mutex
+	linkLimit   int
+	clientLimit int
+	window      time.Duration
+	now         func() time.Time
+	attempts    map[string]*attemptWindow // "link:<id>" или "client:<адрес>" -> окно
+	nextSweep   time.Time                 // Момент, после которого удаляются закончившиеся окна
+}
+
+// NewPasswordLimiter создает ограничение с настройками из конфигурации, получающее время из now.
+func NewPasswordLimiter(cfg config.PasswordConfig, now func() time.Time) *PasswordLimiter {
+	if cfg.MaxLinkAttempts <= 0 {
+		cfg.MaxLinkAttempts = config.DefaultMaxLinkAttempts
+	}
+	if cfg.MaxClientAttempts <= 0 {
+		cfg.MaxClientAttempts = config.DefaultMaxClientAttempts
+	}
+	if cfg.AttemptWindow <= 0 {
+		cfg.AttemptWindow = config.DefaultAttemptWindow
+	}
+	return &PasswordLimiter{
+		linkLimit:   cfg.MaxLinkAttempts,
+		clientLimit: cfg.MaxClientAttempts,
+		window:      cfg.AttemptWindow,
+		now:         now,
+		attempts:    make(map[string]*attemptWindow),
+	}
+}
+
+// Acquire засчитывает попытку ввода пароля ссылки id с клиента client заранее, до проверки пароля,
+// чтобы одновременные запросы не проверили больше паролей, чем позволяет лимит.
+// Если лимит исчерпан, попытка не засчитывается и возвращается *ThrottledError.
+// Удачную попытку нужно вернуть через Release.
+func (l *PasswordLimiter) Acquire(id string, client string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	link := l.current(linkKey(id), now)
+	cl := l.current(clientKey(client), now)
+
+	var retryAfter time.Duration
+	if link.count >= l.linkLimit {
+		retryAfter = link.reset.Sub(now)
+	}
+	if cl.count >= l.clientLimit {
+		retryAfter = max(retryAfter, cl.reset.Sub(now))
+	}
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+	link.count++
+	cl.count++
+	return nil
+}
+
+// Release возвращает попытку, засчитанную Acquire, если пароль оказался верным.
+func (l *PasswordLimiter) Release(id string, client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range []string{linkKey(id), clientKey(client)} {
+		if w, ok := l.attempts[key]; ok && w.count > 0 {
+			w.count--
+		}
+	}
+}
+
+// current возвращает текущее окно ключа, начиная новое, если прежнее закончилось.
+func (l *PasswordLimiter) current(key string, now time.Time) *attemptWindow {
+	w, ok := l.attempts[key]
+	if !ok || !now.Before(w.reset) {
+		w = &attemptWindow{reset: now.Add(l.window)}
+		l.attempts[key] = w
+	}
+	return w
+}
+
+// sweep удаляет закончившиеся окна не чаще раза в окно, чтобы счетчики не копились бесконечно.
+func (l *PasswordLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	for key, w := range l.attempts {
+		if !now.Before(w.reset) {
+			delete(l.attempts, key)
+		}
+	}
+	l.nextSweep = now.Add(l.window)
+}
+
+func linkKey(id string) string {
+	return "link:" + id
+}
+
+func clientKey(client string) string {
+	return "client:" + client
+}
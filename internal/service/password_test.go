@@ -0,0 +1,179 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"linkshrink/internal/config"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newPasswordService(
+	t *testing.T,
+	cfg config.PasswordConfig,
+) (*service.URLService, *memorystore.MemoryStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	srv := service.NewURLServiceWithConfig(store, service.URLServiceConfig{
+		PasswordLimiter: service.NewPasswordLimiter(cfg, clock.Now),
+		PasswordCost:    bcrypt.MinCost,
+		Now:             clock.Now,
+	})
+	return srv, store, clock
+}
+
+// TestURLService_Password тестирует, что ссылка с паролем открывается только с верным паролем,
+// а пароль хранится в виде хеша.
+func TestURLService_Password(t *testing.T) {
+	srv, store, _ := newPasswordService(t, config.PasswordConfig{})
+	ctx := context.Background()
+
+	shortURL, err := srv.Shorten(ctx, testBaseURL, "http://example.com/secret",
+		service.ShortenOptions{Password: "hunter2"})
+	require.NoError(t, err)
+	id := strings.TrimPrefix(shortURL, testBaseURL+"/")
+
+	stored := store.Store[id]
+	assert.NotEmpty(t, stored.PasswordHash)
+	assert.NotContains(t, stored.PasswordHash, "hunter2")
+
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, id)
+	require.ErrorIs(t, err, service.ErrPasswordRequired)
+
+	_, err = srv.UnlockURL(ctx, testBaseURL, id, "hunter3", "192.0.2.1")
+	require.ErrorIs(t, err, service.ErrWrongPassword)
+
+	originalURL, err := srv.UnlockURL(ctx, testBaseURL, id, "hunter2", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/secret", originalURL)
+
+	// Ссылка на ссылку с паролем не позволяет его обойти.
+	_, err = srv.Shorten(ctx, testBaseURL, shortURL, service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrSelfReference)
+}
+
+// TestURLService_Password_NotShared тестирует, что ссылка с паролем не выдается запросам на тот же URL
+// без пароля, а обычная ссылка не выдается запросам с паролем.
+func TestURLService_Password_NotShared(t *testing.T) {
+	srv, _, _ := newPasswordService(t, config.PasswordConfig{})
+	ctx := context.Background()
+
+	protected, err := srv.Shorten(ctx, testBaseURL, "http://example.com/report",
+		service.ShortenOptions{Password: "hunter2"})
+	require.NoError(t, err)
+	public, err := srv.Shorten(ctx, testBaseURL, "http://example.com/report", service.ShortenOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, protected, public)
+
+	originalURL, err := srv.GetOriginalURL(ctx, testBaseURL, strings.TrimPrefix(public, testBaseURL+"/"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/report", originalURL)
+
+	again, err := srv.Shorten(ctx, testBaseURL, "http://example.com/report",
+		service.ShortenOptions{Password: "letmein"})
+	require.NoError(t, err)
+	assert.NotContains(t, []string{protected, public}, again)
+	_, err = srv.UnlockURL(ctx, testBaseURL, strings.TrimPrefix(again, testBaseURL+"/"), "letmein", "192.0.2.1")
+	require.NoError(t, err)
+}
+
+// TestURLService_Password_Invalid тестирует отклонение слишком длинного пароля.
+func TestURLService_Password_Invalid(t *testing.T) {
+	srv, store, _ := newPasswordService(t, config.PasswordConfig{})
+
+	_, err := srv.Shorten(context.Background(), testBaseURL, "http://example.com",
+		service.ShortenOptions{Password: strings.Repeat("x", service.MaxPasswordLength+1)})
+	require.ErrorIs(t, err, service.ErrInvalidPassword)
+	assert.Empty(t, store.Store)
+}
+
+// TestURLService_Password_Throttling тестирует, что неудачные попытки ограничиваются
+// для ссылки со всех клиентов и для клиента по всем ссылкам, а удачные лимит не расходуют.
+func TestURLService_Password_Throttling(t *testing.T) {
+	srv, _, clock := newPasswordService(t, config.PasswordConfig{
+		MaxLinkAttempts:   3,
+		MaxClientAttempts: 3,
+		AttemptWindow:     time.Minute,
+	})
+	ctx := context.Background()
+	shorten := func(originalURL string) string {
+		shortURL, err := srv.Shorten(ctx, testBaseURL, originalURL, service.ShortenOptions{Password: "secret"})
+		require.NoError(t, err)
+		return strings.TrimPrefix(shortURL, testBaseURL+"/")
+	}
+	first, second := shorten("http://example.com/1"), shorten("http://example.com/2")
+
+	for range 5 {
+		_, err := srv.UnlockURL(ctx, testBaseURL, first, "secret", "192.0.2.1")
+		require.NoError(t, err)
+	}
+
+	// Лимит ссылки: попытки с разных клиентов суммируются.
+	for _, client := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		_, err := srv.UnlockURL(ctx, testBaseURL, first, "guess", client)
+		require.ErrorIs(t, err, service.ErrWrongPassword)
+	}
+	_, err := srv.UnlockURL(ctx, testBaseURL, first, "secret", "192.0.2.4")
+	var throttled *service.ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, time.Minute, throttled.RetryAfter)
+	require.ErrorIs(t, err, service.ErrTooManyAttempts)
+
+	// Лимит клиента: попытки на разных ссылках суммируются, лимит второй ссылки при этом не исчерпан.
+	for range 2 {
+		_, err = srv.UnlockURL(ctx, testBaseURL, second, "guess", "192.0.2.1")
+		require.ErrorIs(t, err, service.ErrWrongPassword)
+	}
+	_, err = srv.UnlockURL(ctx, testBaseURL, second, "secret", "192.0.2.1")
+	require.ErrorIs(t, err, service.ErrTooManyAttempts)
+	_, err = srv.UnlockURL(ctx, testBaseURL, second, "secret", "192.0.2.2")
+	require.NoError(t, err)
+
+	clock.Advance(time.Minute)
+	originalURL, err := srv.UnlockURL(ctx, testBaseURL, first, "secret", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/1", originalURL)
+}
+
+// TestPasswordLimiter_Concurrent тестирует, что одновременные попытки не превышают лимит.
+func TestPasswordLimiter_Concurrent(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := service.NewPasswordLimiter(config.PasswordConfig{
+		MaxLinkAttempts:   5,
+		MaxClientAttempts: 100,
+		AttemptWindow:     time.Minute,
+	}, clock.Now)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Acquire("abc", "192.0.2.1") == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, allowed)
+
+	limiter.Release("abc", "192.0.2.1")
+	require.NoError(t, limiter.Acquire("abc", "192.0.2.1"))
+	require.ErrorIs(t, limiter.Acquire("abc", "192.0.2.1"), service.ErrTooManyAttempts)
+}
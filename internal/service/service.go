@@ -1,61 +1,687 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"linkshrink/internal/config"
 	"linkshrink/internal/repository"
+	"linkshrink/internal/utils/logger"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrInvalidURL     = errors.New("invalid URL")
 	ErrURLNotFound    = errors.New("URL not found")
 	ErrInternalServer = errors.New("internal Server Error")
+	ErrInvalidBatch   = errors.New("invalid batch")
+	// ErrURLConflict возвращается вместе с уже существующей короткой ссылкой,
+	// если оригинальный URL был сокращен ранее.
+	ErrURLConflict = errors.New("URL already shortened")
+
+	// errRetry сообщает, что мешавшая сохранению ссылка истекла и удалена, и сохранение нужно повторить.
+	errRetry = errors.New("expired link deleted, retry")
+)
+
+const (
+	// idSaltSize - размер случайной соли в байтах, которая добавляется к URL ссылок вне дедупликации
+	// перед генерацией ID (см. idSource).
+	idSaltSize = 8
+	// maxAttempts - максимальное количество попыток сохранить ссылку.
+	maxAttempts = 10
 )
 
 type IURLService interface {
-	Shorten(baseURL string, url string) (string, error)
-	GetOriginalURL(id string) (string, error)
+	Shorten(ctx context.Context, baseURL string, url string, opts ShortenOptions) (string, error)
+	ShortenBatch(ctx context.Context, baseURL string, userID string, items []BatchItem) ([]BatchResult, error)
+	GetOriginalURL(ctx context.Context, baseURL string, id string) (string, error)
+	UnlockURL(ctx context.Context, baseURL string, id string, password string, client string) (string, error)
+	GetUserURLs(ctx context.Context, baseURL string, userID string) ([]UserURL, error)
+	DeleteUserURLs(ctx context.Context, userID string, ids []string) error
+}
+
+// ShortenOptions - параметры сокращения URL, заданные в запросе.
+type ShortenOptions struct {
+	// Private запрашивает приватную ссылку с длинным неперебираемым ID. Приватная ссылка всегда
+	// создается заново и не выдается другим запросам на тот же URL.
+	Private bool
+	// Alias - ID, выбранный пользователем вместо сгенерированного.
+	Alias string
+	// ExpiresAt - момент, после которого ссылка перестает работать. Нулевое время - ссылка бессрочная.
+	// Ссылка со сроком действия, как и приватная, всегда создается заново и не выдается другим запросам
+	// на тот же URL, а бессрочная ссылка не выдается вместо нее.
+	ExpiresAt time.Time
+	// TTL - срок действия ссылки с момента сокращения. Задается вместо ExpiresAt.
+	TTL time.Duration
+	// MaxClicks - количество переходов, после которого ссылка перестает работать. 0 - без ограничения.
+	// Ссылка с лимитом, как и приватная, всегда создается заново и не выдается другим запросам на тот же URL.
+	MaxClicks int
+	// Password - пароль, без которого ссылка не открывается. Пустой - ссылка без пароля.
+	// Ссылка с паролем, как и приватная, всегда создается заново и не выдается другим запросам на тот же URL.
+	Password string
+	// UserID - пользователь, создающий ссылку. Если URL уже был сокращен, существующая ссылка
+	// остается за своим владельцем.
+	UserID string
+}
+
+// BatchItem - элемент пакетного запроса на сокращение.
+// Ограничения ссылки задаются так же, как в ShortenOptions.
+type BatchItem struct {
+	CorrelationID string // Идентификатор элемента, заданный клиентом
+	OriginalURL   string
+	Private       bool
+	ExpiresAt     time.Time
+	TTL           time.Duration
+	MaxClicks     int
+	Password      string
+}
+
+// BatchResult - результат сокращения элемента пакета.
+type BatchResult struct {
+	CorrelationID string
+	ShortURL      string
 }
 
 type URLService struct {
-	repo        repository.IURLRepository
-	idGenerator *IDGenerator
+	repo               repository.IURLRepository
+	idGenerator        IDGenerator
+	privateIDGenerator IDGenerator // Генератор ID приватных ссылок
+	normalizer         *URLNormalizer
+	blocklist          *Blocklist // nil, если блок-лист не используется
+	shorteners         *ShortenerDetector
+	networkGuard       *NetworkGuard // nil, если ссылки во внутреннюю сеть разрешены
+	passwordLimiter    *PasswordLimiter
+	passwordCost       int      // Стоимость bcrypt для паролей ссылок
+	deleter            *Deleter // nil, если хранилище не поддерживает удаление ссылок
+	expiredRetention   time.Duration
+	now                func() time.Time
+	logger             logger.Logger
 }
 
+// URLServiceConfig - зависимости сервиса, которые можно заменить.
+// Незаданные поля получают значения по умолчанию.
+type URLServiceConfig struct {
+	IDGenerator        IDGenerator        // Генератор ID обычных ссылок
+	PrivateIDGenerator IDGenerator        // Генератор ID приватных ссылок
+	Normalizer         *URLNormalizer     // Проверка и нормализация оригинальных URL
+	Blocklist          *Blocklist         // Заблокированные домены, nil - блокировка не используется
+	Shorteners         *ShortenerDetector // Известные сервисы сокращения ссылок
+	NetworkGuard       *NetworkGuard      // Запрет ссылок во внутреннюю сеть, nil - ссылки разрешены
+	PasswordLimiter    *PasswordLimiter   // Ограничение подбора паролей ссылок
+	PasswordCost       int                // Стоимость bcrypt для паролей ссылок, 0 - bcrypt.DefaultCost
+	Deleter            *Deleter           // Фоновое удаление ссылок, nil - удаление не поддерживается
+	// ExpiredRetention - сколько истекшая ссылка хранится, прежде чем ее ID и URL можно занять заново.
+	// Должно совпадать с config.ExpirationConfig.Retention, с которым работает Reaper.
+	ExpiredRetention time.Duration
+	Now              func() time.Time // Источник текущего времени для проверки срока действия ссылок
+	Logger           logger.Logger
+}
+
+// NewURLService создает сервис, генерирующий случайные ID длины по умолчанию.
 func NewURLService(repo repository.IURLRepository) *URLService {
+	return NewURLServiceWithConfig(repo, URLServiceConfig{})
+}
+
+// NewURLServiceWithGenerator создает сервис с заданными генераторами ID обычных и приватных ссылок.
+func NewURLServiceWithGenerator(
+	repo repository.IURLRepository,
+	idGenerator IDGenerator,
+	privateIDGenerator IDGenerator,
+) *URLService {
+	return NewURLServiceWithConfig(repo, URLServiceConfig{
+		IDGenerator:        idGenerator,
+		PrivateIDGenerator: privateIDGenerator,
+	})
+}
+
+// NewURLServiceWithConfig создает сервис с заданными зависимостями.
+func NewURLServiceWithConfig(repo repository.IURLRepository, cfg URLServiceConfig) *URLService {
+	if cfg.IDGenerator == nil {
+		cfg.IDGenerator = defaultIDGenerator()
+	}
+	if cfg.PrivateIDGenerator == nil {
+		cfg.PrivateIDGenerator = defaultPrivateIDGenerator()
+	}
+	if cfg.Normalizer == nil {
+		cfg.Normalizer = DefaultURLNormalizer()
+	}
+	if cfg.Shorteners == nil {
+		cfg.Shorteners = DefaultShortenerDetector()
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.PasswordLimiter == nil {
+		cfg.PasswordLimiter = NewPasswordLimiter(config.PasswordConfig{}, cfg.Now)
+	}
+	if cfg.PasswordCost == 0 {
+		cfg.PasswordCost = bcrypt.DefaultCost
+	}
+	if cfg.ExpiredRetention < 0 {
+		cfg.ExpiredRetention = 0
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
 	return &URLService{ // Возвращаем новый сервис с заданным репозиторием
-		repo:        repo,
-		idGenerator: NewIDGenerator(),
+		repo:               repo,
+		idGenerator:        cfg.IDGenerator,
+		privateIDGenerator: cfg.PrivateIDGenerator,
+		normalizer:         cfg.Normalizer,
+		blocklist:          cfg.Blocklist,
+		shorteners:         cfg.Shorteners,
+		networkGuard:       cfg.NetworkGuard,
+		passwordLimiter:    cfg.PasswordLimiter,
+		passwordCost:       cfg.PasswordCost,
+		deleter:            cfg.Deleter,
+		expiredRetention:   cfg.ExpiredRetention,
+		now:                cfg.Now,
+		logger:             cfg.Logger.With(zap.String("component", "URLService")),
+	}
+}
+
+func defaultIDGenerator() IDGenerator {
+	idGenerator, err := NewRandomIDGenerator(config.DefaultIDLength, AlphabetBase62)
+	if err != nil {
+		// Параметры по умолчанию корректны, ошибка здесь - ошибка программиста.
+		panic(err)
 	}
+	return idGenerator
 }
 
-// Shorten сокращает оригинальный URL.
-func (s *URLService) Shorten(baseURL string, originalURL string) (string, error) {
-	if originalURL == "" {
-		return "", fmt.Errorf("url is empty: %w ", ErrInvalidURL)
+func defaultPrivateIDGenerator() IDGenerator {
+	generator, err := NewPrivateIDGenerator(config.IDConfig{
+		Alphabet:           AlphabetBase62,
+		PrivateEntropyBits: config.DefaultPrivateEntropyBits,
+	})
+	if err != nil {
+		panic(err)
 	}
+	return generator
+}
 
-	const maxAttempts = 10 // Максимальное количество попыток
-	attempts := 0
+// generator выбирает генератор ID для ссылки.
+func (s *URLService) generator(private bool) IDGenerator {
+	if private {
+		return s.privateIDGenerator
+	}
+	return s.idGenerator
+}
 
-	for attempts < maxAttempts {
-		id := s.idGenerator.GenerateID()
-		err := s.repo.Save(id, originalURL)
+// reservesIDs сообщает, выдает ли генератор ID, зарезервированные в хранилище (см. KeyPool).
+// Такие ID сохраняются с признаком Reserved, остальным запросам хранилище их не отдает.
+func reservesIDs(g IDGenerator) bool {
+	_, ok := g.(*KeyPool)
+	return ok
+}
+
+// releaseIDs снимает резерв с ID пула ключей, которые не удалось сохранить не из-за коллизии.
+// Ошибка только записывается в журнал: неснятый резерв устареет сам (см. repository.ReservationTTL).
+func (s *URLService) releaseIDs(g IDGenerator, ids ...string) {
+	pool, ok := g.(*KeyPool)
+	if !ok || len(ids) == 0 {
+		return
+	}
+	if err := pool.Release(ids...); err != nil {
+		s.logger.Error("Error releasing reserved IDs", zap.Error(err))
+	}
+}
+
+// deterministic сообщает, выдает ли генератор одному URL один и тот же ID (см. HashIDGenerator).
+func deterministic(g IDGenerator) bool {
+	_, ok := g.(*HashIDGenerator)
+	return ok
+}
+
+// idSource возвращает строку, из которой генерируется ID ссылки. Детерминированные стратегии выдают
+// одному URL один и тот же ID, поэтому к URL ссылки вне дедупликации добавляется случайная соль:
+// иначе она всегда получала бы ID общей ссылки на тот же URL.
+func idSource(link repository.URLData) (string, error) {
+	if !link.Exclusive {
+		return link.OriginalURL, nil
+	}
+	salt := make([]byte, idSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate ID salt: %w", err)
+	}
+	return link.OriginalURL + "#" + hex.EncodeToString(salt), nil
+}
+
+// Shorten сокращает оригинальный URL, предварительно проверив и нормализовав его.
+// Если URL уже был сокращен, возвращается существующая короткая ссылка и ErrURLConflict,
+// а если ее срок действия истек, она удаляется и URL сокращается заново.
+// При коллизиях попытки повторяются, но не более maxAttempts раз: генератор учитывает коллизии
+// и увеличивает длину ID, если они случаются часто.
+func (s *URLService) Shorten(
+	ctx context.Context,
+	baseURL string,
+	originalURL string,
+	opts ShortenOptions,
+) (string, error) {
+	link, err := s.linkLimits(opts)
+	if err != nil {
+		return "", err
+	}
+	originalURL, err = s.checkURL(ctx, baseURL, originalURL)
+	if err != nil {
+		return "", err
+	}
+	link.OriginalURL = originalURL
+	link.Flagged = s.flagged(originalURL)
+	if opts.Alias != "" {
+		return s.shortenWithAlias(ctx, baseURL, link, opts)
+	}
+
+	idGenerator := s.generator(opts.Private)
+	link.Reserved = reservesIDs(idGenerator)
+	for range maxAttempts {
+		source, err := idSource(link)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInternalServer, err)
+		}
+		id, err := idGenerator.GenerateID(source)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to generate ID: %w", ErrInternalServer, err)
+		}
+		link.UUID = id
+		err = s.save(ctx, link)
 
 		if err == nil {
+			idGenerator.ReportSuccess(id)
 			return baseURL + "/" + id, nil
 		}
-		attempts++
+		if !errors.Is(err, repository.ErrIDAlreadyExists) {
+			// ID свободен, но ссылка под ним не сохранена: резерв с него больше не нужен.
+			s.releaseIDs(idGenerator, id)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("shorten canceled: %w", ctxErr)
+		}
+		if errors.Is(err, repository.ErrURLAlreadyExists) {
+			shortURL, err := s.existingShortURL(ctx, baseURL, originalURL)
+			if errors.Is(err, errRetry) {
+				continue
+			}
+			return shortURL, err
+		}
+		if !errors.Is(err, repository.ErrIDAlreadyExists) {
+			return "", fmt.Errorf("%w: failed to save URL: %w", ErrInternalServer, err)
+		}
+		// Детерминированные стратегии выдают тому же URL тот же ID, и хранилище
+		// может сообщить о занятом ID раньше, чем о занятом URL.
+		if !link.Exclusive && deterministic(idGenerator) && s.savedAs(ctx, id, originalURL) {
+			if s.freeExpired(ctx, id) {
+				continue
+			}
+			return baseURL + "/" + id, fmt.Errorf("%s: %w", originalURL, ErrURLConflict)
+		}
+		if err := idGenerator.ReportCollision(id); err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrInternalServer, originalURL, err)
+		}
+	}
+	return "", fmt.Errorf("%w: number of attempts exceeded: %s", ErrInternalServer, originalURL)
+}
+
+// shortenWithAlias сохраняет URL под алиасом, выбранным пользователем.
+// Уникальность алиаса проверяет хранилище так же, как для сгенерированных ID.
+// Алиас истекшей ссылки освобождается и может быть занят заново.
+func (s *URLService) shortenWithAlias(
+	ctx context.Context,
+	baseURL string,
+	link repository.URLData,
+	opts ShortenOptions,
+) (string, error) {
+	if opts.Private {
+		return "", fmt.Errorf("%w: private links can't have an alias", ErrInvalidAlias)
+	}
+	if err := validateAlias(opts.Alias); err != nil {
+		return "", err
 	}
 
+	originalURL := link.OriginalURL
+	link.UUID = opts.Alias
+	for range maxAttempts {
+		err := s.save(ctx, link)
+		if err == nil {
+			return baseURL + "/" + opts.Alias, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("shorten canceled: %w", ctxErr)
+		}
+		switch {
+		case errors.Is(err, repository.ErrURLAlreadyExists):
+			shortURL, err := s.existingShortURL(ctx, baseURL, originalURL)
+			if errors.Is(err, errRetry) {
+				continue
+			}
+			return shortURL, err
+		case errors.Is(err, repository.ErrIDAlreadyExists):
+			if s.freeExpired(ctx, opts.Alias) {
+				continue
+			}
+			// Повторный запрос с тем же алиасом и URL - не захват чужого алиаса.
+			// Ссылка вне дедупликации не может совпадать с уже сохраненной.
+			if !link.Exclusive && s.savedAs(ctx, opts.Alias, originalURL) {
+				return baseURL + "/" + opts.Alias, fmt.Errorf("%s: %w", originalURL, ErrURLConflict)
+			}
+			return "", fmt.Errorf("%q: %w", opts.Alias, ErrAliasTaken)
+		default:
+			return "", fmt.Errorf("%w: failed to save URL: %w", ErrInternalServer, err)
+		}
+	}
 	return "", fmt.Errorf("%w: number of attempts exceeded: %s", ErrInternalServer, originalURL)
 }
 
+// linkLimits проверяет срок действия, лимит переходов и пароль из запроса и возвращает запись ссылки с ними.
+func (s *URLService) linkLimits(opts ShortenOptions) (repository.URLData, error) {
+	expiresAt, err := s.expiresAt(opts)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+	if err := s.checkMaxClicks(opts.MaxClicks); err != nil {
+		return repository.URLData{}, err
+	}
+
+	passwordHash, err := s.hashPassword(opts.Password)
+	if err != nil {
+		return repository.URLData{}, err
+	}
+
+	link := repository.URLData{
+		MaxClicks:    opts.MaxClicks,
+		PasswordHash: passwordHash,
+		UserID:       opts.UserID,
+		Exclusive:    opts.Private || !expiresAt.IsZero() || opts.MaxClicks > 0 || passwordHash != "",
+	}
+	if !expiresAt.IsZero() {
+		link.ExpiresAt = &expiresAt
+	}
+	return link, nil
+}
+
+// save сохраняет ссылку со всеми ее полями.
+func (s *URLService) save(ctx context.Context, link repository.URLData) error {
+	if err := s.repo.SaveURL(ctx, link); err != nil {
+		return fmt.Errorf("%s: %w", link.UUID, err)
+	}
+	return nil
+}
+
+// existingShortURL возвращает короткую ссылку, под которой URL был сокращен ранее, и ErrURLConflict.
+// Если срок действия этой ссылки истек, она удаляется и возвращается errRetry.
+func (s *URLService) existingShortURL(ctx context.Context, baseURL string, originalURL string) (string, error) {
+	existingID, err := s.repo.FindByOriginalURL(ctx, originalURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to find existing URL: %w", ErrInternalServer, err)
+	}
+	if s.freeExpired(ctx, existingID) {
+		return "", errRetry
+	}
+	return baseURL + "/" + existingID, fmt.Errorf("%s: %w", originalURL, ErrURLConflict)
+}
+
+// savedAs сообщает, сохранен ли под id именно originalURL.
+func (s *URLService) savedAs(ctx context.Context, id string, originalURL string) bool {
+	stored, err := s.repo.Find(ctx, id)
+	return err == nil && stored == originalURL
+}
+
+// ShortenBatch сокращает пакет URL по принципу "все или ничего": если хотя бы один
+// элемент некорректен или не может быть сохранен, не сохраняется ни один.
+// Результаты возвращаются в порядке элементов запроса.
+// Уже сокращенные URL (и повторы внутри пакета) получают существующий ID, пакет при этом не считается конфликтом.
+// Элементы вне дедупликации (приватные, со сроком действия, лимитом переходов или паролем), как и в Shorten,
+// всегда получают новые ссылки. Новые ссылки принадлежат пользователю userID.
+func (s *URLService) ShortenBatch(
+	ctx context.Context,
+	baseURL string,
+	userID string,
+	items []BatchItem,
+) ([]BatchResult, error) {
+	links, err := s.validateBatch(ctx, baseURL, userID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]string) // Оригинальный URL -> уже сохраненный ID
+
+	for range maxAttempts {
+		ids := make(map[string]string, len(items))          // Оригинальный URL -> ID в этом пакете
+		exclusiveIDs := make(map[string]string, len(items)) // Correlation ID элемента вне дедупликации -> ID
+		urls := make([]repository.URLData, 0, len(items))
+		private := make(map[string]bool, len(items)) // ID приватных ссылок
+		for i, item := range items {
+			link := links[i]
+			if link.Exclusive {
+				idGenerator := s.generator(item.Private)
+				source, err := idSource(link)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %w", ErrInternalServer, err)
+				}
+				id, err := idGenerator.GenerateID(source)
+				if err != nil {
+					return nil, fmt.Errorf("%w: failed to generate ID: %w", ErrInternalServer, err)
+				}
+				link.UUID = id
+				link.Reserved = reservesIDs(idGenerator)
+				exclusiveIDs[item.CorrelationID] = id
+				private[id] = item.Private
+				urls = append(urls, link)
+				continue
+			}
+			if _, ok := ids[link.OriginalURL]; ok {
+				continue
+			}
+			if id, ok := existing[link.OriginalURL]; ok {
+				ids[link.OriginalURL] = id
+				continue
+			}
+			id, err := s.idGenerator.GenerateID(link.OriginalURL)
+			if err != nil {
+				return nil, fmt.Errorf("%w: failed to generate ID: %w", ErrInternalServer, err)
+			}
+			ids[link.OriginalURL] = id
+			link.UUID = id
+			link.Reserved = reservesIDs(s.idGenerator)
+			urls = append(urls, link)
+		}
+
+		var err error
+		if len(urls) > 0 {
+			err = s.repo.SaveBatch(ctx, urls)
+		}
+		if err == nil {
+			for _, url := range urls {
+				s.generator(private[url.UUID]).ReportSuccess(url.UUID)
+			}
+			results := make([]BatchResult, 0, len(items))
+			for i, item := range items {
+				id, ok := exclusiveIDs[item.CorrelationID]
+				if !ok {
+					id = ids[links[i].OriginalURL]
+				}
+				results = append(results, BatchResult{CorrelationID: item.CorrelationID, ShortURL: baseURL + "/" + id})
+			}
+			return results, nil
+		}
+
+		// Пакет не сохранен: следующая попытка получит новые ID, а резерв с этих снимается.
+		reserved := make(map[bool][]string)
+		for _, url := range urls {
+			if url.Reserved {
+				reserved[private[url.UUID]] = append(reserved[private[url.UUID]], url.UUID)
+			}
+		}
+		for isPrivate, ids := range reserved {
+			s.releaseIDs(s.generator(isPrivate), ids...)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("shorten batch canceled: %w", ctxErr)
+		}
+		if !errors.Is(err, repository.ErrURLAlreadyExists) && !errors.Is(err, repository.ErrIDAlreadyExists) {
+			return nil, fmt.Errorf("%w: failed to save batch: %w", ErrInternalServer, err)
+		}
+
+		// Пакет отклонен целиком, поэтому выясняем, какие URL уже сохранены, и повторяем без них.
+		// Занятый ID тоже может означать уже сохраненный URL, если стратегия детерминированная.
+		resolved := len(existing)
+		freed, resolveErr := s.resolveExisting(ctx, urls, existing)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if len(existing) > resolved || freed {
+			continue
+		}
+		if errors.Is(err, repository.ErrURLAlreadyExists) {
+			return nil, fmt.Errorf("%w: conflicting URL not found in batch", ErrInternalServer)
+		}
+		// Коллизия ID: повторяем попытку с новыми ID. Какой именно ID занят, неизвестно,
+		// поэтому пакет учитывается как одна коллизия.
+		if err := s.generator(private[urls[0].UUID]).ReportCollision(urls[0].UUID); err != nil {
+			return nil, fmt.Errorf("%w: batch of %d URLs: %w", ErrInternalServer, len(items), err)
+		}
+	}
+	return nil, fmt.Errorf("%w: number of attempts exceeded: batch of %d URLs", ErrInternalServer, len(items))
+}
+
+// resolveExisting добавляет в existing ID уже сохраненных URL из пакета.
+// Истекшие ссылки на эти URL удаляются; первое значение сообщает, была ли удалена хотя бы одна.
+func (s *URLService) resolveExisting(
+	ctx context.Context,
+	urls []repository.URLData,
+	existing map[string]string,
+) (bool, error) {
+	freed := false
+	for _, url := range urls {
+		if url.Exclusive {
+			continue
+		}
+		id, err := s.repo.FindByOriginalURL(ctx, url.OriginalURL)
+		if errors.Is(err, repository.ErrURLNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: failed to find existing URL: %w", ErrInternalServer, err)
+		}
+		if s.freeExpired(ctx, id) {
+			freed = true
+			continue
+		}
+		existing[url.OriginalURL] = id
+	}
+	return freed, nil
+}
+
+// validateBatch проверяет пакет и возвращает записи ссылок его элементов с нормализованными URL
+// и ограничениями, но без ID, в порядке элементов.
+func (s *URLService) validateBatch(
+	ctx context.Context,
+	baseURL string,
+	userID string,
+	items []BatchItem,
+) ([]repository.URLData, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("batch is empty: %w", ErrInvalidBatch)
+	}
+
+	links := make([]repository.URLData, 0, len(items))
+	correlationIDs := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		if item.CorrelationID == "" {
+			return nil, fmt.Errorf("item %d: correlation_id is empty: %w", i, ErrInvalidBatch)
+		}
+		if _, ok := correlationIDs[item.CorrelationID]; ok {
+			return nil, fmt.Errorf("item %d: duplicate correlation_id %q: %w", i, item.CorrelationID, ErrInvalidBatch)
+		}
+		correlationIDs[item.CorrelationID] = struct{}{}
+
+		link, err := s.linkLimits(ShortenOptions{
+			Private:   item.Private,
+			ExpiresAt: item.ExpiresAt,
+			TTL:       item.TTL,
+			MaxClicks: item.MaxClicks,
+			Password:  item.Password,
+			UserID:    userID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("item %q: %w", item.CorrelationID, err)
+		}
+		originalURL, err := s.checkURL(ctx, baseURL, item.OriginalURL)
+		if err != nil {
+			return nil, fmt.Errorf("item %q: %w", item.CorrelationID, err)
+		}
+		link.OriginalURL = originalURL
+		link.Flagged = s.flagged(originalURL)
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// checkURL нормализует оригинальный URL, заменяет короткую ссылку сервиса адресом назначения
+// и проверяет, что URL не ведет на другой сервис сокращения или во внутреннюю сеть и его домен не заблокирован.
+func (s *URLService) checkURL(ctx context.Context, baseURL string, originalURL string) (string, error) {
+	normalized, err := s.normalizer.Normalize(originalURL)
+	if err != nil {
+		return "", err
+	}
+	if normalized, err = s.checkChain(ctx, baseURL, normalized); err != nil {
+		return "", err
+	}
+	if s.blocklist != nil && s.blocklist.BlockedURL(normalized) {
+		return "", fmt.Errorf("%s: %w", normalized, ErrURLBlocked)
+	}
+	if s.networkGuard != nil {
+		// Разрешение имени - самая дорогая проверка, поэтому она последняя.
+		u, err := url.Parse(normalized)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidURL, err)
+		}
+		if err := s.networkGuard.Check(ctx, u.Hostname()); err != nil {
+			return "", err
+		}
+	}
+	return normalized, nil
+}
+
 // GetOriginalURL получает оригинальный URL по ID.
-func (s *URLService) GetOriginalURL(id string) (string, error) {
-	originalURL, err := s.repo.Find(id)
+// Если ссылка ведет на другую короткую ссылку сервиса с адресом baseURL, возвращается конечный адрес,
+// а замкнутая цепочка ссылок дает ErrRedirectLoop.
+// Ссылки на домены, заблокированные после сокращения, больше не раскрываются: возвращается ErrURLBlocked.
+// Для ссылок с истекшим сроком действия или исчерпанным лимитом переходов возвращается ErrURLExpired,
+// для удаленных владельцем - ErrURLDeleted. Цепочка с несколькими ссылками с лимитом переходов
+// не раскрывается: возвращается ErrLimitedChain.
+// Ссылки, защищенные паролем (в том числе через цепочку), не раскрываются: возвращается ErrPasswordRequired,
+// и открыть их можно через UnlockURL.
+func (s *URLService) GetOriginalURL(ctx context.Context, baseURL string, id string) (string, error) {
+	chain, err := s.resolveSelfLink(ctx, baseURL, id)
 	if err != nil {
-		return "", fmt.Errorf("%s not found  %w ", originalURL, ErrURLNotFound)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("get original URL canceled: %w", ctxErr)
+		}
+		return "", err
+	}
+	if _, ok := chain.protected(); ok {
+		return "", fmt.Errorf("%s: %w", id, ErrPasswordRequired)
+	}
+	return s.follow(ctx, id, chain)
+}
+
+// follow проверяет, что адрес назначения цепочки не заблокирован, засчитывает переход и возвращает адрес.
+func (s *URLService) follow(ctx context.Context, id string, chain linkChain) (string, error) {
+	originalURL := chain.target()
+	if s.blocklist != nil && s.blocklist.BlockedURL(originalURL) {
+		return "", fmt.Errorf("%s: %w", id, ErrURLBlocked)
+	}
+	// Переход засчитывается последним, чтобы отклоненный запрос не расходовал лимит.
+	if err := s.click(ctx, chain.limited()); err != nil {
+		return "", err
 	}
 	return originalURL, nil
 }
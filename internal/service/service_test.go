@@ -1,17 +1,26 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"linkshrink/internal/config"
 	"linkshrink/internal/repository"
+	memorystore "linkshrink/internal/repository/memory_store"
 	"linkshrink/internal/service"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const testFilePath = "test_storage.json"
@@ -21,8 +30,8 @@ type MockRepository struct {
 	mock.Mock // Включаем интерфейс репозитория
 }
 
-func (m *MockRepository) Save(id, originalURL string) error {
-	args := m.Called(id, originalURL)
+func (m *MockRepository) Save(ctx context.Context, id, originalURL string) error {
+	args := m.Called(ctx, id, originalURL)
 	err := args.Error(0) // Вызов метода, который возвращает ошибку
 	if err != nil {
 		log.Printf("Error on save: %v", err)
@@ -31,8 +40,35 @@ func (m *MockRepository) Save(id, originalURL string) error {
 	return nil
 }
 
-func (m *MockRepository) Find(id string) (string, error) {
-	args := m.Called(id)
+// SaveURL передает моку только ID и URL записи: остальные поля тестам сервиса не нужны.
+func (m *MockRepository) SaveURL(ctx context.Context, url repository.URLData) error {
+	args := m.Called(ctx, url.UUID, url.OriginalURL)
+	err := args.Error(0)
+	if err != nil {
+		log.Printf("Error on save: %v", err)
+		return fmt.Errorf("failed to save: %w", err)
+	}
+	return nil
+}
+
+func (m *MockRepository) SaveBatch(ctx context.Context, urls []repository.URLData) error {
+	args := m.Called(ctx, urls)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Find(ctx context.Context, id string) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id string) (repository.URLData, error) {
+	args := m.Called(ctx, id)
+	url, _ := args.Get(0).(repository.URLData)
+	return url, args.Error(1)
+}
+
+func (m *MockRepository) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	args := m.Called(ctx, originalURL)
 	return args.String(0), args.Error(1)
 }
 
@@ -56,6 +92,16 @@ func (m *MockRepository) SaveToFile() error {
 	return nil
 }
 
+func newPrivateGenerator(t *testing.T) service.IDGenerator {
+	t.Helper()
+	generator, err := service.NewPrivateIDGenerator(config.IDConfig{
+		Alphabet:           service.AlphabetBase62,
+		PrivateEntropyBits: config.DefaultPrivateEntropyBits,
+	})
+	require.NoError(t, err)
+	return generator
+}
+
 // TestURLService_Shortcut тестирует метод Shorten.
 func TestURLService_Shortcut(t *testing.T) {
 	mockRepo := new(MockRepository)
@@ -63,9 +109,9 @@ func TestURLService_Shortcut(t *testing.T) {
 
 	originalURL := "http://example.com"
 	baseURL := "http://localhost:8080/"
-	mockRepo.On("Save", mock.Anything, originalURL).Return(nil)
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, originalURL).Return(nil)
 
-	shortenedURL, err := srv.Shorten(baseURL, originalURL)
+	shortenedURL, err := srv.Shorten(context.Background(), baseURL, originalURL, service.ShortenOptions{})
 
 	require.NoError(t, err)
 	assert.Contains(t, shortenedURL, "http://localhost:8080/") // Проверяем, что URL содержит базовый адрес
@@ -79,12 +125,97 @@ func TestURLService_Shortcut_InternalServer(t *testing.T) {
 
 	originalURL := "http://example.com"
 	baseURL := "http://localhost:8080/"
-	mockRepo.On("Save", mock.Anything, originalURL).Return(repository.ErrIDAlreadyExists)
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, originalURL).Return(repository.ErrIDAlreadyExists)
 
-	shortenedURL, err := srv.Shorten(baseURL, originalURL)
+	shortenedURL, err := srv.Shorten(context.Background(), baseURL, originalURL, service.ShortenOptions{})
 
 	assert.True(t, errors.Is(err, service.ErrInternalServer), "expected ErrInternalServer")
 	assert.Empty(t, shortenedURL)
+	// Количество попыток ограничено, а случайный ID не сверяется с сохраненным URL.
+	mockRepo.AssertNumberOfCalls(t, "SaveURL", 10)
+	mockRepo.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+}
+
+// TestURLService_Shortcut_GrowsIDLength тестирует, что при частых коллизиях длина ID растет:
+// запрос, исчерпавший попытки, отклоняется, а следующий получает более длинный ID.
+func TestURLService_Shortcut_GrowsIDLength(t *testing.T) {
+	mockRepo := new(MockRepository)
+	gen, err := service.NewRandomIDGenerator(2, service.AlphabetBase62)
+	require.NoError(t, err)
+	srv := service.NewURLServiceWithGenerator(mockRepo, gen, newPrivateGenerator(t))
+
+	originalURL := "http://example.com"
+	// Все ID длины 2 заняты.
+	mockRepo.On("SaveURL", mock.Anything, mock.MatchedBy(func(id string) bool { return len(id) == 2 }), originalURL).
+		Return(repository.ErrIDAlreadyExists)
+	mockRepo.On("SaveURL", mock.Anything, mock.MatchedBy(func(id string) bool { return len(id) == 3 }), originalURL).
+		Return(nil).Once()
+
+	_, err = srv.Shorten(context.Background(), "http://localhost:8080", originalURL, service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrInternalServer)
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", originalURL, service.ShortenOptions{})
+
+	require.NoError(t, err)
+	assert.Len(t, shortenedURL, len("http://localhost:8080/")+3)
+	id, err := gen.GenerateID(originalURL)
+	require.NoError(t, err)
+	assert.Len(t, id, 3)
+}
+
+// TestURLService_Shortcut_Private тестирует, что приватная ссылка получает длинный ID
+// независимо от стратегии генерации обычных ссылок.
+func TestURLService_Shortcut_Private(t *testing.T) {
+	mockRepo := new(MockRepository)
+	gen, err := service.NewSequentialIDGenerator(config.DefaultIDLength, service.AlphabetBase62, "")
+	require.NoError(t, err)
+	srv := service.NewURLServiceWithGenerator(mockRepo, gen, newPrivateGenerator(t))
+
+	originalURL := "http://example.com/private"
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, originalURL).Return(nil).Once()
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", originalURL,
+		service.ShortenOptions{Private: true})
+
+	require.NoError(t, err)
+	// 128 бит энтропии в base62 - 22 символа.
+	assert.Len(t, shortenedURL, len("http://localhost:8080/")+22)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_PrivateNotShared тестирует, что приватная ссылка не выдается повторным
+// запросам на тот же URL, а обычная ссылка не выдается вместо приватной.
+func TestURLService_Shortcut_PrivateNotShared(t *testing.T) {
+	srv := service.NewURLService(memorystore.NewMemoryStore(zaptest.NewLogger(t)))
+	ctx := context.Background()
+	const baseURL = "http://localhost:8080"
+
+	private, err := srv.Shorten(ctx, baseURL, "http://example.com/a", service.ShortenOptions{Private: true})
+	require.NoError(t, err)
+	public, err := srv.Shorten(ctx, baseURL, "http://example.com/a", service.ShortenOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, private, public)
+
+	again, err := srv.Shorten(ctx, baseURL, "http://example.com/a", service.ShortenOptions{Private: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, private, again)
+	assert.NotEqual(t, public, again)
+
+	// Обычный запрос по-прежнему получает обычную ссылку.
+	existing, err := srv.Shorten(ctx, baseURL, "http://example.com/a", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, public, existing)
+
+	results, err := srv.ShortenBatch(ctx, baseURL, "", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com/a"},
+		{CorrelationID: "2", OriginalURL: "http://example.com/a", Private: true},
+		{CorrelationID: "3", OriginalURL: "http://example.com/a", Private: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, public, results[0].ShortURL)
+	assert.NotContains(t, []string{public, private, again, results[2].ShortURL}, results[1].ShortURL)
+	assert.NotContains(t, []string{public, private, again}, results[2].ShortURL)
 }
 
 // TestURLService_Shortcut_InvalidURL тестирует метод Shorten с недопустимым URL.
@@ -93,9 +224,13 @@ func TestURLService_Shortcut_InvalidURL(t *testing.T) {
 	srv := service.NewURLService(mockRepo)
 	baseURL := "http://localhost:8080/"
 
-	shortenedURL, err := srv.Shorten(baseURL, "")
+	shortenedURL, err := srv.Shorten(context.Background(), baseURL, "", service.ShortenOptions{})
 	assert.True(t, errors.Is(err, service.ErrInvalidURL), "expected ErrInvalidURL")
 	assert.Empty(t, shortenedURL)
+
+	_, err = srv.Shorten(context.Background(), baseURL, "javascript:alert(1)", service.ShortenOptions{})
+	assert.ErrorIs(t, err, service.ErrInvalidURL)
+	mockRepo.AssertNotCalled(t, "SaveURL", mock.Anything, mock.Anything, mock.Anything)
 }
 
 // TestURLService_GetOriginalURL тестирует метод GetOriginalURL.
@@ -105,9 +240,9 @@ func TestURLService_GetOriginalURL(t *testing.T) {
 
 	id := "abc123"
 	originalURL := "http://example.com"
-	mockRepo.On("Find", id).Return(originalURL, nil)
+	mockRepo.On("Get", mock.Anything, id).Return(repository.URLData{UUID: id, OriginalURL: originalURL}, nil)
 
-	result, err := srv.GetOriginalURL(id)
+	result, err := srv.GetOriginalURL(context.Background(), "http://localhost:8080", id)
 
 	require.NoError(t, err)
 	assert.Equal(t, originalURL, result)
@@ -120,11 +255,324 @@ func TestURLService_GetOriginalURL_NotFound(t *testing.T) {
 	srv := service.NewURLService(mockRepo)
 
 	id := "nonexistent"
-	mockRepo.On("Find", id).Return("", service.ErrURLNotFound)
+	mockRepo.On("Get", mock.Anything, id).Return(repository.URLData{}, service.ErrURLNotFound)
 
-	result, err := srv.GetOriginalURL(id)
+	result, err := srv.GetOriginalURL(context.Background(), "http://localhost:8080", id)
 
 	assert.True(t, errors.Is(err, service.ErrURLNotFound), "expected ErrURLNotFound")
 	assert.Empty(t, result)
 	mockRepo.AssertExpectations(t)
 }
+
+// TestURLService_Shortcut_Canceled тестирует, что Shorten прекращает попытки после отмены контекста.
+func TestURLService_Shortcut_Canceled(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	originalURL := "http://example.com"
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, originalURL).Return(context.Canceled).Once()
+
+	shortenedURL, err := srv.Shorten(ctx, "http://localhost:8080/", originalURL, service.ShortenOptions{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_ShortenBatch тестирует метод ShortenBatch.
+func TestURLService_ShortenBatch(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	items := []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com"},
+		{CorrelationID: "2", OriginalURL: "http://another.com"},
+	}
+	// Первая попытка натыкается на занятый ID, вторая сохраняет пакет целиком.
+	mockRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(repository.ErrIDAlreadyExists).Once()
+	mockRepo.On("FindByOriginalURL", mock.Anything, mock.Anything).Return("", repository.ErrURLNotFound).Times(2)
+	mockRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(urls []repository.URLData) bool {
+		return len(urls) == 2 && urls[0].OriginalURL == "http://example.com" && urls[1].OriginalURL == "http://another.com"
+	})).Return(nil).Once()
+
+	results, err := srv.ShortenBatch(context.Background(), "http://localhost:8080", "", items)
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "1", results[0].CorrelationID)
+	assert.Equal(t, "2", results[1].CorrelationID)
+	assert.Contains(t, results[0].ShortURL, "http://localhost:8080/")
+	assert.NotEqual(t, results[0].ShortURL, results[1].ShortURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_Conflict тестирует, что для уже сокращенного URL возвращается существующая ссылка.
+func TestURLService_Shortcut_Conflict(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	originalURL := "http://example.com"
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, originalURL).Return(repository.ErrURLAlreadyExists).Once()
+	mockRepo.On("FindByOriginalURL", mock.Anything, originalURL).Return("abc123", nil).Once()
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", originalURL, service.ShortenOptions{})
+
+	assert.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, "http://localhost:8080/abc123", shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_HashConflict тестирует, что при детерминированной стратегии
+// занятый тем же URL ID считается повторным сокращением, а не коллизией.
+func TestURLService_Shortcut_HashConflict(t *testing.T) {
+	mockRepo := new(MockRepository)
+	gen, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	srv := service.NewURLServiceWithGenerator(mockRepo, gen, newPrivateGenerator(t))
+
+	originalURL := "http://example.com"
+	id, err := gen.GenerateID(originalURL)
+	require.NoError(t, err)
+	mockRepo.On("SaveURL", mock.Anything, id, originalURL).Return(repository.ErrIDAlreadyExists).Once()
+	mockRepo.On("Find", mock.Anything, id).Return(originalURL, nil).Once()
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", originalURL, service.ShortenOptions{})
+
+	assert.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, "http://localhost:8080/"+id, shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_Normalizes тестирует, что сохраняется и ищется нормализованный URL,
+// поэтому разные записи одного адреса распознаются как уже сокращенный URL.
+func TestURLService_Shortcut_Normalizes(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	mockRepo.On("SaveURL", mock.Anything, mock.Anything, "http://example.com/Path").
+		Return(repository.ErrURLAlreadyExists).Once()
+	mockRepo.On("FindByOriginalURL", mock.Anything, "http://example.com/Path").Return("abc123", nil).Once()
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", " HTTP://Example.com:80/Path\n",
+		service.ShortenOptions{})
+
+	assert.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, "http://localhost:8080/abc123", shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Blocklist тестирует отказ в сокращении и раскрытии ссылок на заблокированные домены.
+func TestURLService_Blocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("*.evil.com\n"), 0o600))
+	blocklist, err := service.NewBlocklist(path, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	mockRepo := new(MockRepository)
+	srv := service.NewURLServiceWithConfig(mockRepo, service.URLServiceConfig{Blocklist: blocklist})
+	ctx := context.Background()
+
+	_, err = srv.Shorten(ctx, "http://localhost:8080", "https://Login.EVIL.com/", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrURLBlocked)
+	_, err = srv.ShortenBatch(ctx, "http://localhost:8080", "", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com"},
+		{CorrelationID: "2", OriginalURL: "http://a.evil.com"},
+	})
+	require.ErrorIs(t, err, service.ErrURLBlocked)
+	mockRepo.AssertNotCalled(t, "SaveURL", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+
+	// Ссылка, сокращенная до блокировки домена, больше не раскрывается.
+	mockRepo.On("Get", mock.Anything, "abc123").
+		Return(repository.URLData{UUID: "abc123", OriginalURL: "http://old.evil.com/page"}, nil)
+	_, err = srv.GetOriginalURL(ctx, "http://localhost:8080", "abc123")
+	require.ErrorIs(t, err, service.ErrURLBlocked)
+}
+
+// TestURLService_Shortcut_Alias тестирует сохранение URL под выбранным пользователем алиасом.
+func TestURLService_Shortcut_Alias(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	originalURL := "http://example.com/report"
+	mockRepo.On("SaveURL", mock.Anything, "q4-report", originalURL).Return(nil).Once()
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", originalURL,
+		service.ShortenOptions{Alias: "q4-report"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/q4-report", shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_AliasTaken тестирует, что занятый другим URL алиас не перезаписывается,
+// а повторное сокращение того же URL с тем же алиасом считается конфликтом URL.
+func TestURLService_Shortcut_AliasTaken(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+	opts := service.ShortenOptions{Alias: "q4-report"}
+
+	mockRepo.On("SaveURL", mock.Anything, "q4-report", mock.Anything).Return(repository.ErrIDAlreadyExists)
+	mockRepo.On("Find", mock.Anything, "q4-report").Return("http://example.com/report", nil)
+
+	_, err := srv.Shorten(context.Background(), "http://localhost:8080", "http://another.com", opts)
+	assert.ErrorIs(t, err, service.ErrAliasTaken)
+
+	shortenedURL, err := srv.Shorten(context.Background(), "http://localhost:8080", "http://example.com/report", opts)
+	assert.ErrorIs(t, err, service.ErrURLConflict)
+	assert.Equal(t, "http://localhost:8080/q4-report", shortenedURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_Shortcut_InvalidAlias тестирует отклонение некорректных алиасов без обращения к хранилищу.
+func TestURLService_Shortcut_InvalidAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		opts service.ShortenOptions
+	}{
+		{name: "too short", opts: service.ShortenOptions{Alias: "q4"}},
+		{name: "too long", opts: service.ShortenOptions{Alias: strings.Repeat("a", 65)}},
+		{name: "forbidden character", opts: service.ShortenOptions{Alias: "q4/report"}},
+		{name: "non-latin", opts: service.ShortenOptions{Alias: "отчет"}},
+		{name: "reserved", opts: service.ShortenOptions{Alias: "API"}},
+		{name: "private", opts: service.ShortenOptions{Alias: "q4-report", Private: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			srv := service.NewURLService(mockRepo)
+
+			_, err := srv.Shorten(context.Background(), "http://localhost:8080", "http://example.com", tt.opts)
+
+			assert.ErrorIs(t, err, service.ErrInvalidAlias)
+			mockRepo.AssertNotCalled(t, "SaveURL", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// TestURLService_ShortenBatch_Existing тестирует, что уже сокращенные URL и повторы внутри пакета
+// получают существующий ID, а сохраняются только новые URL.
+func TestURLService_ShortenBatch_Existing(t *testing.T) {
+	mockRepo := new(MockRepository)
+	srv := service.NewURLService(mockRepo)
+
+	items := []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com"},
+		{CorrelationID: "2", OriginalURL: "http://another.com"},
+		{CorrelationID: "3", OriginalURL: "http://another.com"},
+	}
+	mockRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(urls []repository.URLData) bool {
+		return len(urls) == 2
+	})).Return(repository.ErrURLAlreadyExists).Once()
+	mockRepo.On("FindByOriginalURL", mock.Anything, "http://example.com").Return("abc123", nil).Once()
+	mockRepo.On("FindByOriginalURL", mock.Anything, "http://another.com").Return("", repository.ErrURLNotFound).Once()
+	mockRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(urls []repository.URLData) bool {
+		return len(urls) == 1 && urls[0].OriginalURL == "http://another.com"
+	})).Return(nil).Once()
+
+	results, err := srv.ShortenBatch(context.Background(), "http://localhost:8080", "", items)
+
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "http://localhost:8080/abc123", results[0].ShortURL)
+	assert.NotEqual(t, results[0].ShortURL, results[1].ShortURL)
+	assert.Equal(t, results[1].ShortURL, results[2].ShortURL)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestURLService_ShortenBatch_Limits тестирует, что элементы пакета получают те же ограничения, что и Shorten,
+// и, как и приватные элементы, всегда получают новые ссылки, даже при детерминированной стратегии.
+func TestURLService_ShortenBatch_Limits(t *testing.T) {
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	hash, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	privateHash, err := service.NewHashIDGenerator(config.DefaultIDLength, service.AlphabetBase62)
+	require.NoError(t, err)
+	srv := service.NewURLServiceWithConfig(store, service.URLServiceConfig{
+		IDGenerator:        hash,
+		PrivateIDGenerator: privateHash,
+		PasswordCost:       bcrypt.MinCost,
+	})
+	ctx := context.Background()
+	const baseURL = "http://localhost:8080"
+
+	results, err := srv.ShortenBatch(ctx, baseURL, "", []service.BatchItem{
+		{CorrelationID: "plain", OriginalURL: "http://example.com"},
+		{CorrelationID: "ttl", OriginalURL: "http://example.com", TTL: time.Hour},
+		{CorrelationID: "clicks", OriginalURL: "http://example.com", MaxClicks: 1},
+		{CorrelationID: "password", OriginalURL: "http://example.com", Password: "secret"},
+		{CorrelationID: "private1", OriginalURL: "http://example.com", Private: true},
+		{CorrelationID: "private2", OriginalURL: "http://example.com", Private: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 6)
+
+	links := make(map[string]repository.URLData, len(results))
+	for _, result := range results {
+		link, err := store.Get(ctx, strings.TrimPrefix(result.ShortURL, baseURL+"/"))
+		require.NoError(t, err)
+		links[result.CorrelationID] = link
+	}
+	assert.Len(t, links, 6)
+	ids := make(map[string]struct{}, len(links))
+	for _, link := range links {
+		ids[link.UUID] = struct{}{}
+	}
+	assert.Len(t, ids, 6, "every item must get its own link")
+	assert.False(t, links["plain"].Exclusive)
+	require.NotNil(t, links["ttl"].ExpiresAt)
+	assert.Equal(t, 1, links["clicks"].MaxClicks)
+	assert.NotEmpty(t, links["password"].PasswordHash)
+	assert.True(t, links["private1"].Exclusive)
+
+	_, err = srv.ShortenBatch(ctx, baseURL, "", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com", MaxClicks: -1},
+	})
+	require.ErrorIs(t, err, service.ErrInvalidMaxClicks)
+}
+
+// TestURLService_ShortenBatch_Invalid тестирует отклонение некорректного пакета целиком.
+func TestURLService_ShortenBatch_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []service.BatchItem
+		err   error
+	}{
+		{name: "empty batch", items: nil, err: service.ErrInvalidBatch},
+		{
+			name:  "empty url",
+			items: []service.BatchItem{{CorrelationID: "1", OriginalURL: "http://example.com"}, {CorrelationID: "2"}},
+			err:   service.ErrInvalidURL,
+		},
+		{
+			name:  "invalid url",
+			items: []service.BatchItem{{CorrelationID: "1", OriginalURL: "hello"}},
+			err:   service.ErrInvalidURL,
+		},
+		{
+			name: "duplicate correlation id",
+			items: []service.BatchItem{
+				{CorrelationID: "1", OriginalURL: "http://example.com"},
+				{CorrelationID: "1", OriginalURL: "http://another.com"},
+			},
+			err: service.ErrInvalidBatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			srv := service.NewURLService(mockRepo)
+
+			results, err := srv.ShortenBatch(context.Background(), "http://localhost:8080", "", tt.items)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Empty(t, results)
+			mockRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+		})
+	}
+}
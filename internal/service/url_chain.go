@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"linkshrink/internal/repository"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Политики обработки ссылок других сервисов сокращения.
+	ShortenerPolicyReject = "reject" // Отклонять
+	ShortenerPolicyFlag   = "flag"   // Сокращать, отмечая ссылку (URLData.Flagged) и записывая предупреждение в лог
+
+	// maxRedirectHops - количество переходов по собственным ссылкам, после которого цепочка считается петлей.
+	maxRedirectHops = 10
+)
+
+var (
+	// ErrSelfReference возвращается для URL, указывающих на сам сервис, но не на существующую короткую ссылку.
+	ErrSelfReference = errors.New("URL points to this service")
+	// ErrShortenerURL возвращается для ссылок других сервисов сокращения, если они отклоняются.
+	ErrShortenerURL = errors.New("URL of another shortener")
+	// ErrRedirectLoop возвращается, если короткие ссылки ссылаются друг на друга по кругу.
+	ErrRedirectLoop = errors.New("redirect loop detected")
+)
+
+// ShortenerDetector распознает ссылки известных сервисов сокращения,
+// которые скрывают настоящий адрес назначения.
+type ShortenerDetector struct {
+	hosts  map[string]struct{}
+	reject bool // Отклонять ссылки, а не только отмечать их в логе
+}
+
+// NewShortenerDetector создает детектор со списком хостов и политикой из конфигурации.
+func NewShortenerDetector(cfg config.URLConfig) (*ShortenerDetector, error) {
+	if cfg.ShortenerPolicy != ShortenerPolicyReject && cfg.ShortenerPolicy != ShortenerPolicyFlag {
+		return nil, fmt.Errorf("%w: unknown shortener policy %q", ErrInvalidURLConfig, cfg.ShortenerPolicy)
+	}
+
+	hosts := make(map[string]struct{}, len(cfg.ShortenerHosts))
+	for _, host := range cfg.ShortenerHosts {
+		normalized, err := normalizeHost(strings.ToLower(strings.TrimSpace(host)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: shortener host %q: %w", ErrInvalidURLConfig, host, err)
+		}
+		hosts[normalized] = struct{}{}
+	}
+	return &ShortenerDetector{hosts: hosts, reject: cfg.ShortenerPolicy == ShortenerPolicyReject}, nil
+}
+
+// DefaultShortenerDetector создает детектор, отклоняющий ссылки config.DefaultShortenerHosts.
+func DefaultShortenerDetector() *ShortenerDetector {
+	detector, err := NewShortenerDetector(config.URLConfig{
+		ShortenerHosts:  config.DefaultShortenerHosts,
+		ShortenerPolicy: ShortenerPolicyReject,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return detector
+}
+
+// Match сообщает, принадлежит ли хост (или домен, поддоменом которого он является) сервису сокращения.
+func (d *ShortenerDetector) Match(host string) bool {
+	for host != "" {
+		if _, ok := d.hosts[host]; ok {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+	return false
+}
+
+// selfLinkID возвращает ID короткой ссылки, если URL указывает на сервис с адресом baseURL.
+// Второе значение сообщает, указывает ли URL на сервис вообще: для адресов сервиса,
+// не являющихся короткой ссылкой (например, API), ID пустой.
+// Схема не сравнивается: http и https версии одного хоста - тот же сервис.
+func (s *URLService) selfLinkID(baseURL string, target string) (string, bool) {
+	base, err := s.normalizer.Normalize(baseURL)
+	if err != nil {
+		return "", false
+	}
+	baseParsed, err := url.Parse(base)
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host != baseParsed.Host {
+		return "", false
+	}
+
+	prefix := strings.TrimSuffix(baseParsed.Path, "/") + "/"
+	if u.Path+"/" == prefix {
+		return "", true
+	}
+	id, ok := strings.CutPrefix(u.Path, prefix)
+	if !ok {
+		return "", false
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return "", true
+	}
+	return id, true
+}
+
+// linkChain - короткие ссылки сервиса, ведущие друг на друга. Последняя ведет на внешний адрес.
+type linkChain []repository.URLData
+
+// target возвращает адрес назначения цепочки.
+func (c linkChain) target() string {
+	return c[len(c)-1].OriginalURL
+}
+
+// limited возвращает ID ссылок цепочки с ограниченным количеством переходов.
+func (c linkChain) limited() []string {
+	var ids []string
+	for _, link := range c {
+		if link.MaxClicks > 0 {
+			ids = append(ids, link.UUID)
+		}
+	}
+	return ids
+}
+
+// expiring сообщает, есть ли в цепочке ссылка со сроком действия.
+func (c linkChain) expiring() bool {
+	for _, link := range c {
+		if link.ExpiresAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// protected возвращает первую ссылку цепочки, защищенную паролем.
+func (c linkChain) protected() (repository.URLData, bool) {
+	for _, link := range c {
+		if link.PasswordHash != "" {
+			return link, true
+		}
+	}
+	return repository.URLData{}, false
+}
+
+// resolveSelfLink проходит цепочку коротких ссылок сервиса, начиная с id, до внешнего адреса.
+// Переходы не засчитываются. ErrRedirectLoop возвращается, если цепочка замкнута или слишком длинна,
+// ErrURLDeleted или ErrURLExpired - если любая ссылка цепочки удалена владельцем или истекла.
+func (s *URLService) resolveSelfLink(ctx context.Context, baseURL string, id string) (linkChain, error) {
+	chain := make(linkChain, 0, 1)
+	seen := make(map[string]struct{}, maxRedirectHops)
+	for range maxRedirectHops {
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("%w: link %s refers to itself", ErrRedirectLoop, id)
+		}
+		seen[id] = struct{}{}
+
+		link, err := s.repo.Get(ctx, id)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("resolve link canceled: %w", ctxErr)
+			}
+			return nil, fmt.Errorf("%s not found: %w", id, ErrURLNotFound)
+		}
+		if link.Deleted {
+			return nil, fmt.Errorf("%s: %w", id, ErrURLDeleted)
+		}
+		if link.Expired(s.now()) {
+			return nil, fmt.Errorf("%s: %w", id, ErrURLExpired)
+		}
+		chain = append(chain, link)
+
+		next, self := s.selfLinkID(baseURL, link.OriginalURL)
+		if !self || next == "" {
+			return chain, nil
+		}
+		id = next
+	}
+	return nil, fmt.Errorf("%w: more than %d hops", ErrRedirectLoop, maxRedirectHops)
+}
+
+// checkChain не дает сократить ссылку на сам сервис или на другой сервис сокращения.
+// Короткая ссылка сервиса заменяется адресом, на который она ведет. Ссылки со сроком действия,
+// с ограниченным количеством переходов и защищенные паролем не раскрываются: иначе новая ссылка
+// позволила бы обойти срок, лимит или пароль.
+func (s *URLService) checkChain(ctx context.Context, baseURL string, originalURL string) (string, error) {
+	if id, self := s.selfLinkID(baseURL, originalURL); self {
+		if id == "" {
+			return "", fmt.Errorf("%s: %w", originalURL, ErrSelfReference)
+		}
+		chain, err := s.resolveSelfLink(ctx, baseURL, id)
+		if errors.Is(err, ErrURLNotFound) || errors.Is(err, ErrURLExpired) || errors.Is(err, ErrURLDeleted) {
+			return "", fmt.Errorf("%s: %w", originalURL, ErrSelfReference)
+		}
+		if err != nil {
+			return "", err
+		}
+		if chain.expiring() {
+			return "", fmt.Errorf("%s: link has an expiration: %w", originalURL, ErrSelfReference)
+		}
+		if len(chain.limited()) > 0 {
+			return "", fmt.Errorf("%s: link has a click limit: %w", originalURL, ErrSelfReference)
+		}
+		if _, ok := chain.protected(); ok {
+			return "", fmt.Errorf("%s: link is password-protected: %w", originalURL, ErrSelfReference)
+		}
+		originalURL = chain.target()
+	}
+
+	u, err := url.Parse(originalURL)
+	if err != nil || !s.shorteners.Match(u.Hostname()) {
+		return originalURL, nil
+	}
+	if s.shorteners.reject {
+		return "", fmt.Errorf("%s: %w", originalURL, ErrShortenerURL)
+	}
+	s.logger.Warn("Shortening URL of another shortener", zap.String("url", originalURL))
+	return originalURL, nil
+}
+
+// flagged сообщает, нужно ли отметить ссылку на проверенный checkURL адрес: при политике flag
+// ссылки других сервисов сокращения сохраняются с отметкой Flagged.
+func (s *URLService) flagged(originalURL string) bool {
+	if s.shorteners.reject {
+		return false
+	}
+	u, err := url.Parse(originalURL)
+	return err == nil && s.shorteners.Match(u.Hostname())
+}
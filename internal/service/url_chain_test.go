@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"linkshrink/internal/config"
+	memorystore "linkshrink/internal/repository/memory_store"
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const testBaseURL = "http://localhost:8080"
+
+// TestURLService_SelfReference тестирует, что короткая ссылка сервиса заменяется адресом назначения,
+// а другие адреса сервиса не сокращаются.
+func TestURLService_SelfReference(t *testing.T) {
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	srv := service.NewURLService(store)
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, "abc123", "http://example.com"))
+
+	for _, link := range []string{"http://localhost:8080/abc123", "HTTPS://LOCALHOST:8080/abc123?utm=1"} {
+		shortenedURL, err := srv.Shorten(ctx, testBaseURL, link, service.ShortenOptions{})
+		require.ErrorIs(t, err, service.ErrURLConflict, link)
+		assert.Equal(t, testBaseURL+"/abc123", shortenedURL)
+	}
+
+	for _, link := range []string{testBaseURL + "/", testBaseURL + "/api/shorten", testBaseURL + "/nope"} {
+		_, err := srv.Shorten(ctx, testBaseURL, link, service.ShortenOptions{})
+		require.ErrorIs(t, err, service.ErrSelfReference, link)
+	}
+
+	// Тот же хост на другом порту - другой сервис.
+	_, err := srv.Shorten(ctx, testBaseURL, "http://localhost:9090/abc123", service.ShortenOptions{})
+	require.NoError(t, err)
+}
+
+// TestURLService_ShortenerHosts тестирует отклонение и пометку ссылок других сервисов сокращения.
+func TestURLService_ShortenerHosts(t *testing.T) {
+	ctx := context.Background()
+
+	srv := service.NewURLService(memorystore.NewMemoryStore(zaptest.NewLogger(t)))
+	for _, link := range []string{"https://bit.ly/abc", "https://WWW.TinyURL.com/abc"} {
+		_, err := srv.Shorten(ctx, testBaseURL, link, service.ShortenOptions{})
+		require.ErrorIs(t, err, service.ErrShortenerURL, link)
+	}
+	_, err := srv.Shorten(ctx, testBaseURL, "https://notbit.ly/abc", service.ShortenOptions{})
+	require.NoError(t, err)
+
+	detector, err := service.NewShortenerDetector(config.URLConfig{
+		ShortenerHosts:  []string{"bit.ly"},
+		ShortenerPolicy: service.ShortenerPolicyFlag,
+	})
+	require.NoError(t, err)
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	srv = service.NewURLServiceWithConfig(store, service.URLServiceConfig{
+		Shorteners: detector,
+		Logger:     zaptest.NewLogger(t),
+	})
+	// При политике flag ссылка сохраняется с отметкой, остальные ссылки не отмечаются.
+	for link, flagged := range map[string]bool{"https://bit.ly/abc": true, "https://example.com/abc": false} {
+		shortenedURL, err := srv.Shorten(ctx, testBaseURL, link, service.ShortenOptions{})
+		require.NoError(t, err)
+		url, err := store.Get(ctx, strings.TrimPrefix(shortenedURL, testBaseURL+"/"))
+		require.NoError(t, err)
+		assert.Equal(t, flagged, url.Flagged, link)
+	}
+	results, err := srv.ShortenBatch(ctx, testBaseURL, "", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://bit.ly/batch"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	url, err := store.Get(ctx, strings.TrimPrefix(results[0].ShortURL, testBaseURL+"/"))
+	require.NoError(t, err)
+	assert.True(t, url.Flagged)
+
+	_, err = service.NewShortenerDetector(config.URLConfig{ShortenerPolicy: "ignore"})
+	require.ErrorIs(t, err, service.ErrInvalidURLConfig)
+}
+
+// TestURLService_RedirectLoop тестирует раскрытие цепочки собственных ссылок и обнаружение петли.
+func TestURLService_RedirectLoop(t *testing.T) {
+	store := memorystore.NewMemoryStore(zaptest.NewLogger(t))
+	srv := service.NewURLService(store)
+	ctx := context.Background()
+
+	// Такие записи могли появиться до проверки при сокращении или при смене BaseURL.
+	require.NoError(t, store.Save(ctx, "first", testBaseURL+"/second"))
+	require.NoError(t, store.Save(ctx, "second", "http://example.com"))
+	require.NoError(t, store.Save(ctx, "ping", testBaseURL+"/pong"))
+	require.NoError(t, store.Save(ctx, "pong", testBaseURL+"/ping"))
+	require.NoError(t, store.Save(ctx, "self", testBaseURL+"/self"))
+
+	originalURL, err := srv.GetOriginalURL(ctx, testBaseURL, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", originalURL)
+
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, "ping")
+	require.ErrorIs(t, err, service.ErrRedirectLoop)
+	_, err = srv.GetOriginalURL(ctx, testBaseURL, "self")
+	require.ErrorIs(t, err, service.ErrRedirectLoop)
+
+	_, err = srv.Shorten(ctx, testBaseURL, testBaseURL+"/ping", service.ShortenOptions{})
+	require.ErrorIs(t, err, service.ErrRedirectLoop)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"linkshrink/internal/config"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidURLConfig = errors.New("invalid URL validation config")
+
+// defaultPorts - порты схем, которые при нормализации удаляются из URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// URLNormalizer проверяет оригинальные URL и приводит их к каноническому виду,
+// чтобы один и тот же адрес, записанный по-разному, распознавался как уже сокращенный.
+type URLNormalizer struct {
+	schemes   map[string]struct{}
+	maxLength int
+}
+
+// NewURLNormalizer создает нормализатор с разрешенными схемами и максимальной длиной из конфигурации.
+func NewURLNormalizer(cfg config.URLConfig) (*URLNormalizer, error) {
+	if len(cfg.AllowedSchemes) == 0 {
+		return nil, fmt.Errorf("%w: no allowed URL schemes", ErrInvalidURLConfig)
+	}
+	if cfg.MaxLength < 1 {
+		return nil, fmt.Errorf("%w: max URL length %d must be positive", ErrInvalidURLConfig, cfg.MaxLength)
+	}
+
+	schemes := make(map[string]struct{}, len(cfg.AllowedSchemes))
+	for _, scheme := range cfg.AllowedSchemes {
+		schemes[strings.ToLower(strings.TrimSpace(scheme))] = struct{}{}
+	}
+	return &URLNormalizer{schemes: schemes, maxLength: cfg.MaxLength}, nil
+}
+
+// DefaultURLNormalizer создает нормализатор с настройками по умолчанию: http и https URL
+// длиной до config.DefaultMaxURLLength.
+func DefaultURLNormalizer() *URLNormalizer {
+	normalizer, err := NewURLNormalizer(config.URLConfig{
+		AllowedSchemes: config.DefaultAllowedSchemes,
+		MaxLength:      config.DefaultMaxURLLength,
+	})
+	if err != nil {
+		// Параметры по умолчанию корректны, ошибка здесь - ошибка программиста.
+		panic(err)
+	}
+	return normalizer
+}
+
+// Normalize проверяет URL и возвращает его канонический вид: без пробелов по краям,
+// со схемой и хостом в нижнем регистре, международным доменом в punycode и без порта по умолчанию.
+// Путь, параметры и фрагмент не меняются: их смысл определяет сервер назначения.
+// Ошибки оборачивают ErrInvalidURL и описывают причину.
+func (n *URLNormalizer) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("%w: url is empty", ErrInvalidURL)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: scheme is missing", ErrInvalidURL)
+	}
+	if _, ok := n.schemes[u.Scheme]; !ok {
+		return "", fmt.Errorf("%w: scheme %q is not allowed", ErrInvalidURL, u.Scheme)
+	}
+	// У URL вида "http:example.com" нет хоста, весь остаток попадает в Opaque.
+	if u.Opaque != "" || u.Hostname() == "" {
+		return "", fmt.Errorf("%w: host is required", ErrInvalidURL)
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port != "" {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return "", fmt.Errorf("%w: port %q is out of range", ErrInvalidURL, port)
+		}
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	u.Host = joinHostPort(host, port)
+
+	normalized := u.String()
+	if len(normalized) > n.maxLength {
+		return "", fmt.Errorf("%w: length %d exceeds %d characters", ErrInvalidURL, len(normalized), n.maxLength)
+	}
+	return normalized, nil
+}
+
+// normalizeHost переводит хост в нижний регистр, а международное доменное имя - в punycode.
+// IP-адреса приводятся к канонической записи.
+func normalizeHost(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid host %q: %w", ErrInvalidURL, host, err)
+	}
+	return ascii, nil
+}
+
+func joinHostPort(host, port string) string {
+	if strings.Contains(host, ":") { // IPv6
+		host = "[" + host + "]"
+	}
+	if port == "" {
+		return host
+	}
+	return host + ":" + port
+}
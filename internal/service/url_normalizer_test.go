@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"linkshrink/internal/config"
+	"linkshrink/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLNormalizer_Normalize(t *testing.T) {
+	normalizer := service.DefaultURLNormalizer()
+
+	tests := []struct {
+		name     string
+		rawURL   string
+		expected string
+	}{
+		{name: "unchanged", rawURL: "https://example.com/path?q=1#top", expected: "https://example.com/path?q=1#top"},
+		{name: "whitespace", rawURL: " \thttp://example.com/\n", expected: "http://example.com/"},
+		{name: "case of scheme and host", rawURL: "HTTP://Example.COM/Path", expected: "http://example.com/Path"},
+		{name: "default http port", rawURL: "http://example.com:80/", expected: "http://example.com/"},
+		{name: "default https port", rawURL: "https://example.com:443", expected: "https://example.com"},
+		{name: "custom port", rawURL: "https://example.com:8443/", expected: "https://example.com:8443/"},
+		{name: "idn", rawURL: "http://пример.рф/путь", expected: "http://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{name: "ipv6", rawURL: "http://[::1]:80/", expected: "http://[::1]/"},
+		{name: "user info", rawURL: "http://user@example.com", expected: "http://user@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := normalizer.Normalize(tt.rawURL)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, normalized)
+		})
+	}
+}
+
+func TestURLNormalizer_Invalid(t *testing.T) {
+	normalizer := service.DefaultURLNormalizer()
+
+	tests := []struct {
+		name   string
+		rawURL string
+		reason string
+	}{
+		{name: "empty", rawURL: "  ", reason: "url is empty"},
+		{name: "no scheme", rawURL: "hello", reason: "scheme is missing"},
+		{name: "javascript", rawURL: "javascript:alert(1)", reason: `scheme "javascript" is not allowed`},
+		{name: "ftp", rawURL: "ftp://example.com/file", reason: `scheme "ftp" is not allowed`},
+		{name: "no host", rawURL: "http:///path", reason: "host is required"},
+		{name: "opaque", rawURL: "http:example.com", reason: "host is required"},
+		{name: "port out of range", rawURL: "http://example.com:99999/", reason: "out of range"},
+		{name: "invalid host", rawURL: "http://exa mple.com/", reason: "invalid"},
+		{name: "too long", rawURL: "http://example.com/" + strings.Repeat("a", 2048), reason: "exceeds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := normalizer.Normalize(tt.rawURL)
+
+			require.ErrorIs(t, err, service.ErrInvalidURL)
+			assert.Contains(t, err.Error(), tt.reason)
+		})
+	}
+}
+
+func TestURLNormalizer_Config(t *testing.T) {
+	normalizer, err := service.NewURLNormalizer(config.URLConfig{AllowedSchemes: []string{"FTP"}, MaxLength: 30})
+	require.NoError(t, err)
+
+	normalized, err := normalizer.Normalize("ftp://example.com:21/file")
+	require.NoError(t, err)
+	assert.Equal(t, "ftp://example.com/file", normalized)
+
+	_, err = normalizer.Normalize("http://example.com")
+	require.ErrorIs(t, err, service.ErrInvalidURL)
+	_, err = normalizer.Normalize("ftp://example.com/" + strings.Repeat("a", 20))
+	require.ErrorIs(t, err, service.ErrInvalidURL)
+
+	_, err = service.NewURLNormalizer(config.URLConfig{MaxLength: 30})
+	require.ErrorIs(t, err, service.ErrInvalidURLConfig)
+	_, err = service.NewURLNormalizer(config.URLConfig{AllowedSchemes: []string{"http"}})
+	require.ErrorIs(t, err, service.ErrInvalidURLConfig)
+}
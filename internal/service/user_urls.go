@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"linkshrink/internal/repository"
+)
+
+// UserURL - ссылка, созданная пользователем.
+type UserURL struct {
+	ShortURL    string
+	OriginalURL string
+}
+
+// GetUserURLs возвращает ссылки, созданные пользователем userID. Удаленные ссылки и ссылки с истекшим
+// сроком действия не возвращаются: они больше не работают.
+func (s *URLService) GetUserURLs(ctx context.Context, baseURL string, userID string) ([]UserURL, error) {
+	finder, ok := s.repo.(repository.IUserURLs)
+	if !ok {
+		return nil, fmt.Errorf("%w: storage doesn't support user links", ErrInternalServer)
+	}
+	urls, err := finder.FindByUser(ctx, userID)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("get user URLs canceled: %w", ctxErr)
+		}
+		return nil, fmt.Errorf("%w: failed to find user URLs: %w", ErrInternalServer, err)
+	}
+
+	now := s.now()
+	result := make([]UserURL, 0, len(urls))
+	for _, url := range urls {
+		if url.Deleted || url.Expired(now) {
+			continue
+		}
+		result = append(result, UserURL{ShortURL: baseURL + "/" + url.UUID, OriginalURL: url.OriginalURL})
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"linkshrink/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestURLService_GetUserURLs тестирует, что пользователю возвращаются только созданные им
+// и еще действующие ссылки, а уже сокращенный URL остается за прежним владельцем.
+func TestURLService_GetUserURLs(t *testing.T) {
+	srv, _, clock := newExpiringService(t)
+	ctx := context.Background()
+
+	first, err := srv.Shorten(ctx, testBaseURL, "http://example.com/1", service.ShortenOptions{UserID: "alice"})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/short-lived",
+		service.ShortenOptions{UserID: "alice", TTL: time.Minute})
+	require.NoError(t, err)
+	results, err := srv.ShortenBatch(ctx, testBaseURL, "alice", []service.BatchItem{
+		{CorrelationID: "1", OriginalURL: "http://example.com/2"},
+	})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/3", service.ShortenOptions{UserID: "bob"})
+	require.NoError(t, err)
+	_, err = srv.Shorten(ctx, testBaseURL, "http://example.com/1", service.ShortenOptions{UserID: "bob"})
+	require.ErrorIs(t, err, service.ErrURLConflict)
+
+	clock.Advance(time.Minute)
+	urls, err := srv.GetUserURLs(ctx, testBaseURL, "alice")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []service.UserURL{
+		{ShortURL: first, OriginalURL: "http://example.com/1"},
+		{ShortURL: results[0].ShortURL, OriginalURL: "http://example.com/2"},
+	}, urls)
+
+	urls, err = srv.GetUserURLs(ctx, testBaseURL, "bob")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	urls, err = srv.GetUserURLs(ctx, testBaseURL, "carol")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}